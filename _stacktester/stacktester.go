@@ -12,6 +12,9 @@ import (
 	"reflect"
 	"time"
 	"strconv"
+	"encoding/binary"
+	"math"
+	"sort"
 )
 
 const verbose bool = false
@@ -32,7 +35,7 @@ type stackEntry struct {
 
 type StackMachine struct {
 	prefix []byte
-	tr fdb.Transaction
+	tr fdb.WriteTransaction
 	stack []stackEntry
 	lastVersion int64
 	threads sync.WaitGroup
@@ -345,6 +348,8 @@ func (sm *StackMachine) processInst(idx int, inst tuple.Tuple) {
 		if e != nil { panic(e) }
 	case op == "COMMIT":
 		sm.store(idx, sm.tr.Commit())
+	case op == "GET_VERSIONSTAMP":
+		sm.store(idx, sm.tr.GetVersionstamp())
 	case op == "RESET":
 		sm.tr.Reset()
 	case op == "CLEAR":
@@ -409,6 +414,23 @@ func (sm *StackMachine) processInst(idx int, inst tuple.Tuple) {
 			t = append(t, sm.waitAndPop().item)
 		}
 		sm.store(idx, []byte(t.Pack()))
+	case op == "TUPLE_PACK_WITH_VERSIONSTAMP":
+		var t tuple.Tuple
+		prefix := sm.waitAndPop().item.([]byte)
+		count := sm.waitAndPop().item.(int64)
+		for i := 0; i < int(count); i++ {
+			t = append(t, sm.waitAndPop().item)
+		}
+		packed, e := t.PackWithVersionstamp(prefix)
+		switch {
+		case e == nil:
+			sm.store(idx, []byte("OK"))
+			sm.store(idx, packed)
+		case !t.HasIncompleteVersionstamp():
+			sm.store(idx, []byte("ERROR: NONE"))
+		default:
+			sm.store(idx, []byte("ERROR: MULTIPLE"))
+		}
 	case op == "TUPLE_UNPACK":
 		t, e := tuple.Unpack(fdb.Key(sm.waitAndPop().item.([]byte)))
 		if e != nil {
@@ -426,6 +448,34 @@ func (sm *StackMachine) processInst(idx int, inst tuple.Tuple) {
 		bk, ek := t.FDBRangeKeys()
 		sm.store(idx, []byte(bk.FDBKey()))
 		sm.store(idx, []byte(ek.FDBKey()))
+	case op == "TUPLE_SORT":
+		count := sm.waitAndPop().item.(int64)
+		tuples := make([]tuple.Tuple, count)
+		for i := 0; i < int(count); i++ {
+			t, e := tuple.Unpack(fdb.Key(sm.waitAndPop().item.([]byte)))
+			if e != nil {
+				panic(e)
+			}
+			tuples[i] = t
+		}
+		sort.Slice(tuples, func(i, j int) bool { return tuple.Compare(tuples[i], tuples[j]) < 0 })
+		for _, t := range tuples {
+			sm.store(idx, t.Pack())
+		}
+	case op == "ENCODE_FLOAT":
+		b := sm.waitAndPop().item.([]byte)
+		sm.store(idx, math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case op == "ENCODE_DOUBLE":
+		b := sm.waitAndPop().item.([]byte)
+		sm.store(idx, math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case op == "DECODE_FLOAT":
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, math.Float32bits(sm.waitAndPop().item.(float32)))
+		sm.store(idx, b)
+	case op == "DECODE_DOUBLE":
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(sm.waitAndPop().item.(float64)))
+		sm.store(idx, b)
 	case op == "START_THREAD":
 		newsm := newStackMachine(sm.waitAndPop().item.([]byte), verbose, sm.de)
 		sm.threads.Add(1)
@@ -546,7 +596,7 @@ func (sm *StackMachine) Run() {
 	sm.threads.Wait()
 }
 
-var db fdb.Database
+var db fdb.DatabaseHandle
 
 func main() {
 	var clusterFile string
@@ -569,7 +619,7 @@ func main() {
 		log.Fatal(e)
 	}
 
-	db, e = fdb.Open(clusterFile, []byte("DB"))
+	db, e = fdb.OpenDatabase(clusterFile)
 	if e != nil {
 		log.Fatal(e)
 	}
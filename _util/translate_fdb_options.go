@@ -39,6 +39,7 @@ type Option struct {
 	ParamType string `xml:"paramType,attr"`
 	ParamDesc string `xml:"paramDescription,attr"`
 	Description string `xml:"description,attr"`
+	Hidden bool `xml:"hidden,attr"`
 }
 type Scope struct {
 	Name string `xml:"name,attr"`
@@ -85,7 +86,12 @@ func writeOpt(receiver string, opt Option) {
 
 	fmt.Println()
 
-	if opt.Description != "" {
+	if opt.Description == "Deprecated" {
+		fmt.Printf("// Deprecated: %s is no longer supported by FoundationDB.\n", function)
+		if opt.ParamDesc != "" {
+			fmt.Printf("//\n// Parameter: %s\n", opt.ParamDesc)
+		}
+	} else if opt.Description != "" {
 		fmt.Printf("// %s\n", opt.Description)
 		if opt.ParamDesc != "" {
 			fmt.Printf("//\n// Parameter: %s\n", opt.ParamDesc)
@@ -184,8 +190,11 @@ func int64ToBytes(i int64) ([]byte, error) {
 		if strings.HasSuffix(scope.Name, "Option") {
 			receiver := scope.Name + "s"
 
+			// Deprecated options are still generated (and marked as such)
+			// so that existing callers continue to compile; hidden options
+			// are for internal use by FoundationDB and are never generated.
 			for _, opt := range(scope.Option) {
-				if opt.Description != "Deprecated" { // Eww
+				if !opt.Hidden {
 					writeOpt(receiver, opt)
 				}
 			}
@@ -194,7 +203,7 @@ func int64ToBytes(i int64) ([]byte, error) {
 
 		if scope.Name == "MutationType" {
 			for _, opt := range(scope.Option) {
-				if opt.Description != "Deprecated" { // Eww
+				if opt.Description != "Deprecated" && !opt.Hidden { // Eww
 					writeMutation(opt)
 				}
 			}
@@ -217,7 +226,9 @@ type %s int
 const (
 `, scope.Name)
 		for _, opt := range(scope.Option) {
-			writeEnum(scope, opt, d)
+			if !opt.Hidden {
+				writeEnum(scope, opt, d)
+			}
 		}
 		fmt.Println(")")
 	}
@@ -0,0 +1,96 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package fdb
+
+// A DatabaseBackend is the storage engine underlying a Database. Databases
+// returned by Open and OpenDefault are backed by the FoundationDB C
+// library. NewDatabase wraps any other implementation (such as the in-memory
+// engine provided by the memdb package) in a Database, which may then be used
+// wherever a Database, Transactor or ReadTransactor is accepted.
+//
+// Since every operation on such a Database is carried out by its backend, a
+// DatabaseBackend that wraps another (such as one returned by
+// memdb.NewBackend) may be used to record, instrument or alter all of the
+// operations performed, including those of layers like the directory package.
+//
+// Most programs will never use DatabaseBackend directly.
+type DatabaseBackend interface {
+	// CreateTransaction returns a new transaction on the database.
+	CreateTransaction() (TransactionBackend, error)
+
+	// SetOption sets the database option identified by code (as defined in
+	// fdb.options) to the encoded parameter param.
+	SetOption(code int, param []byte) error
+}
+
+// A TransactionBackend carries out the operations of a Transaction (and of its
+// Snapshot) on behalf of a DatabaseBackend. Each method corresponds to the
+// Transaction method of the same name, with keys and key selectors already
+// resolved to Key and KeySelector values. Read methods that accept a snapshot
+// flag perform a snapshot read (which must not add a read conflict range) when
+// it is true.
+//
+// A TransactionBackend must be safe for concurrent use by multiple goroutines.
+type TransactionBackend interface {
+	Get(key Key, snapshot bool) FutureByteSlice
+	GetKey(sel KeySelector, snapshot bool) FutureKey
+
+	// GetRange returns a single batch of the key-value pairs between the
+	// begin and end key selectors. Iteration starts at 1 and is incremented
+	// for each successive batch of the same logical range read, as in the
+	// FoundationDB C API.
+	GetRange(begin, end KeySelector, options RangeOptions, snapshot bool, iteration int) FutureKeyValueArray
+
+	GetReadVersion() FutureInt64
+	SetReadVersion(version int64)
+	GetCommittedVersion() (int64, error)
+
+	Set(key Key, value []byte)
+	Clear(key Key)
+	ClearRange(begin, end Key)
+
+	// AtomicOp performs the mutation identified by code (as defined in
+	// fdb.options) on key with the operand param.
+	AtomicOp(key Key, param []byte, code int)
+
+	AddReadConflictRange(begin, end Key) error
+	AddWriteConflictRange(begin, end Key) error
+
+	// SetOption sets the transaction option identified by code (as defined
+	// in fdb.options) to the encoded parameter param.
+	SetOption(code int, param []byte) error
+
+	Watch(key Key) FutureNil
+	Commit() FutureNil
+	GetVersionstamp() FutureKey
+	OnError(e Error) FutureNil
+	Cancel()
+	Reset()
+
+	GetAddressesForKey(key Key) FutureStringSlice
+}
+
+// NewDatabase returns a Database that performs all of its operations using the
+// provided DatabaseBackend.
+func NewDatabase(b DatabaseBackend) Database {
+	return Database{&database{b}}
+}
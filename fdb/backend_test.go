@@ -0,0 +1,94 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package fdb_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/memdb"
+	"sync"
+	"testing"
+)
+
+// recordingBackend wraps a DatabaseBackend, recording the keys set by its
+// transactions.
+type recordingBackend struct {
+	fdb.DatabaseBackend
+	mu sync.Mutex
+	sets []fdb.Key
+}
+
+func (rb *recordingBackend) CreateTransaction() (fdb.TransactionBackend, error) {
+	t, e := rb.DatabaseBackend.CreateTransaction()
+	if e != nil {
+		return nil, e
+	}
+	return recordingTransaction{t, rb}, nil
+}
+
+type recordingTransaction struct {
+	fdb.TransactionBackend
+	rb *recordingBackend
+}
+
+func (rt recordingTransaction) Set(key fdb.Key, value []byte) {
+	rt.rb.mu.Lock()
+	rt.rb.sets = append(rt.rb.sets, key)
+	rt.rb.mu.Unlock()
+	rt.TransactionBackend.Set(key, value)
+}
+
+func TestBackendWrapper(t *testing.T) {
+	rb := &recordingBackend{DatabaseBackend: memdb.NewBackend()}
+	var db fdb.DatabaseHandle = fdb.NewDatabase(rb)
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fdb.Key("a"), []byte("1"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(rb.sets) != 1 || string(rb.sets[0]) != "a" {
+		t.Errorf("got sets %q, expected [a]", rb.sets)
+	}
+
+	// The operations of the directory layer are carried out by the backend as
+	// well.
+	rb.sets = nil
+	if _, e = directory.CreateOrOpen(db, []string{"d"}, nil); e != nil {
+		t.Fatal(e)
+	}
+	if len(rb.sets) == 0 {
+		t.Error("got no sets from creating a directory")
+	}
+
+	v, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(fdb.Key("a")).Get()
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if string(v.([]byte)) != "1" {
+		t.Errorf("got %q, expected 1", v)
+	}
+}
@@ -0,0 +1,234 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package fdb_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/memdb"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransactContext(t *testing.T) {
+	db := memdb.New()
+
+	// A cancelled context stops the retry loop.
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	_, e := db.TransactContext(ctx, func(tr fdb.Transaction) (interface{}, error) {
+		attempts++
+		if attempts == 3 {
+			cancel()
+		}
+		return nil, fdb.Error{1020}
+	})
+	if !errors.Is(e, context.Canceled) || attempts != 3 {
+		t.Errorf("got %v after %d attempts, expected context.Canceled after 3", e, attempts)
+	}
+
+	// So does a deadline passing while waiting to retry.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, e = db.ReadTransactContext(ctx, func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return nil, fdb.Error{1020}
+	})
+	if !errors.Is(e, context.DeadlineExceeded) {
+		t.Errorf("got %v, expected context.DeadlineExceeded", e)
+	}
+
+	// A context that is already done is not even given a transaction.
+	_, e = db.TransactContext(ctx, func(tr fdb.Transaction) (interface{}, error) {
+		t.Error("transactional function called with a done context")
+		return nil, nil
+	})
+	if !errors.Is(e, context.DeadlineExceeded) {
+		t.Errorf("got %v, expected context.DeadlineExceeded", e)
+	}
+}
+
+func TestFutureGetContext(t *testing.T) {
+	db := memdb.New()
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fdb.Key("a"), []byte("1"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tr, e := db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	w := tr.Watch(fdb.Key("a"))
+	if e = tr.Commit().Get(); e != nil {
+		t.Fatal(e)
+	}
+
+	// A watch that does not fire is cancelled once the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if e = w.GetContext(ctx); !errors.Is(e, context.DeadlineExceeded) {
+		t.Errorf("got %v from watch, expected context.DeadlineExceeded", e)
+	}
+	if !w.IsReady() {
+		t.Error("watch not cancelled after its context was done")
+	}
+
+	// A future that is already ready returns its value regardless.
+	tr, e = db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v, e := tr.Get(fdb.Key("a")).GetContext(ctx); e != nil || string(v) != "1" {
+		t.Errorf("got %q and %v from ready future, expected 1", v, e)
+	}
+
+	tb, e := memdb.NewBackend().CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	kvs, more, e := tb.GetRange(fdb.FirstGreaterOrEqual(fdb.Key("")), fdb.FirstGreaterOrEqual(fdb.Key("\xff")), fdb.RangeOptions{}, false, 1).GetContext(context.Background())
+	if e != nil || len(kvs) != 0 || more {
+		t.Errorf("got %v, %v and %v from range read of empty database", kvs, more, e)
+	}
+}
+
+// batchingBackend wraps a DatabaseBackend, returning range reads one key-value
+// pair at a time, and never completing a read of any batch but the first.
+type batchingBackend struct {
+	fdb.DatabaseBackend
+}
+
+func (bb batchingBackend) CreateTransaction() (fdb.TransactionBackend, error) {
+	t, e := bb.DatabaseBackend.CreateTransaction()
+	if e != nil {
+		return nil, e
+	}
+	return batchingTransaction{t}, nil
+}
+
+type batchingTransaction struct {
+	fdb.TransactionBackend
+}
+
+func (bt batchingTransaction) GetRange(begin, end fdb.KeySelector, options fdb.RangeOptions, snapshot bool, iteration int) fdb.FutureKeyValueArray {
+	if iteration > 1 {
+		return &pendingKeyValueArray{cancelled: make(chan struct{})}
+	}
+	options.Limit = 1
+	return firstBatch{bt.TransactionBackend.GetRange(begin, end, options, snapshot, iteration)}
+}
+
+// firstBatch reports that more key-value pairs remain after its own.
+type firstBatch struct {
+	fdb.FutureKeyValueArray
+}
+
+func (f firstBatch) Get() ([]fdb.KeyValue, bool, error) {
+	kvs, _, e := f.FutureKeyValueArray.Get()
+	return kvs, true, e
+}
+
+// pendingKeyValueArray is a FutureKeyValueArray that only becomes ready when
+// it is cancelled.
+type pendingKeyValueArray struct {
+	cancelled chan struct{}
+	once sync.Once
+}
+
+func (f *pendingKeyValueArray) BlockUntilReady() {
+	<-f.cancelled
+}
+
+func (f *pendingKeyValueArray) BlockUntilReadyContext(ctx context.Context) error {
+	select {
+	case <-f.cancelled:
+		return nil
+	case <-ctx.Done():
+		f.Cancel()
+		return ctx.Err()
+	}
+}
+
+func (f *pendingKeyValueArray) IsReady() bool {
+	select {
+	case <-f.cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *pendingKeyValueArray) Cancel() {
+	f.once.Do(func() { close(f.cancelled) })
+}
+
+func (f *pendingKeyValueArray) Get() ([]fdb.KeyValue, bool, error) {
+	f.BlockUntilReady()
+	return nil, false, fdb.Error{1101}
+}
+
+func (f *pendingKeyValueArray) GetContext(ctx context.Context) ([]fdb.KeyValue, bool, error) {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return nil, false, e
+	}
+	return f.Get()
+}
+
+func TestAdvanceContext(t *testing.T) {
+	db := fdb.NewDatabase(batchingBackend{memdb.NewBackend()})
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fdb.Key("a"), []byte("1"))
+		tr.Set(fdb.Key("b"), []byte("2"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tr, e := db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ri := tr.GetRange(fdb.KeyRange{Begin: fdb.Key("a"), End: fdb.Key("z")}, fdb.RangeOptions{}).Iterator()
+	if !ri.AdvanceContext(ctx) {
+		t.Fatal("got no key-value pairs from range read")
+	}
+	if kv, e := ri.Get(); e != nil || string(kv.Key) != "a" {
+		t.Fatalf("got %q and %v from first batch, expected a", kv.Key, e)
+	}
+
+	// The second batch never arrives, so the iterator stops once the context
+	// is cancelled.
+	cancel()
+	if !ri.AdvanceContext(ctx) {
+		t.Fatal("AdvanceContext returned false for an unfinished range read")
+	}
+	if _, e := ri.Get(); !errors.Is(e, context.Canceled) {
+		t.Errorf("got %v from cancelled range read, expected context.Canceled", e)
+	}
+}
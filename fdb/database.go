@@ -21,14 +21,8 @@
 
 package fdb
 
-/*
- #define FDB_API_VERSION 200
- #include <foundationdb/fdb_c.h>
-*/
-import "C"
-
 import (
-	"runtime"
+	"context"
 )
 
 // Database is a handle to a FoundationDB database. Database is a lightweight
@@ -44,9 +38,33 @@ type Database struct {
 }
 
 type database struct {
-	ptr *C.FDBDatabase
+	b DatabaseBackend
 }
 
+// A DatabaseHandle can create transactions on, and execute transactional
+// functions against, a FoundationDB database. Database satisfies the
+// DatabaseHandle interface.
+//
+// Code written against DatabaseHandle rather than Database may also be used
+// with types that wrap or mock a Database. Note, however, that a DatabaseHandle
+// still creates and runs transactional functions with the concrete Transaction
+// type, and that the directory and subspace packages accept any Transactor, so
+// a wrapper around a Database only sees the calls made directly on it. To
+// instrument or replace every operation (including those performed by the
+// directory layer), implement DatabaseBackend and TransactionBackend instead,
+// and pass the backend to NewDatabase.
+type DatabaseHandle interface {
+	CreateTransaction() (Transaction, error)
+	TransactContext(ctx context.Context, f func(Transaction) (interface{}, error)) (interface{}, error)
+	ReadTransactContext(ctx context.Context, f func(ReadTransaction) (interface{}, error)) (interface{}, error)
+	Options() DatabaseOptions
+	LocalityGetBoundaryKeys(er ExactRange, limit int, readVersion int64) ([]Key, error)
+
+	Transactor
+}
+
+var _ DatabaseHandle = Database{}
+
 // DatabaseOptions is a handle with which to set options that affect a Database
 // object. A DatabaseOptions instance should be obtained with the
 // (Database).Options method.
@@ -55,13 +73,7 @@ type DatabaseOptions struct {
 }
 
 func (opt DatabaseOptions) setOpt(code int, param []byte) error {
-	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
-		return C.fdb_database_set_option(opt.d.ptr, C.FDBDatabaseOption(code), p, pl)
-	}, param)
-}
-
-func (d *database) destroy() {
-	C.fdb_database_destroy(d.ptr)
+	return opt.d.b.SetOption(code, param)
 }
 
 // CreateTransaction returns a new FoundationDB transaction. It is generally
@@ -69,19 +81,15 @@ func (d *database) destroy() {
 // automatically creating and committing a transaction with appropriate retry
 // behavior.
 func (d Database) CreateTransaction() (Transaction, error) {
-	var outt *C.FDBTransaction
-
-	if err := C.fdb_database_create_transaction(d.ptr, &outt); err != 0 {
-		return Transaction{}, Error{int(err)}
+	tb, e := d.b.CreateTransaction()
+	if e != nil {
+		return Transaction{}, e
 	}
 
-	t := &transaction{outt, d}
-	runtime.SetFinalizer(t, (*transaction).destroy)
-
-	return Transaction{t}, nil
+	return Transaction{&transaction{tb, d}}, nil
 }
 
-func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNil) (ret interface{}, e error) {
+func retryable(ctx context.Context, wrapped func() (interface{}, error), onError func(Error) FutureNil) (ret interface{}, e error) {
 	for {
 		ret, e = wrapped()
 
@@ -90,9 +98,16 @@ func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNi
 			return
 		}
 
+		/* A done context takes precedence over whatever error it
+		/* caused (typically a cancelled transaction) */
+		if ce := ctx.Err(); ce != nil {
+			e = ce
+			return
+		}
+
 		ep, ok := e.(Error)
 		if ok {
-			e = onError(ep).Get()
+			e = onError(ep).GetContext(ctx)
 		}
 
 		/* If OnError returns an error, then it's not
@@ -103,6 +118,26 @@ func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNi
 	}
 }
 
+// cancelWhenDone cancels tr once ctx is done, until the returned function is
+// called.
+func cancelWhenDone(ctx context.Context, tr Transaction) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			tr.Cancel()
+		case <-stopped:
+		}
+	}()
+
+	return func() { close(stopped) }
+}
+
 // Transact runs a caller-provided function inside a retry loop, providing it
 // with a newly created Transaction. After the function returns, the Transaction
 // will be committed automatically. Any error during execution of the function
@@ -123,12 +158,30 @@ func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNi
 // See the Transactor interface for an example of using Transact with
 // Transaction and Database objects.
 func (d Database) Transact(f func(Transaction) (interface{}, error)) (interface{}, error) {
+	return d.TransactContext(context.Background(), f)
+}
+
+// TransactContext is like Transact, but observes the provided context. If the
+// context is done while the caller-provided function or the commit is in
+// progress, the Transaction is cancelled (causing any outstanding futures to
+// fail promptly), no further retries are attempted, and the context's error is
+// returned.
+//
+// As with (Transaction).Cancel, if the context is done while the commit is in
+// progress, the commit may or may not have been applied to the database.
+func (d Database) TransactContext(ctx context.Context, f func(Transaction) (interface{}, error)) (interface{}, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
 	tr, e := d.CreateTransaction()
 	/* Any error here is non-retryable */
 	if e != nil {
 		return nil, e
 	}
 
+	defer cancelWhenDone(ctx, tr)()
+
 	wrapped := func() (ret interface{}, e error) {
 		defer panicToError(&e)
 
@@ -141,7 +194,7 @@ func (d Database) Transact(f func(Transaction) (interface{}, error)) (interface{
 		return
 	}
 
-	return retryable(wrapped, tr.OnError)
+	return retryable(ctx, wrapped, tr.OnError)
 }
 
 // ReadTransact runs a caller-provided function inside a retry loop, providing
@@ -163,12 +216,27 @@ func (d Database) Transact(f func(Transaction) (interface{}, error)) (interface{
 // See the ReadTransactor interface for an example of using ReadTransact with
 // Transaction, Snapshot and Database objects.
 func (d Database) ReadTransact(f func(ReadTransaction) (interface{}, error)) (interface{}, error) {
+	return d.ReadTransactContext(context.Background(), f)
+}
+
+// ReadTransactContext is like ReadTransact, but observes the provided
+// context. If the context is done while the caller-provided function is in
+// progress, the Transaction is cancelled (causing any outstanding futures to
+// fail promptly), no further retries are attempted, and the context's error is
+// returned.
+func (d Database) ReadTransactContext(ctx context.Context, f func(ReadTransaction) (interface{}, error)) (interface{}, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
 	tr, e := d.CreateTransaction()
 	/* Any error here is non-retryable */
 	if e != nil {
 		return nil, e
 	}
 
+	defer cancelWhenDone(ctx, tr)()
+
 	wrapped := func() (ret interface{}, e error) {
 		defer panicToError(&e)
 
@@ -181,7 +249,7 @@ func (d Database) ReadTransact(f func(ReadTransaction) (interface{}, error)) (in
 		return
 	}
 
-	return retryable(wrapped, tr.OnError)
+	return retryable(ctx, wrapped, tr.OnError)
 }
 
 // Options returns a DatabaseOptions instance suitable for setting options
@@ -0,0 +1,70 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build cgo
+
+package fdb
+
+/*
+ #define FDB_API_VERSION 710
+ #include <foundationdb/fdb_c.h>
+*/
+import "C"
+
+import (
+	"runtime"
+)
+
+// nativeDatabase is the DatabaseBackend provided by the FoundationDB C
+// library.
+type nativeDatabase struct {
+	ptr *C.FDBDatabase
+}
+
+func newNativeDatabase(ptr *C.FDBDatabase) Database {
+	d := &nativeDatabase{ptr}
+	runtime.SetFinalizer(d, (*nativeDatabase).destroy)
+
+	return NewDatabase(d)
+}
+
+func (d *nativeDatabase) destroy() {
+	C.fdb_database_destroy(d.ptr)
+}
+
+func (d *nativeDatabase) SetOption(code int, param []byte) error {
+	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
+		return C.fdb_database_set_option(d.ptr, C.FDBDatabaseOption(code), p, pl)
+	}, param)
+}
+
+func (d *nativeDatabase) CreateTransaction() (TransactionBackend, error) {
+	var outt *C.FDBTransaction
+
+	if err := C.fdb_database_create_transaction(d.ptr, &outt); err != 0 {
+		return nil, Error{int(err)}
+	}
+
+	t := &nativeTransaction{outt}
+	runtime.SetFinalizer(t, (*nativeTransaction).destroy)
+
+	return t, nil
+}
@@ -26,25 +26,79 @@ import (
 	"github.com/FoundationDB/fdb-go/fdb/subspace"
 	"encoding/binary"
 	"bytes"
+	"errors"
+	"fmt"
 	"math/rand"
+	"sync"
 )
 
-var oneBytes = []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-
-type highContentionAllocator struct {
+// HighContentionAllocator allocates small, unique int64 values, which are
+// suitable for use as short key prefixes or identifiers. It is the allocator
+// with which the directory layer allocates directory prefixes.
+//
+// Values are allocated at random from a window of candidates, so that
+// concurrent transactions rarely allocate the same candidate and conflict. When
+// half of the window has been allocated, the window advances to the candidates
+// following it. The allocator records its state in the keys of a subspace; all
+// allocators using the same subspace allocate distinct values.
+//
+// A HighContentionAllocator is safe for concurrent use by multiple goroutines.
+type HighContentionAllocator struct {
 	counters, recent subspace.Subspace
+	windowSize func(start int64) int64
+
+	mu sync.Mutex
+	rand *rand.Rand
+	stats AllocatorStats
 }
 
-func newHCA(s subspace.Subspace) highContentionAllocator {
-	var hca highContentionAllocator
+// AllocatorOptions configure a HighContentionAllocator.
+type AllocatorOptions struct {
+	// WindowSize returns the size of the window of candidates beginning at
+	// start, which must be positive. If WindowSize is nil, DefaultWindowSize
+	// is used. Allocators using the same subspace must use the same
+	// WindowSize.
+	WindowSize func(start int64) int64
+
+	// Rand, if not nil, is the source from which candidates are chosen,
+	// allowing the values allocated to be reproduced in tests. If Rand is
+	// nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
+}
 
-	hca.counters = s.Sub(0)
-	hca.recent = s.Sub(1)
+// AllocatorStats count the work done by a HighContentionAllocator. Since
+// allocations are made in transactions that may be retried, the counts include
+// allocations made in transactions that did not commit.
+type AllocatorStats struct {
+	// Allocations is the number of values allocated.
+	Allocations int64
+
+	// Retries is the number of candidates rejected because they had already
+	// been allocated.
+	Retries int64
+
+	// WindowAdvances is the number of times the window advanced.
+	WindowAdvances int64
+}
 
+// NewHighContentionAllocator returns a HighContentionAllocator that records its
+// state in ss.
+func NewHighContentionAllocator(ss subspace.Subspace, options AllocatorOptions) *HighContentionAllocator {
+	hca := &HighContentionAllocator{
+		counters: ss.Sub(0),
+		recent: ss.Sub(1),
+		windowSize: options.WindowSize,
+		rand: options.Rand,
+	}
+	if hca.windowSize == nil {
+		hca.windowSize = DefaultWindowSize
+	}
 	return hca
 }
 
-func windowSize(start int64) int64 {
+// DefaultWindowSize is the window size used by the directory layer, which
+// grows from 64 to 8192 candidates as the values allocated grow.
+func DefaultWindowSize(start int64) int64 {
 	// Larger window sizes are better for high contention, smaller sizes for
 	// keeping the keys small.  But if there are many allocations, the keys
 	// can't be too small.  So start small and scale up.  We don't want this to
@@ -55,48 +109,212 @@ func windowSize(start int64) int64 {
 	return 8192
 }
 
-func (hca highContentionAllocator) allocate(tr fdb.Transaction, s subspace.Subspace) (subspace.Subspace, error) {
+// Allocate allocates a value that has not been allocated before by an
+// allocator using the same subspace.
+func (hca *HighContentionAllocator) Allocate(t fdb.Transactor) (int64, error) {
+	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		return hca.allocate(tr, 1)
+	})
+	if e != nil {
+		return 0, e
+	}
+	return r.([]int64)[0], nil
+}
+
+// AllocateN allocates n values in a single transaction, as if by n calls to
+// Allocate, but reading the state of the allocator only once and checking the
+// candidates in each window concurrently.
+func (hca *HighContentionAllocator) AllocateN(t fdb.Transactor, n int) ([]int64, error) {
+	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		return hca.allocate(tr, n)
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.([]int64), nil
+}
+
+// Stats returns the work done by the allocator since it was created.
+func (hca *HighContentionAllocator) Stats() AllocatorStats {
+	hca.mu.Lock()
+	defer hca.mu.Unlock()
+	return hca.stats
+}
+
+func (hca *HighContentionAllocator) window(start int64) (int64, error) {
+	window := hca.windowSize(start)
+	if window <= 0 {
+		return 0, fmt.Errorf("invalid allocator window size %d at %d", window, start)
+	}
+	return window, nil
+}
+
+func (hca *HighContentionAllocator) candidate(start, window int64) int64 {
+	hca.mu.Lock()
+	defer hca.mu.Unlock()
+	if hca.rand != nil {
+		return hca.rand.Int63n(window) + start
+	}
+	return rand.Int63n(window) + start
+}
+
+// latest returns the start of the current window and the number of values
+// allocated from it, as of the snapshot being read from.
+func (hca *HighContentionAllocator) latest(tr fdb.WriteTransaction) (start, count int64, e error) {
 	rr := tr.Snapshot().GetRange(hca.counters, fdb.RangeOptions{Limit:1, Reverse:true})
 	kvs := rr.GetSliceOrPanic()
 
-	var start, count int64
-
 	if len(kvs) == 1 {
 		t, e := hca.counters.Unpack(kvs[0].Key)
 		if e != nil {
-			return nil, e
+			return 0, 0, e
 		}
 		start = t[0].(int64)
 
 		e = binary.Read(bytes.NewBuffer(kvs[0].Value), binary.LittleEndian, &count)
 		if e != nil {
-			return nil, e
+			return 0, 0, e
 		}
 	}
 
-	window := windowSize(start)
+	return start, count, nil
+}
 
-	if (count + 1) * 2 >= window {
-		// Advance the window
-		tr.ClearRange(fdb.KeyRange{hca.counters, append(hca.counters.Sub(start).FDBKey(), 0x00)})
-		start += window
-		tr.ClearRange(fdb.KeyRange{hca.recent, hca.recent.Sub(start)})
-		window = windowSize(start)
+func (hca *HighContentionAllocator) allocate(tr fdb.WriteTransaction, n int) ([]int64, error) {
+	ret := make([]int64, 0, n)
+
+	// The state of the allocator is read again whenever another allocation in
+	// the same transaction has advanced the window
+	for len(ret) < n {
+		start, count, e := hca.latest(tr)
+		if e != nil {
+			return nil, e
+		}
+
+		window, e := hca.window(start)
+		if e != nil {
+			return nil, e
+		}
+
+		advanced := false
+		for len(ret) < n && !advanced {
+			if (count + 1) * 2 >= window {
+				// Advance the window. The recent allocations of the old window
+				// are cleared without a write conflict range, so that
+				// transactions still allocating from it do not conflict.
+				tr.ClearRange(fdb.KeyRange{Begin: hca.counters, End: append(hca.counters.Sub(start).FDBKey(), 0x00)})
+				start += window
+				if e = tr.Options().SetNextWriteNoWriteConflictRange(); e != nil {
+					return nil, e
+				}
+				tr.ClearRange(fdb.KeyRange{Begin: hca.recent, End: hca.recent.Sub(start)})
+				window, e = hca.window(start)
+				if e != nil {
+					return nil, e
+				}
+				count = 0
+
+				hca.mu.Lock()
+				hca.stats.WindowAdvances++
+				hca.mu.Unlock()
+			}
+
+			// Take as many of the remaining values from this window as it allows
+			k := 0
+			for len(ret) + k < n && (k == 0 || (count + 1) * 2 < window) {
+				count++
+				k++
+			}
+
+			// Increment the allocation count for the current window
+			delta := make([]byte, 8)
+			binary.LittleEndian.PutUint64(delta, uint64(k))
+			tr.Add(hca.counters.Sub(start), delta)
+
+			var values []int64
+			values, advanced, e = hca.pick(tr, start, window, k)
+			if e != nil {
+				return nil, e
+			}
+			ret = append(ret, values...)
+		}
 	}
 
-	// Increment the allocation count for the current window
-	tr.Add(hca.counters.Sub(start), oneBytes)
+	return ret, nil
+}
+
+// pick chooses and records k unallocated candidates from the window of size
+// window beginning at start. If the window is advanced by another allocation in
+// the same transaction, pick returns the candidates chosen so far and true.
+func (hca *HighContentionAllocator) pick(tr fdb.WriteTransaction, start, window int64, k int) ([]int64, bool, error) {
+	chosen := make(map[int64]bool)
+	ret := make([]int64, 0, k)
+	advanced := false
 
-	for {
+	for len(ret) < k && !advanced {
 		// As of the snapshot being read from, the window is less than half
 		// full, so this should be expected to take 2 tries.  Under high
 		// contention (and when the window advances), there is an additional
 		// subsequent risk of conflict for this transaction.
-		candidate := rand.Int63n(window) + start
-		key := hca.recent.Sub(candidate)
-		if tr.Get(key).MustGet() == nil {
-			tr.Set(key, []byte(""))
-			return s.Sub(candidate), nil
+		latest := tr.Snapshot().GetRange(hca.counters, fdb.RangeOptions{Limit:1, Reverse:true})
+
+		var candidates []int64
+		var futures []fdb.FutureByteSlice
+		for len(ret) + len(candidates) < k {
+			if int64(len(chosen)) >= window {
+				return nil, false, errors.New("the allocator window has no unallocated candidates")
+			}
+			candidate := hca.candidate(start, window)
+			if chosen[candidate] {
+				continue
+			}
+			chosen[candidate] = true
+			candidates = append(candidates, candidate)
+			futures = append(futures, tr.Get(hca.recent.Sub(candidate)))
 		}
+
+		// The candidates are recorded without a write conflict range, which
+		// is only added for those that turn out to be unallocated
+		values := make([][]byte, len(futures))
+		for i, f := range futures {
+			values[i] = f.MustGet()
+			if e := tr.Options().SetNextWriteNoWriteConflictRange(); e != nil {
+				return nil, false, e
+			}
+			tr.Set(hca.recent.Sub(candidates[i]), []byte(""))
+		}
+
+		if kvs := latest.GetSliceOrPanic(); len(kvs) == 1 {
+			t, e := hca.counters.Unpack(kvs[0].Key)
+			if e != nil {
+				return nil, false, e
+			}
+			if t[0].(int64) > start {
+				advanced = true
+				break
+			}
+		}
+
+		var retries int64
+		for i, v := range values {
+			if v == nil {
+				if e := tr.AddWriteConflictKey(hca.recent.Sub(candidates[i])); e != nil {
+					return nil, false, e
+				}
+				ret = append(ret, candidates[i])
+			} else {
+				retries++
+			}
+		}
+
+		hca.mu.Lock()
+		hca.stats.Retries += retries
+		hca.mu.Unlock()
 	}
+
+	hca.mu.Lock()
+	hca.stats.Allocations += int64(len(ret))
+	hca.mu.Unlock()
+
+	return ret, advanced, nil
 }
@@ -0,0 +1,96 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+)
+
+// Attributes are named values stored with a directory, such as its owner or
+// the version of the schema of its contents. Attributes are stored in the
+// metadata of the directory, so they move with the directory and are removed
+// when it is removed.
+type Attributes map[string][]byte
+
+func attributesOf(node subspace.Subspace) subspace.Subspace {
+	return node.Sub([]byte("attributes"))
+}
+
+// setAttributes records attrs in node, removing attributes with nil values.
+func setAttributes(tr fdb.WriteTransaction, node subspace.Subspace, attrs Attributes) {
+	as := attributesOf(node)
+	for name, value := range attrs {
+		if value == nil {
+			tr.Clear(as.Sub(name))
+		} else {
+			tr.Set(as.Sub(name), value)
+		}
+	}
+}
+
+func readAttributes(rtr fdb.ReadTransaction, node subspace.Subspace) (Attributes, error) {
+	as := attributesOf(node)
+
+	kvs, e := rtr.GetRange(as, fdb.RangeOptions{}).GetSliceWithError()
+	if e != nil {
+		return nil, e
+	}
+
+	attrs := make(Attributes, len(kvs))
+	for _, kv := range kvs {
+		t, e := as.Unpack(kv.Key)
+		if e != nil {
+			return nil, e
+		}
+		name, ok := t[0].(string)
+		if !ok || len(t) != 1 {
+			continue
+		}
+		attrs[name] = kv.Value
+	}
+	return attrs, nil
+}
+
+func getAttributes(rt fdb.ReadTransactor, node subspace.Subspace) (Attributes, error) {
+	r, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
+		if rtr.Get(node.Sub([]byte("layer"))).MustGet() == nil {
+			return nil, ErrDirNotExists
+		}
+		return readAttributes(rtr, node)
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.(Attributes), nil
+}
+
+func updateAttributes(t fdb.Transactor, node subspace.Subspace, attrs Attributes) error {
+	_, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		if tr.Get(node.Sub([]byte("layer"))).MustGet() == nil {
+			return nil, ErrDirNotExists
+		}
+		setAttributes(tr, node, attrs)
+		return nil, nil
+	})
+	return e
+}
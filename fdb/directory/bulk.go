@@ -0,0 +1,333 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// PathSpec describes a directory to be created or opened by CreateOrOpenAll.
+type PathSpec struct {
+	// Path is the path of the directory, relative to the root directory.
+	Path []string
+
+	// Layer is the layer of the directory. As with CreateOrOpen, an existing
+	// directory must have been created with the same layer, unless Layer is
+	// nil.
+	Layer []byte
+
+	// Prefix, if not nil, is the manually specified prefix with which to
+	// create the directory (see CreatePrefix). It is ignored if the directory
+	// already exists.
+	Prefix []byte
+
+	// Attributes are recorded as the attributes of the directory if it is
+	// created.
+	Attributes Attributes
+}
+
+// CreateOrOpenAll creates or opens each of the directories described by specs,
+// in a single transaction, and returns them by path, as formatted by
+// FormatPath.
+//
+// Directories are processed in order of the length of their paths, so a spec
+// for a directory applies even if a spec for one of its subdirectories comes
+// first. Directories that are shared by several paths are looked up only once,
+// as is the version of each directory layer, and the prefixes of the new
+// directories in each directory layer are allocated together (see
+// (*HighContentionAllocator).AllocateN).
+//
+// root must be a root directory, such as one returned by Root,
+// NewDirectoryLayer or NewCachedDirectoryLayer. If t is a Transaction rather
+// than a Database, the directories are created or opened in that transaction.
+func CreateOrOpenAll(t fdb.Transactor, root Directory, specs []PathSpec) (map[string]DirectorySubspace, error) {
+	dl, e := rootLayer(root)
+	if e != nil {
+		return nil, e
+	}
+
+	sorted := make([]PathSpec, len(specs))
+	copy(sorted, specs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Path) < len(sorted[j].Path)
+	})
+	for _, spec := range sorted {
+		if len(spec.Path) == 0 {
+			return nil, errors.New("the root directory cannot be opened")
+		}
+	}
+
+	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		p := provisioner{
+			tr: tr,
+			dirs: map[string]*provisioned{"": {dl: dl, node: dl.rootNode}},
+			read: make(map[string]bool),
+			written: make(map[string]bool),
+		}
+
+		// Directories in a new partition are created once the partition
+		// has been.
+		remaining := sorted
+		for len(remaining) > 0 {
+			var blocked []PathSpec
+			for _, spec := range remaining {
+				done, e := p.createOrOpen(spec)
+				if e != nil {
+					return nil, e
+				}
+				if !done {
+					blocked = append(blocked, spec)
+				}
+			}
+			if e := p.createPending(); e != nil {
+				return nil, e
+			}
+			remaining = blocked
+		}
+
+		if p.created {
+			dl.bumpMetadataVersion(tr)
+		}
+
+		ret := make(map[string]DirectorySubspace, len(sorted))
+		for _, spec := range sorted {
+			ret[FormatPath(spec.Path)] = p.dirs[pathKey(spec.Path)].ds
+		}
+		return ret, nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.(map[string]DirectorySubspace), nil
+}
+
+func pathKey(path []string) string {
+	return string(stringsTuple(path).Pack())
+}
+
+// provisioned is a directory created or opened by a provisioner.
+type provisioned struct {
+	// dl is the directory layer containing the directory, and node its node
+	// in dl. The node of a directory that has not yet been created is nil.
+	dl directoryLayer
+	node subspace.Subspace
+	layer []byte
+
+	ds DirectorySubspace
+
+	// parent, path, prefix and attrs describe a directory that has not yet
+	// been created. The prefix is nil until allocated.
+	parent *provisioned
+	path []string
+	prefix []byte
+	attrs Attributes
+}
+
+func (d *provisioned) isPartition() bool {
+	return bytes.Equal(d.layer, []byte("partition"))
+}
+
+// provisioner creates or opens directories for CreateOrOpenAll, remembering
+// each directory it has visited.
+type provisioner struct {
+	tr fdb.Transaction
+
+	// dirs are the directories visited, by their packed paths.
+	dirs map[string]*provisioned
+
+	// pending are the directories to be created by createPending, each after
+	// its parent.
+	pending []*provisioned
+
+	// read and written record the directory layers, by node subspace, whose
+	// versions have been checked for reading and writing.
+	read map[string]bool
+	written map[string]bool
+
+	created bool
+}
+
+// createOrOpen opens the directories on the path of spec, or adds them to the
+// directories pending creation. It returns false, without error, if the
+// directory is in a partition that has not yet been created.
+func (p *provisioner) createOrOpen(spec PathSpec) (bool, error) {
+	for i := range spec.Path {
+		final := i == len(spec.Path) - 1
+
+		if d, ok := p.dirs[pathKey(spec.Path[:i+1])]; ok {
+			if final && spec.Layer != nil && !bytes.Equal(d.layer, spec.Layer) {
+				return false, ErrIncompatibleLayer
+			}
+			continue
+		}
+
+		parent := p.dirs[pathKey(spec.Path[:i])]
+		if parent.node == nil && parent.isPartition() {
+			return false, nil
+		}
+
+		var d *provisioned
+		var e error
+		if final {
+			d, e = p.child(parent, spec.Path[:i+1], spec.Layer, spec.Prefix, spec.Attributes)
+		} else {
+			d, e = p.child(parent, spec.Path[:i+1], nil, nil, nil)
+		}
+		if e != nil {
+			return false, e
+		}
+		p.dirs[pathKey(spec.Path[:i+1])] = d
+	}
+	return true, nil
+}
+
+// child opens the directory at path, whose parent directory is parent, or adds
+// it to the directories pending creation.
+func (p *provisioner) child(parent *provisioned, path []string, layer, prefix []byte, attrs Attributes) (*provisioned, error) {
+	dl, parentNode := parent.dl, parent.node
+	if parent.isPartition() {
+		dl = parent.ds.(directoryPartition).directoryLayer
+		parentNode = dl.rootNode
+	}
+
+	if !p.read[string(dl.nodeSS.Bytes())] {
+		if e := dl.checkVersion(p.tr, nil); e != nil {
+			return nil, e
+		}
+		p.read[string(dl.nodeSS.Bytes())] = true
+	}
+
+	// The subdirectories of a directory pending creation do not exist.
+	if parentNode != nil {
+		if existing := p.tr.Get(parentNode.Sub(_SUBDIRS, path[len(path)-1])).MustGet(); existing != nil {
+			node := dl.nodeWithPrefix(existing)
+			existingLayer := p.tr.Get(node.Sub([]byte("layer"))).MustGet()
+			if layer != nil && !bytes.Equal(existingLayer, layer) {
+				return nil, ErrIncompatibleLayer
+			}
+			ds, e := dl.contentsOfNode(node, path[len(dl.path):], existingLayer)
+			if e != nil {
+				return nil, e
+			}
+			return &provisioned{dl: dl, node: node, layer: existingLayer, ds: ds}, nil
+		}
+	}
+
+	if e := validatePath(path); e != nil {
+		return nil, e
+	}
+
+	if prefix != nil && !dl.allowManualPrefixes {
+		if len(dl.path) == 0 {
+			return nil, errors.New("cannot specify a prefix unless manual prefixes are enabled")
+		} else {
+			return nil, errors.New("cannot specify a prefix in a partition")
+		}
+	}
+
+	if !p.written[string(dl.nodeSS.Bytes())] {
+		if e := dl.checkVersion(p.tr, p.tr); e != nil {
+			return nil, e
+		}
+		p.written[string(dl.nodeSS.Bytes())] = true
+	}
+
+	if layer == nil {
+		layer = []byte{}
+	}
+
+	d := &provisioned{dl: dl, layer: layer, parent: parent, path: path, prefix: prefix, attrs: attrs}
+	p.pending = append(p.pending, d)
+	return d, nil
+}
+
+// createPending creates the directories pending creation, allocating their
+// prefixes in a batch for each directory layer.
+func (p *provisioner) createPending() error {
+	var layers []string
+	batches := make(map[string][]*provisioned)
+	allocated := make(map[*provisioned]bool)
+	for _, d := range p.pending {
+		if d.prefix != nil {
+			continue
+		}
+		key := string(d.dl.nodeSS.Bytes())
+		if _, ok := batches[key]; !ok {
+			layers = append(layers, key)
+		}
+		batches[key] = append(batches[key], d)
+		allocated[d] = true
+	}
+
+	for _, key := range layers {
+		batch := batches[key]
+		prefixes, e := batch[0].dl.allocatePrefixes(p.tr, p.tr, len(batch))
+		if e != nil {
+			return e
+		}
+		for i, d := range batch {
+			d.prefix = prefixes[i]
+		}
+	}
+
+	for _, d := range p.pending {
+		// Each prefix is checked as its directory is created, and so also
+		// against the prefixes of the directories created before it.
+		if allocated[d] {
+			pf, e := d.dl.isPrefixFree(p.tr.Snapshot(), d.prefix)
+			if e != nil { return e }
+			if !pf {
+				return errors.New("the directory layer has manually allocated prefixes that conflict with the automatic prefix allocator")
+			}
+		} else {
+			pf, e := d.dl.isPrefixFree(p.tr, d.prefix)
+			if e != nil { return e }
+			if !pf {
+				return errors.New("the given prefix is already in use")
+			}
+		}
+
+		parentNode := d.parent.node
+		if d.parent.isPartition() {
+			parentNode = d.dl.rootNode
+		}
+
+		d.node = d.dl.nodeWithPrefix(d.prefix)
+		p.tr.Set(parentNode.Sub(_SUBDIRS, d.path[len(d.path)-1]), d.prefix)
+		p.tr.Set(d.node.Sub([]byte("layer")), d.layer)
+		setAttributes(p.tr, d.node, d.attrs)
+
+		ds, e := d.dl.contentsOfNode(d.node, d.path[len(d.dl.path):], d.layer)
+		if e != nil {
+			return e
+		}
+		d.ds = ds
+		p.created = true
+	}
+
+	p.pending = nil
+	return nil
+}
@@ -0,0 +1,161 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"sync"
+)
+
+type cachedDirectoryLayer struct {
+	directoryLayer
+	cache *directoryCache
+}
+
+// directoryCache holds the directories opened at a single metadata version.
+type directoryCache struct {
+	mu sync.Mutex
+	version []byte
+	dirs map[string]DirectorySubspace
+}
+
+// NewCachedDirectoryLayer returns a new root directory (as a Directory), like
+// NewDirectoryLayer, that remembers the directories it has opened. Open and
+// CreateOrOpen on the returned Directory read a single key (the metadata
+// version of the directory layer) when the directory at path has been opened
+// before, rather than reading each component of path.
+//
+// Every transaction that creates, moves or removes a directory changes the
+// metadata version, which discards all remembered directories. Since every
+// transaction that uses a remembered directory reads the metadata version, it
+// will conflict with (and be retried after) any concurrent transaction that
+// changes the directories. Within a transaction that has itself changed the
+// directories, Open and CreateOrOpen read the directories without using the
+// cache.
+//
+// The cache is only invalidated by directory layers in this package: if other
+// clients modify the same directories, they must not use a cached directory
+// layer. Only Open and CreateOrOpen on the returned root directory use the
+// cache; the DirectorySubspaces they return are not themselves cached.
+func NewCachedDirectoryLayer(nodeSS, contentSS subspace.Subspace, allowManualPrefixes bool) Directory {
+	dl := NewDirectoryLayer(nodeSS, contentSS, allowManualPrefixes).(directoryLayer)
+	return cachedDirectoryLayer{dl, &directoryCache{}}
+}
+
+func cacheKey(path []string) string {
+	t := make(tuple.Tuple, len(path))
+	for i, name := range path {
+		t[i] = name
+	}
+	return string(t.Pack())
+}
+
+func (c *directoryCache) get(version []byte, path []string) (DirectorySubspace, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dirs == nil || !bytes.Equal(c.version, version) {
+		return nil, false
+	}
+	ds, ok := c.dirs[cacheKey(path)]
+	return ds, ok
+}
+
+func (c *directoryCache) put(version []byte, path []string, ds DirectorySubspace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dirs == nil || !bytes.Equal(c.version, version) {
+		// Versionstamps increase, so a directory read at an older version than
+		// those already cached is simply dropped.
+		if c.dirs != nil && bytes.Compare(version, c.version) < 0 {
+			return
+		}
+		c.version = version
+		c.dirs = make(map[string]DirectorySubspace)
+	}
+	c.dirs[cacheKey(path)] = ds
+}
+
+// createOrOpen is like (directoryLayer).createOrOpen without a prefix, but
+// consults and fills the cache.
+func (cdl cachedDirectoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr fdb.WriteTransaction, path []string, layer []byte, attrs Attributes, allowCreate bool) (DirectorySubspace, error) {
+	version, e := rtr.Get(cdl.metadataVersion).Get()
+	if e != nil {
+		if fe, ok := e.(fdb.Error); ok && fe.Code == 1036 {
+			// The directories have been changed in this transaction.
+			return cdl.directoryLayer.createOrOpen(rtr, tr, path, layer, nil, attrs, allowCreate, true)
+		}
+		return nil, e
+	}
+
+	if ds, ok := cdl.cache.get(version, path); ok {
+		if layer != nil && bytes.Compare(ds.GetLayer(), layer) != 0 {
+			return nil, ErrIncompatibleLayer
+		}
+		return ds, nil
+	}
+
+	ds, e := cdl.directoryLayer.createOrOpen(rtr, tr, path, layer, nil, attrs, allowCreate, true)
+	if e != nil {
+		return nil, e
+	}
+
+	if tr != nil {
+		// If the directory was just created, the metadata version is no
+		// longer readable, and the directory must not be cached until this
+		// transaction has committed.
+		if _, e := rtr.Get(cdl.metadataVersion).Get(); e != nil {
+			return ds, nil
+		}
+	}
+
+	cdl.cache.put(version, path, ds)
+	return ds, nil
+}
+
+func (cdl cachedDirectoryLayer) CreateOrOpen(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return cdl.CreateOrOpenWithAttributes(t, path, layer, nil)
+}
+
+func (cdl cachedDirectoryLayer) CreateOrOpenWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error) {
+	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		return cdl.createOrOpen(tr, tr, path, layer, attrs, true)
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.(DirectorySubspace), nil
+}
+
+func (cdl cachedDirectoryLayer) Open(rt fdb.ReadTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	r, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
+		return cdl.createOrOpen(rtr, nil, path, layer, nil, false)
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.(DirectorySubspace), nil
+}
@@ -0,0 +1,586 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IssueKind identifies the kind of an inconsistency found by Check.
+type IssueKind int
+
+const (
+	// InvalidMetadata is a key in the node subspace of a directory layer that
+	// the directory layer could not have written.
+	InvalidMetadata IssueKind = iota
+
+	// DanglingSubdirectory is an entry naming a subdirectory whose node (and
+	// so layer) does not exist. Repair removes the entry.
+	DanglingSubdirectory
+
+	// OrphanedNode is the metadata of a directory that is not a subdirectory
+	// of any directory. Repair removes the metadata, but not the contents of
+	// the directory (which are reported as UnownedContent).
+	OrphanedNode
+
+	// OverlappingPrefixes is a pair of directories whose prefixes are equal
+	// or of which one is a prefix of the other, or a directory whose prefix
+	// overlaps the node subspace of its directory layer.
+	OverlappingPrefixes
+
+	// UnownedContent is a key in the content subspace of a directory layer
+	// that is not in any directory.
+	UnownedContent
+
+	// UnrecordedPrefix is an automatically allocated directory prefix that
+	// the high contention allocator has not recorded as allocated, and so
+	// might allocate again. Repair records it.
+	UnrecordedPrefix
+
+	// StaleAllocatorCounter is an allocation counter of the high contention
+	// allocator for a window before the current one. Repair removes it.
+	StaleAllocatorCounter
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case InvalidMetadata:
+		return "invalid metadata"
+	case DanglingSubdirectory:
+		return "dangling subdirectory"
+	case OrphanedNode:
+		return "orphaned node"
+	case OverlappingPrefixes:
+		return "overlapping prefixes"
+	case UnownedContent:
+		return "unowned content"
+	case UnrecordedPrefix:
+		return "unrecorded prefix"
+	case StaleAllocatorCounter:
+		return "stale allocator counter"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// Issue describes an inconsistency found by Check.
+type Issue struct {
+	Kind IssueKind
+
+	// Path is the absolute path of the affected directory, if known. For a
+	// DanglingSubdirectory, it is the path named by the entry.
+	Path []string
+
+	// OtherPath is the absolute path of the second directory of an
+	// OverlappingPrefixes issue, or nil if the prefix of the directory at Path
+	// overlaps the node subspace.
+	OtherPath []string
+
+	// Prefix is the prefix of the affected directory, if any.
+	Prefix []byte
+
+	// Key is the affected key: the invalid or dangling metadata key, the
+	// first unowned content key or the stale counter.
+	Key fdb.Key
+
+	// Repaired is true if the issue was repaired by Repair.
+	Repaired bool
+}
+
+func formatIssuePath(path []string) string {
+	return "/" + strings.Join(path, "/")
+}
+
+func (i Issue) String() string {
+	var s string
+	switch i.Kind {
+	case InvalidMetadata:
+		s = fmt.Sprintf("invalid directory metadata at key %x", []byte(i.Key))
+	case DanglingSubdirectory:
+		s = fmt.Sprintf("directory %s refers to missing node for prefix %x", formatIssuePath(i.Path), i.Prefix)
+	case OrphanedNode:
+		s = fmt.Sprintf("node for prefix %x is not in any directory", i.Prefix)
+	case OverlappingPrefixes:
+		if i.OtherPath == nil {
+			s = fmt.Sprintf("prefix %x of directory %s overlaps the directory metadata", i.Prefix, formatIssuePath(i.Path))
+		} else {
+			s = fmt.Sprintf("prefix %x of directory %s overlaps the prefix of directory %s", i.Prefix, formatIssuePath(i.OtherPath), formatIssuePath(i.Path))
+		}
+	case UnownedContent:
+		s = fmt.Sprintf("key %x is not in any directory", []byte(i.Key))
+	case UnrecordedPrefix:
+		s = fmt.Sprintf("prefix %x of directory %s is not recorded by the allocator", i.Prefix, formatIssuePath(i.Path))
+	case StaleAllocatorCounter:
+		s = fmt.Sprintf("stale allocator counter at key %x", []byte(i.Key))
+	default:
+		s = i.Kind.String()
+	}
+	if i.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// Check verifies the consistency of the metadata of the directories in root
+// (which must be a root directory, as returned by Root, NewDirectoryLayer or
+// NewCachedDirectoryLayer), including the contents of any directory
+// partitions, and returns every inconsistency found. Check reads every key of
+// the node subspace and high contention allocator of each directory layer, and
+// checks that each key of its content subspace is in some directory.
+//
+// The check is performed in a single transaction, and so is limited to
+// directory layers whose metadata can be read within the five second lifetime
+// of a transaction.
+func Check(rt fdb.ReadTransactor, root Directory) ([]Issue, error) {
+	dl, e := rootLayer(root)
+	if e != nil {
+		return nil, e
+	}
+
+	r, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
+		c := checker{rtr: rtr}
+		if e := c.checkLayer(dl); e != nil {
+			return nil, e
+		}
+		return c.issues, nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.([]Issue), nil
+}
+
+// Repair is like Check, but also repairs those issues that can be repaired
+// without removing the contents of a directory, in the same transaction. Each
+// issue returned reports whether it was repaired; OverlappingPrefixes,
+// UnownedContent and InvalidMetadata must be resolved by hand.
+func Repair(t fdb.Transactor, root Directory) ([]Issue, error) {
+	dl, e := rootLayer(root)
+	if e != nil {
+		return nil, e
+	}
+
+	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		c := checker{rtr: tr, tr: tr}
+		if e := c.checkLayer(dl); e != nil {
+			return nil, e
+		}
+		if c.repaired {
+			dl.bumpMetadataVersion(tr)
+		}
+		return c.issues, nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.([]Issue), nil
+}
+
+func rootLayer(root Directory) (directoryLayer, error) {
+	switch d := root.(type) {
+	case directoryLayer:
+		return d, nil
+	case cachedDirectoryLayer:
+		return d.directoryLayer, nil
+	}
+	return directoryLayer{}, errors.New("the directory is not a root directory")
+}
+
+type checker struct {
+	rtr fdb.ReadTransaction
+	tr fdb.WriteTransaction
+	issues []Issue
+	repaired bool
+}
+
+// report records an issue, repairing it with fix if repairing and fix is not
+// nil.
+func (c *checker) report(i Issue, fix func(tr fdb.WriteTransaction)) {
+	if c.tr != nil && fix != nil {
+		fix(c.tr)
+		i.Repaired = true
+		c.repaired = true
+	}
+	c.issues = append(c.issues, i)
+}
+
+// checkedNode is the metadata of a node read from the node subspace.
+type checkedNode struct {
+	layer []byte
+	hasLayer bool
+	subdirs []checkedSubdir
+	reachable bool
+}
+
+type checkedSubdir struct {
+	name string
+	prefix []byte
+	key fdb.Key
+}
+
+type checkedDirectory struct {
+	path []string
+	prefix []byte
+}
+
+func (c *checker) checkLayer(dl directoryLayer) error {
+	nodes, order, e := c.readNodes(dl)
+	if e != nil {
+		return e
+	}
+
+	rootPrefix := dl.nodeSS.Bytes()
+	root, ok := nodes[string(rootPrefix)]
+	if !ok {
+		root = &checkedNode{}
+		nodes[string(rootPrefix)] = root
+	}
+	root.reachable = true
+
+	// Visit the directories, breadth first from the root node.
+	type pending struct {
+		n *checkedNode
+		path []string
+	}
+	var dirs []checkedDirectory
+	var partitions []checkedDirectory
+	owner := make(map[string][]string)
+	queue := []pending{{root, nil}}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		for _, sd := range p.n.subdirs {
+			path := make([]string, len(p.path) + 1)
+			copy(path, p.path)
+			path[len(p.path)] = sd.name
+
+			abs := make([]string, len(dl.path) + len(path))
+			copy(abs, dl.path)
+			copy(abs[len(dl.path):], path)
+
+			child, ok := nodes[string(sd.prefix)]
+			if !ok || !child.hasLayer {
+				key := sd.key
+				c.report(Issue{Kind: DanglingSubdirectory, Path: abs, Prefix: sd.prefix, Key: key}, func(tr fdb.WriteTransaction) {
+					tr.Clear(key)
+				})
+				continue
+			}
+
+			if other, ok := owner[string(sd.prefix)]; ok {
+				c.report(Issue{Kind: OverlappingPrefixes, Path: abs, OtherPath: other, Prefix: sd.prefix}, nil)
+				continue
+			}
+			owner[string(sd.prefix)] = abs
+
+			child.reachable = true
+			dirs = append(dirs, checkedDirectory{abs, sd.prefix})
+			if bytes.Compare(child.layer, []byte("partition")) == 0 {
+				partitions = append(partitions, checkedDirectory{abs, sd.prefix})
+			} else {
+				queue = append(queue, pending{child, path})
+			}
+		}
+	}
+
+	for _, prefix := range order {
+		if n := nodes[prefix]; !n.reachable {
+			node := dl.nodeWithPrefix([]byte(prefix))
+			c.report(Issue{Kind: OrphanedNode, Prefix: []byte(prefix)}, func(tr fdb.WriteTransaction) {
+				tr.ClearRange(node)
+			})
+		}
+	}
+
+	c.checkPrefixes(dl, dirs)
+
+	if e = c.checkContent(dl, dirs); e != nil {
+		return e
+	}
+
+	if e = c.checkAllocator(dl, dirs); e != nil {
+		return e
+	}
+
+	for _, p := range partitions {
+		ds, e := dl.contentsOfNode(dl.nodeWithPrefix(p.prefix), p.path[len(dl.path):], []byte("partition"))
+		if e != nil {
+			return e
+		}
+		if e = c.checkLayer(ds.(directoryPartition).directoryLayer); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// readNodes reads the node subspace of dl, returning its nodes by prefix and
+// the prefixes in the order read.
+func (c *checker) readNodes(dl directoryLayer) (map[string]*checkedNode, []string, error) {
+	nodes := make(map[string]*checkedNode)
+	var order []string
+
+	kvs, e := c.rtr.GetRange(dl.nodeSS, fdb.RangeOptions{}).GetSliceWithError()
+	if e != nil {
+		return nil, nil, e
+	}
+
+	rootPrefix := dl.nodeSS.Bytes()
+
+	for _, kv := range kvs {
+		t, e := dl.nodeSS.Unpack(kv.Key)
+		if e != nil || len(t) < 2 {
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			continue
+		}
+		prefix, ok := t[0].([]byte)
+		if !ok {
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			continue
+		}
+
+		n, ok := nodes[string(prefix)]
+		if !ok {
+			n = &checkedNode{}
+			nodes[string(prefix)] = n
+			order = append(order, string(prefix))
+		}
+
+		switch {
+		case len(t) == 2 && string(asBytes(t[1])) == "layer":
+			n.layer = kv.Value
+			n.hasLayer = true
+		case len(t) == 3 && t[1] == int64(_SUBDIRS):
+			name, ok := t[2].(string)
+			if !ok {
+				c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+				continue
+			}
+			n.subdirs = append(n.subdirs, checkedSubdir{name, kv.Value, kv.Key})
+		case len(t) == 3 && string(asBytes(t[1])) == "attributes":
+			if _, ok := t[2].(string); !ok {
+				c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			}
+		case bytes.Equal(prefix, rootPrefix) && isRootMetadata(t[1]):
+		default:
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+		}
+	}
+
+	return nodes, order, nil
+}
+
+func asBytes(el interface{}) []byte {
+	b, _ := el.([]byte)
+	return b
+}
+
+// isRootMetadata reports whether el names metadata stored only in the root
+// node of a directory layer.
+func isRootMetadata(el interface{}) bool {
+	switch string(asBytes(el)) {
+	case "version", "hca", "metadataVersion", "move":
+		return true
+	}
+	return false
+}
+
+// checkPrefixes reports directories whose prefixes overlap each other or the
+// node subspace of dl.
+func (c *checker) checkPrefixes(dl directoryLayer, dirs []checkedDirectory) {
+	sorted := make([]checkedDirectory, len(dirs))
+	copy(sorted, dirs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].prefix, sorted[j].prefix) < 0
+	})
+
+	nodePrefix := dl.nodeSS.Bytes()
+
+	var stack []checkedDirectory
+	for _, d := range sorted {
+		for len(stack) > 0 && !bytes.HasPrefix(d.prefix, stack[len(stack)-1].prefix) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			c.report(Issue{Kind: OverlappingPrefixes, Path: d.path, OtherPath: stack[len(stack)-1].path, Prefix: stack[len(stack)-1].prefix}, nil)
+		}
+		stack = append(stack, d)
+
+		if bytes.HasPrefix(d.prefix, nodePrefix) || bytes.HasPrefix(nodePrefix, d.prefix) {
+			c.report(Issue{Kind: OverlappingPrefixes, Path: d.path, Prefix: d.prefix}, nil)
+		}
+	}
+}
+
+// checkContent reports the first key of each range of the content subspace of
+// dl that is not in a directory (or the node subspace).
+func (c *checker) checkContent(dl directoryLayer, dirs []checkedDirectory) error {
+	var owned []fdb.KeyRange
+	for _, prefix := range append([][]byte{dl.nodeSS.Bytes()}, prefixesOf(dirs)...) {
+		kr, e := fdb.PrefixRange(prefix)
+		if e != nil {
+			continue
+		}
+		owned = append(owned, kr)
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return bytes.Compare(owned[i].Begin.FDBKey(), owned[j].Begin.FDBKey()) < 0
+	})
+
+	begin := dl.contentSS.Bytes()
+	end, e := fdb.PrefixRange(begin)
+	var contentEnd fdb.Key
+	if e != nil {
+		contentEnd = fdb.Key{0xFF}
+	} else {
+		contentEnd = end.End.FDBKey()
+	}
+
+	cursor := fdb.Key(begin)
+	gaps := []fdb.KeyRange{}
+	for _, kr := range owned {
+		if b := kr.Begin.FDBKey(); bytes.Compare(cursor, b) < 0 {
+			gaps = append(gaps, fdb.KeyRange{Begin: cursor, End: minKey(b, contentEnd)})
+		}
+		if e := kr.End.FDBKey(); bytes.Compare(cursor, e) < 0 {
+			cursor = e
+		}
+	}
+	gaps = append(gaps, fdb.KeyRange{Begin: cursor, End: contentEnd})
+
+	for _, gap := range gaps {
+		if bytes.Compare(gap.Begin.FDBKey(), gap.End.FDBKey()) >= 0 {
+			continue
+		}
+		kvs, e := c.rtr.GetRange(gap, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+		if e != nil {
+			return e
+		}
+		if len(kvs) > 0 {
+			c.report(Issue{Kind: UnownedContent, Key: kvs[0].Key}, nil)
+		}
+	}
+
+	return nil
+}
+
+func prefixesOf(dirs []checkedDirectory) [][]byte {
+	prefixes := make([][]byte, len(dirs))
+	for i, d := range dirs {
+		prefixes[i] = d.prefix
+	}
+	return prefixes
+}
+
+func minKey(a, b fdb.Key) fdb.Key {
+	if bytes.Compare(a, b) < 0 {
+		return a
+	}
+	return b
+}
+
+// checkAllocator reports stale counters of the high contention allocator of
+// dl, and allocated prefixes in its current window that it has not recorded.
+func (c *checker) checkAllocator(dl directoryLayer, dirs []checkedDirectory) error {
+	hca := dl.allocator
+
+	counters, e := c.rtr.GetRange(hca.counters, fdb.RangeOptions{}).GetSliceWithError()
+	if e != nil {
+		return e
+	}
+
+	var start int64
+	for i, kv := range counters {
+		t, e := hca.counters.Unpack(kv.Key)
+		if e != nil || len(t) != 1 || len(kv.Value) != 8 {
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			continue
+		}
+		s, ok := t[0].(int64)
+		if !ok {
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			continue
+		}
+		if i < len(counters) - 1 {
+			key := kv.Key
+			c.report(Issue{Kind: StaleAllocatorCounter, Key: key}, func(tr fdb.WriteTransaction) {
+				tr.Clear(key)
+			})
+			continue
+		}
+		start = s
+	}
+
+	recent, e := c.rtr.GetRange(hca.recent, fdb.RangeOptions{}).GetSliceWithError()
+	if e != nil {
+		return e
+	}
+	recorded := make(map[int64]bool)
+	for _, kv := range recent {
+		t, e := hca.recent.Unpack(kv.Key)
+		if e != nil || len(t) != 1 {
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			continue
+		}
+		candidate, ok := t[0].(int64)
+		if !ok {
+			c.report(Issue{Kind: InvalidMetadata, Key: kv.Key}, nil)
+			continue
+		}
+		recorded[candidate] = true
+	}
+
+	for _, d := range dirs {
+		candidate, ok := allocatedCandidate(dl.contentSS, d.prefix)
+		if !ok || candidate < start || recorded[candidate] {
+			continue
+		}
+		key := hca.recent.Sub(candidate)
+		c.report(Issue{Kind: UnrecordedPrefix, Path: d.path, Prefix: d.prefix}, func(tr fdb.WriteTransaction) {
+			tr.Set(key, []byte(""))
+		})
+	}
+
+	return nil
+}
+
+// allocatedCandidate returns the candidate from which the high contention
+// allocator would have allocated prefix, if any.
+func allocatedCandidate(contentSS subspace.Subspace, prefix []byte) (int64, bool) {
+	if !contentSS.Contains(fdb.Key(prefix)) {
+		return 0, false
+	}
+	t, e := contentSS.Unpack(fdb.Key(prefix))
+	if e != nil || len(t) != 1 {
+		return 0, false
+	}
+	candidate, ok := t[0].(int64)
+	return candidate, ok
+}
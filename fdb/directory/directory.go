@@ -36,12 +36,22 @@
 //
 // Directory operations are transactional. A byte slice layer option is used as
 // a metadata identifier when opening a directory.
+//
+// Every transaction that creates, moves or removes a directory (or that Repair
+// uses to fix its metadata), in the default root directory or in one returned
+// by NewDirectoryLayer, also sets a metadata version key stored with the
+// metadata of its directory layer (for the default root directory, the key
+// "\xfe\x01\xfe\x00\x01metadataVersion\x00") to the versionstamp of the
+// transaction. Cached directory layers (see
+// NewCachedDirectoryLayer) read the key to detect changes. Since the key is set
+// with a versionstamped atomic operation, it does not cause such transactions to
+// conflict with one another, but it cannot be read later in the same
+// transaction: doing so fails with accessed_unreadable (1036).
 package directory
 
 import (
 	"github.com/FoundationDB/fdb-go/fdb"
 	"github.com/FoundationDB/fdb-go/fdb/subspace"
-	"errors"
 )
 
 const (
@@ -64,26 +74,39 @@ type Directory interface {
 	// If the byte slice layer is specified and the directory is new, it is
 	// recorded as the layer; if layer is specified and the directory already
 	// exists, it is compared against the layer specified when the directory was
-	// created, and an error is returned if they differ.
+	// created, and ErrIncompatibleLayer is returned if they differ.
+	//
+	// A directory is not created if a component of its path is empty or not
+	// valid UTF-8; instead, an error matching ErrInvalidPath is returned.
 	CreateOrOpen(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error)
 
 	// Open opens the directory specified by path (relative to this Directory),
-	// and returns the directory and its contents as a DirectorySubspace (or an
-	// error if the directory does not exist).
+	// and returns the directory and its contents as a DirectorySubspace (or
+	// ErrDirNotExists if the directory does not exist).
 	//
 	// If the byte slice layer is specified, it is compared against the layer
-	// specified when the directory was created, and an error is returned if
-	// they differ.
+	// specified when the directory was created, and ErrIncompatibleLayer is
+	// returned if they differ.
 	Open(rt fdb.ReadTransactor, path []string, layer []byte) (DirectorySubspace, error)
 
 	// Create creates a directory specified by path (relative to this
 	// Directory), and returns the directory and its contents as a
-	// DirectorySubspace (or an error if the directory already exists).
+	// DirectorySubspace (or ErrDirAlreadyExists if the directory already
+	// exists).
 	//
 	// If the byte slice layer is specified, it is recorded as the layer and
 	// will be checked when opening the directory in the future.
 	Create(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error)
 
+	// CreateOrOpenWithAttributes behaves like CreateOrOpen, but if the
+	// directory is new, also records attrs as its attributes. The attributes
+	// of an existing directory are not changed.
+	CreateOrOpenWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error)
+
+	// CreateWithAttributes behaves like Create, but also records attrs as the
+	// attributes of the new directory.
+	CreateWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error)
+
 	// CreatePrefix behaves like Create, but uses a manually specified byte
 	// slice prefix to physically store the contents of this directory, rather
 	// than an automatically allocated prefix.
@@ -95,9 +118,12 @@ type Directory interface {
 
 	// Move moves the directory at oldPath to newPath (both relative to this
 	// Directory), and returns the directory (at its new location) and its
-	// contents as a DirectorySubspace. Move will return an error if a directory
-	// does not exist at oldPath, a directory already exists at newPath, or the
-	// parent directory of newPath does not exist.
+	// contents as a DirectorySubspace. Move will return an error matching
+	// ErrDirNotExists if a directory does not exist at oldPath or the parent
+	// directory of newPath does not exist, one matching ErrDirAlreadyExists if
+	// a directory already exists at newPath, one matching ErrInvalidPath if a
+	// component of newPath is empty or not valid UTF-8, and ErrPartitionMove if
+	// oldPath and newPath are not in the same directory partition.
 	//
 	// There is no effect on the physical prefix of the given directory or on
 	// clients that already have the directory open.
@@ -131,6 +157,12 @@ type Directory interface {
 	// is the name of the last component of a subdirectory's path.
 	List(rt fdb.ReadTransactor, path []string) ([]string, error)
 
+	// ListDetailed returns the immediate subdirectories of the directory at
+	// path (relative to this Directory), in the order of their names, with
+	// the prefix and layer of each. Only the subdirectories selected by
+	// options are read, so large directories may be listed a page at a time.
+	ListDetailed(rt fdb.ReadTransactor, path []string, options ListOptions) ([]Subdirectory, error)
+
 	// GetLayer returns the layer specified when this Directory was created.
 	GetLayer() []byte
 
@@ -153,8 +185,8 @@ func stringsEqual(a, b []string) bool {
 func moveTo(t fdb.Transactor, dl directoryLayer, path, newAbsolutePath []string) (DirectorySubspace, error) {
 	partition_len := len(dl.path)
 
-	if !stringsEqual(newAbsolutePath[:partition_len], dl.path) {
-		return nil, errors.New("cannot move between partitions")
+	if len(newAbsolutePath) < partition_len || !stringsEqual(newAbsolutePath[:partition_len], dl.path) {
+		return nil, ErrPartitionMove
 	}
 
 	return dl.Move(t, path[partition_len:], newAbsolutePath[partition_len:])
@@ -221,6 +253,13 @@ func List(rt fdb.ReadTransactor, path []string) ([]string, error) {
 	return root.List(rt, path)
 }
 
+// ListDetailed returns the immediate subdirectories of the directory at path
+// (relative to the default root directory) selected by options, with the
+// prefix and layer of each.
+func ListDetailed(rt fdb.ReadTransactor, path []string, options ListOptions) ([]Subdirectory, error) {
+	return root.ListDetailed(rt, path, options)
+}
+
 // Root returns the default root directory. Any attempt to move or remove the
 // root directory will return an error.
 //
@@ -233,8 +272,8 @@ func List(rt fdb.ReadTransactor, path []string) ([]string, error) {
 // where metadata and keys are stored.
 //
 // As an alternative to Root, you may use the package-level functions
-// CreateOrOpen, Open, Create, CreatePrefix, Move, Exists and List to operate
-// directly on the default DirectoryLayer.
+// CreateOrOpen, Open, Create, CreatePrefix, Move, Exists, List and ListDetailed
+// to operate directly on the default DirectoryLayer.
 func Root() Directory {
 	return root
 }
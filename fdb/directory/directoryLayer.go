@@ -37,9 +37,14 @@ type directoryLayer struct {
 
 	allowManualPrefixes bool
 
-	allocator highContentionAllocator
+	allocator *HighContentionAllocator
 	rootNode subspace.Subspace
 
+	// metadataVersion is the key changed (to a new versionstamp) by every
+	// transaction that creates, moves or removes a directory. Partitions share
+	// the key of the directory layer containing them.
+	metadataVersion fdb.Key
+
 	path []string
 }
 
@@ -55,6 +60,10 @@ type directoryLayer struct {
 // Directory (or any subdirectories) will fail, and all directory prefixes will
 // be automatically allocated. The default root directory does not allow manual
 // prefixes.
+//
+// Like the default root directory, the returned Directory records each change
+// to its directories in a metadata version key within nodeSS, as described in
+// the package documentation.
 func NewDirectoryLayer(nodeSS, contentSS subspace.Subspace, allowManualPrefixes bool) Directory {
 	var dl directoryLayer
 
@@ -64,12 +73,13 @@ func NewDirectoryLayer(nodeSS, contentSS subspace.Subspace, allowManualPrefixes
 	dl.allowManualPrefixes = allowManualPrefixes
 
 	dl.rootNode = dl.nodeSS.Sub(dl.nodeSS.Bytes())
-	dl.allocator = newHCA(dl.rootNode.Sub([]byte("hca")))
+	dl.allocator = NewHighContentionAllocator(dl.rootNode.Sub([]byte("hca")), AllocatorOptions{})
+	dl.metadataVersion = dl.rootNode.Sub([]byte("metadataVersion")).FDBKey()
 
 	return dl
 }
 
-func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transaction, path []string, layer []byte, prefix []byte, allowCreate, allowOpen bool) (DirectorySubspace, error) {
+func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr fdb.WriteTransaction, path []string, layer []byte, prefix []byte, attrs Attributes, allowCreate, allowOpen bool) (DirectorySubspace, error) {
 	if e := dl.checkVersion(rtr, nil); e != nil {
 		return nil, e
 	}
@@ -94,57 +104,41 @@ func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transacti
 			if e != nil {
 				return nil, e
 			}
-			return enc.(directoryPartition).createOrOpen(rtr, tr, subpath, layer, prefix, allowCreate, allowOpen)
+			return enc.(directoryPartition).createOrOpen(rtr, tr, subpath, layer, prefix, attrs, allowCreate, allowOpen)
 		}
 
 		if !allowOpen {
-			return nil, errors.New("the directory already exists")
+			return nil, ErrDirAlreadyExists
 		}
 
 		if layer != nil && bytes.Compare(existingNode._layer.MustGet(), layer) != 0 {
-			return nil, errors.New("the directory was created with an incompatible layer")
+			return nil, ErrIncompatibleLayer
 		}
 
 		return existingNode.getContents(dl, nil)
 	}
 
 	if !allowCreate {
-		return nil, errors.New("the directory does not exist")
+		return nil, ErrDirNotExists
 	}
 
-	if e := dl.checkVersion(rtr, tr); e != nil {
+	if e := validatePath(path); e != nil {
 		return nil, e
 	}
 
-	if prefix == nil {
-		newss, e := dl.allocator.allocate(*tr, dl.contentSS)
-		if e != nil {
-			return nil, fmt.Errorf("unable to allocate new directory prefix (%s)", e.Error())
-		}
-
-		if !isRangeEmpty(rtr, newss) {
-			return nil, fmt.Errorf("the database has keys stored at the prefix chosen by the automatic prefix allocator: %v", prefix)
-		}
-
-		prefix = newss.Bytes()
+	if e := dl.checkVersion(rtr, tr); e != nil {
+		return nil, e
+	}
 
-		pf, e := dl.isPrefixFree(rtr.Snapshot(), prefix)
-		if e != nil { return nil, e }
-		if !pf {
-			return nil, errors.New("the directory layer has manually allocated prefixes that conflict with the automatic prefix allocator")
-		}
-	} else {
-		pf, e := dl.isPrefixFree(rtr, prefix)
-		if e != nil { return nil, e }
-		if !pf {
-			return nil, errors.New("the given prefix is already in use")
-		}
+	prefix, e := dl.newPrefix(rtr, tr, prefix)
+	if e != nil {
+		return nil, e
 	}
 
 	var parentNode subspace.Subspace
 
 	if len(path) > 1 {
-		pd, e := dl.createOrOpen(rtr, tr, path[:len(path)-1], nil, nil, true, true)
+		pd, e := dl.createOrOpen(rtr, tr, path[:len(path)-1], nil, nil, nil, true, true)
 		if e != nil {
 			return nil, e
 		}
@@ -154,7 +148,7 @@ func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transacti
 	}
 
 	if parentNode == nil {
-		return nil, errors.New("the parent directory does not exist")
+		return nil, directoryError{"the parent directory does not exist", ErrDirNotExists}
 	}
 
 	node := dl.nodeWithPrefix(prefix)
@@ -165,13 +159,69 @@ func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transacti
 	}
 
 	tr.Set(node.Sub([]byte("layer")), layer)
+	setAttributes(tr, node, attrs)
+	dl.bumpMetadataVersion(tr)
 
 	return dl.contentsOfNode(node, path, layer)
 }
 
+// newPrefix returns prefix if it is free for a new directory, or a newly
+// allocated prefix if prefix is nil.
+func (dl directoryLayer) newPrefix(rtr fdb.ReadTransaction, tr fdb.WriteTransaction, prefix []byte) ([]byte, error) {
+	if prefix == nil {
+		prefixes, e := dl.allocatePrefixes(rtr, tr, 1)
+		if e != nil {
+			return nil, e
+		}
+		prefix = prefixes[0]
+
+		pf, e := dl.isPrefixFree(rtr.Snapshot(), prefix)
+		if e != nil { return nil, e }
+		if !pf {
+			return nil, errors.New("the directory layer has manually allocated prefixes that conflict with the automatic prefix allocator")
+		}
+	} else {
+		pf, e := dl.isPrefixFree(rtr, prefix)
+		if e != nil { return nil, e }
+		if !pf {
+			return nil, errors.New("the given prefix is already in use")
+		}
+	}
+
+	return prefix, nil
+}
+
+// allocatePrefixes allocates n prefixes from the content subspace of dl, under
+// which no keys are stored. Unlike newPrefix, it does not check the prefixes
+// against those of existing directories.
+func (dl directoryLayer) allocatePrefixes(rtr fdb.ReadTransaction, tr fdb.WriteTransaction, n int) ([][]byte, error) {
+	candidates, e := dl.allocator.allocate(tr, n)
+	if e != nil {
+		if _, ok := e.(fdb.Error); ok {
+			return nil, e
+		}
+		return nil, fmt.Errorf("unable to allocate new directory prefix: %w", e)
+	}
+
+	prefixes := make([][]byte, len(candidates))
+	for i, candidate := range candidates {
+		newss := dl.contentSS.Sub(candidate)
+		if !isRangeEmpty(rtr, newss) {
+			return nil, fmt.Errorf("the database has keys stored at the prefix chosen by the automatic prefix allocator: %x", newss.Bytes())
+		}
+		prefixes[i] = newss.Bytes()
+	}
+
+	return prefixes, nil
+}
+
 func (dl directoryLayer) CreateOrOpen(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return dl.CreateOrOpenWithAttributes(t, path, layer, nil)
+}
+
+func (dl directoryLayer) CreateOrOpenWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error) {
 	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
-		return dl.createOrOpen(tr, &tr, path, layer, nil, true, true)
+		return dl.createOrOpen(tr, tr, path, layer, nil, attrs, true, true)
 	})
 	if e != nil {
 		return nil, e
@@ -180,8 +230,12 @@ func (dl directoryLayer) CreateOrOpen(t fdb.Transactor, path []string, layer []b
 }
 
 func (dl directoryLayer) Create(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return dl.CreateWithAttributes(t, path, layer, nil)
+}
+
+func (dl directoryLayer) CreateWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error) {
 	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
-		return dl.createOrOpen(tr, &tr, path, layer, nil, true, false)
+		return dl.createOrOpen(tr, tr, path, layer, nil, attrs, true, false)
 	})
 	if e != nil {
 		return nil, e
@@ -194,7 +248,7 @@ func (dl directoryLayer) CreatePrefix(t fdb.Transactor, path []string, layer []b
 		prefix = []byte{}
 	}
 	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
-		return dl.createOrOpen(tr, &tr, path, layer, prefix, true, false)
+		return dl.createOrOpen(tr, tr, path, layer, prefix, nil, true, false)
 	})
 	if e != nil {
 		return nil, e
@@ -204,7 +258,7 @@ func (dl directoryLayer) CreatePrefix(t fdb.Transactor, path []string, layer []b
 
 func (dl directoryLayer) Open(rt fdb.ReadTransactor, path []string, layer []byte) (DirectorySubspace, error) {
 	r, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
-		return dl.createOrOpen(rtr, nil, path, layer, nil, false, true)
+		return dl.createOrOpen(rtr, nil, path, layer, nil, nil, false, true)
 	})
 	if e != nil {
 		return nil, e
@@ -247,7 +301,7 @@ func (dl directoryLayer) List(rt fdb.ReadTransactor, path []string) ([]string, e
 
 		node := dl.find(rtr, path).prefetchMetadata(rtr)
 		if !node.exists() {
-			return nil, errors.New("the directory does not exist")
+			return nil, ErrDirNotExists
 		}
 
 		if node.isInPartition(nil, true) {
@@ -272,7 +326,11 @@ func (dl directoryLayer) MoveTo(t fdb.Transactor, newAbsolutePath []string) (Dir
 
 func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []string) (DirectorySubspace, error) {
 	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
-		if e := dl.checkVersion(tr, &tr); e != nil {
+		if e := dl.checkVersion(tr, tr); e != nil {
+			return nil, e
+		}
+
+		if e := validatePath(newPath); e != nil {
 			return nil, e
 		}
 
@@ -288,12 +346,12 @@ func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []stri
 		newNode := dl.find(tr, newPath).prefetchMetadata(tr)
 
 		if !oldNode.exists() {
-			return nil, errors.New("the source directory does not exist")
+			return nil, directoryError{"the source directory does not exist", ErrDirNotExists}
 		}
 
 		if oldNode.isInPartition(nil, false) || newNode.isInPartition(nil, false) {
 			if !oldNode.isInPartition(nil, false) || !newNode.isInPartition(nil, false) || !stringsEqual(oldNode.path, newNode.path) {
-				return nil, errors.New("cannot move between partitions")
+				return nil, ErrPartitionMove
 			}
 
 			nnc, e := newNode.getContents(dl, nil)
@@ -304,12 +362,12 @@ func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []stri
 		}
 
 		if newNode.exists() {
-			return nil, errors.New("the destination directory already exists. Remove it first")
+			return nil, directoryError{"the destination directory already exists. Remove it first", ErrDirAlreadyExists}
 		}
 
 		parentNode := dl.find(tr, newPath[:len(newPath)-1])
 		if !parentNode.exists() {
-			return nil, errors.New("the parent of the destination directory does not exist. Create it first")
+			return nil, directoryError{"the parent of the destination directory does not exist. Create it first", ErrDirNotExists}
 		}
 
 		p, e := dl.nodeSS.Unpack(oldNode.subspace)
@@ -319,6 +377,7 @@ func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []stri
 		tr.Set(parentNode.subspace.Sub(_SUBDIRS, newPath[len(newPath)-1]), p[0].([]byte))
 
 		dl.removeFromParent(tr, oldPath)
+		dl.bumpMetadataVersion(tr)
 
 		return dl.contentsOfNode(oldNode.subspace, newPath, oldNode._layer.MustGet())
 	})
@@ -330,7 +389,7 @@ func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []stri
 
 func (dl directoryLayer) Remove(t fdb.Transactor, path []string) (bool, error) {
 	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
-		if e := dl.checkVersion(tr, &tr); e != nil {
+		if e := dl.checkVersion(tr, tr); e != nil {
 			return false, e
 		}
 
@@ -356,6 +415,7 @@ func (dl directoryLayer) Remove(t fdb.Transactor, path []string) (bool, error) {
 			return false, e
 		}
 		dl.removeFromParent(tr, path)
+		dl.bumpMetadataVersion(tr)
 
 		return true, nil
 	})
@@ -365,7 +425,7 @@ func (dl directoryLayer) Remove(t fdb.Transactor, path []string) (bool, error) {
 	return r.(bool), nil
 }
 
-func (dl directoryLayer) removeRecursive(tr fdb.Transaction, node subspace.Subspace) error {
+func (dl directoryLayer) removeRecursive(tr fdb.WriteTransaction, node subspace.Subspace) error {
 	nodes := dl.subdirNodes(tr, node)
 	for i := range nodes {
 		if e := dl.removeRecursive(tr, nodes[i]); e != nil {
@@ -384,7 +444,7 @@ func (dl directoryLayer) removeRecursive(tr fdb.Transaction, node subspace.Subsp
 	return nil
 }
 
-func (dl directoryLayer) removeFromParent(tr fdb.Transaction, path []string) {
+func (dl directoryLayer) removeFromParent(tr fdb.WriteTransaction, path []string) {
 	parent := dl.find(tr, path[:len(path)-1])
 	tr.Clear(parent.subspace.Sub(_SUBDIRS, path[len(path)-1]))
 }
@@ -419,7 +479,7 @@ func (dl directoryLayer) subdirNames(rtr fdb.ReadTransaction, node subspace.Subs
 	return ret, nil
 }
 
-func (dl directoryLayer) subdirNodes(tr fdb.Transaction, node subspace.Subspace) []subspace.Subspace {
+func (dl directoryLayer) subdirNodes(tr fdb.WriteTransaction, node subspace.Subspace) []subspace.Subspace {
 	sd := node.Sub(_SUBDIRS)
 
 	rr := tr.GetRange(sd, fdb.RangeOptions{})
@@ -442,7 +502,7 @@ func (dl directoryLayer) nodeContainingKey(rtr fdb.ReadTransaction, key []byte)
 	}
 
 	bk, _ := dl.nodeSS.FDBRangeKeys()
-	kr := fdb.KeyRange{bk, fdb.Key(append(dl.nodeSS.Pack(tuple.Tuple{key}), 0x00))}
+	kr := fdb.KeyRange{Begin: bk, End: fdb.Key(append(dl.nodeSS.Pack(tuple.Tuple{key}), 0x00))}
 
 	kvs := rtr.GetRange(kr, fdb.RangeOptions{Reverse:true, Limit:1}).GetSliceOrPanic()
 	if len(kvs) == 1 {
@@ -478,19 +538,19 @@ func (dl directoryLayer) isPrefixFree(rtr fdb.ReadTransaction, prefix []byte) (b
 	}
 
 	bk, ek := kr.FDBRangeKeys()
-	if !isRangeEmpty(rtr, fdb.KeyRange{dl.nodeSS.Pack(tuple.Tuple{bk}), dl.nodeSS.Pack(tuple.Tuple{ek})}) {
+	if !isRangeEmpty(rtr, fdb.KeyRange{Begin: dl.nodeSS.Pack(tuple.Tuple{bk}), End: dl.nodeSS.Pack(tuple.Tuple{ek})}) {
 		return false, nil
 	}
 
 	return true, nil
 }
 
-func (dl directoryLayer) checkVersion(rtr fdb.ReadTransaction, tr *fdb.Transaction) error {
+func (dl directoryLayer) checkVersion(rtr fdb.ReadTransaction, tr fdb.WriteTransaction) error {
 	version := rtr.Get(dl.rootNode.Sub([]byte("version"))).MustGet()
 
 	if version == nil {
 		if tr != nil {
-			dl.initializeDirectory(*tr)
+			dl.initializeDirectory(tr)
 		}
 		return nil
 	}
@@ -502,23 +562,23 @@ func (dl directoryLayer) checkVersion(rtr fdb.ReadTransaction, tr *fdb.Transacti
 		var v int32
 		err := binary.Read(buf, binary.LittleEndian, &v)
 		if err != nil {
-			return errors.New("cannot determine directory version present in database")
+			return directoryError{"cannot determine directory version present in database", ErrVersion}
 		}
 		versions = append(versions, v)
 	}
 
 	if versions[0] > _MAJORVERSION {
-		return fmt.Errorf("cannot load directory with version %d.%d.%d using directory layer %d.%d.%d", versions[0], versions[1], versions[2], _MAJORVERSION, _MINORVERSION, _MICROVERSION)
+		return &VersionError{Major: versions[0], Minor: versions[1], Micro: versions[2]}
 	}
 
 	if versions[1] > _MINORVERSION && tr != nil /* aka write access allowed */ {
-		return fmt.Errorf("directory with version %d.%d.%d is read-only when opened using directory layer %d.%d.%d", versions[0], versions[1], versions[2], _MAJORVERSION, _MINORVERSION, _MICROVERSION)
+		return &VersionError{Major: versions[0], Minor: versions[1], Micro: versions[2], ReadOnly: true}
 	}
 
 	return nil
 }
 
-func (dl directoryLayer) initializeDirectory(tr fdb.Transaction) {
+func (dl directoryLayer) initializeDirectory(tr fdb.WriteTransaction) {
 	buf := new(bytes.Buffer)
 
 	// bytes.Buffer claims that Write will always return a nil error, which
@@ -532,6 +592,18 @@ func (dl directoryLayer) initializeDirectory(tr fdb.Transaction) {
 	tr.Set(dl.rootNode.Sub([]byte("version")), buf.Bytes())
 }
 
+// bumpMetadataVersion sets the metadata version key to the versionstamp of tr,
+// so that any cached directories (see NewCachedDirectoryLayer) are invalidated
+// when tr commits. Reading the key later in tr fails with accessed_unreadable
+// (1036).
+func (dl directoryLayer) bumpMetadataVersion(tr fdb.WriteTransaction) {
+	param := make([]byte, 14)
+	if v, e := fdb.GetAPIVersion(); e == nil && v < 520 {
+		param = param[:10]
+	}
+	tr.SetVersionstampedValue(dl.metadataVersion, param)
+}
+
 func (dl directoryLayer) contentsOfNode(node subspace.Subspace, path []string, layer []byte) (DirectorySubspace, error) {
 	p, e := dl.nodeSS.Unpack(node)
 	if e != nil {
@@ -547,17 +619,24 @@ func (dl directoryLayer) contentsOfNode(node subspace.Subspace, path []string, l
 	ss := subspace.FromBytes(pb)
 
 	if bytes.Compare(layer, []byte("partition")) == 0 {
-		nssb := make([]byte, len(pb) + 1)
-		copy(nssb, pb)
-		nssb[len(pb)] = 0xFE
-		ndl := NewDirectoryLayer(subspace.FromBytes(nssb), ss, false).(directoryLayer)
-		ndl.path = newPath
-		return directoryPartition{ndl, dl}, nil
+		return directoryPartition{dl.partitionLayer(pb, newPath), dl}, nil
 	} else {
 		return directorySubspace{ss, dl, newPath, layer}, nil
 	}
 }
 
+// partitionLayer returns the directory layer of the partition with the given
+// prefix, at the absolute path path.
+func (dl directoryLayer) partitionLayer(prefix []byte, path []string) directoryLayer {
+	nssb := make([]byte, len(prefix) + 1)
+	copy(nssb, prefix)
+	nssb[len(prefix)] = 0xFE
+	ndl := NewDirectoryLayer(subspace.FromBytes(nssb), subspace.FromBytes(prefix), false).(directoryLayer)
+	ndl.path = path
+	ndl.metadataVersion = dl.metadataVersion
+	return ndl
+}
+
 func (dl directoryLayer) nodeWithPrefix(prefix []byte) subspace.Subspace {
 	if prefix == nil { return nil }
 	return dl.nodeSS.Sub(prefix)
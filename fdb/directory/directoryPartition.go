@@ -44,6 +44,10 @@ func (dp directoryPartition) Pack(t tuple.Tuple) fdb.Key {
 	panic("cannot pack keys using the root of a directory partition")
 }
 
+func (dp directoryPartition) PackWithVersionstamp(t tuple.Tuple) (fdb.Key, error) {
+	return nil, ErrPartitionSubspace
+}
+
 func (dp directoryPartition) Unpack(k fdb.KeyConvertible) (tuple.Tuple, error) {
 	panic("cannot unpack keys using the root of a directory partition")
 }
@@ -52,6 +56,18 @@ func (dp directoryPartition) Contains(k fdb.KeyConvertible) bool {
 	panic("cannot check whether a key belongs to the root of a directory partition")
 }
 
+func (dp directoryPartition) Range(begin, end tuple.Tuple, beginInclusive, endInclusive bool) (fdb.KeyRange, error) {
+	return fdb.KeyRange{}, ErrPartitionSubspace
+}
+
+func (dp directoryPartition) StringPrefixRange(t tuple.Tuple, prefix string) (fdb.KeyRange, error) {
+	return fdb.KeyRange{}, ErrPartitionSubspace
+}
+
+func (dp directoryPartition) BytesPrefixRange(t tuple.Tuple, prefix []byte) (fdb.KeyRange, error) {
+	return fdb.KeyRange{}, ErrPartitionSubspace
+}
+
 func (dp directoryPartition) FDBKey() fdb.Key {
 	panic("cannot use the root of a directory partition as a key")
 }
@@ -64,6 +80,22 @@ func (dp directoryPartition) FDBRangeKeySelectors() (fdb.Selectable, fdb.Selecta
 	panic("cannot get range for the root of a directory partition")
 }
 
+func (dp directoryPartition) GetAttributes(rt fdb.ReadTransactor) (Attributes, error) {
+	return getAttributes(rt, dp.parentDirectoryLayer.nodeWithPrefix(dp.contentSS.Bytes()))
+}
+
+func (dp directoryPartition) SetAttributes(t fdb.Transactor, attrs Attributes) error {
+	return updateAttributes(t, dp.parentDirectoryLayer.nodeWithPrefix(dp.contentSS.Bytes()), attrs)
+}
+
+func (dp directoryPartition) IsPartition() bool {
+	return true
+}
+
+func (dp directoryPartition) AsSubspace() (subspace.Subspace, error) {
+	return nil, ErrPartitionSubspace
+}
+
 func (dp directoryPartition) GetLayer() []byte {
 	return []byte("partition")
 }
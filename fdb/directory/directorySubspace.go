@@ -30,9 +30,39 @@ import (
 // to store key/value pairs. Subdirectories of a root directory (as returned by
 // Root or NewDirectoryLayer) are DirectorySubspaces, and provide all methods of
 // the Directory and subspace.Subspace interfaces.
+//
+// The root of a directory partition is a DirectorySubspace that cannot be used
+// as a Subspace, since the contents of a partition may only be stored in its
+// subdirectories. Its Sub, Bytes, Pack, Unpack, Contains, FDBKey,
+// FDBRangeKeys and FDBRangeKeySelectors methods panic, while its
+// PackWithVersionstamp, Range, StringPrefixRange and BytesPrefixRange methods
+// return ErrPartitionSubspace. Code that may be given a partition should check
+// IsPartition, or use AsSubspace, before using a DirectorySubspace as a
+// Subspace.
 type DirectorySubspace interface {
 	subspace.Subspace
 	Directory
+
+	// GetAttributes returns the attributes of this directory, or
+	// ErrDirNotExists if the directory has been removed.
+	GetAttributes(rt fdb.ReadTransactor) (Attributes, error)
+
+	// SetAttributes sets the attributes of this directory named in attrs,
+	// removing those whose value is nil, and leaves its other attributes
+	// unchanged. SetAttributes returns ErrDirNotExists if the directory has
+	// been removed.
+	SetAttributes(t fdb.Transactor, attrs Attributes) error
+
+	// IsPartition returns true if this directory is the root of a directory
+	// partition.
+	IsPartition() bool
+
+	// AsSubspace returns the Subspace in which the contents of this directory
+	// are stored, or ErrPartitionSubspace if this directory is the root of a
+	// directory partition. The methods of the returned Subspace never panic
+	// because of the kind of directory, so AsSubspace is the checked
+	// alternative to calling them on the DirectorySubspace itself.
+	AsSubspace() (subspace.Subspace, error)
 }
 
 type directorySubspace struct {
@@ -46,10 +76,18 @@ func (d directorySubspace) CreateOrOpen(t fdb.Transactor, path []string, layer [
 	return d.dl.CreateOrOpen(t, d.dl.partitionSubpath(d.path, path), layer)
 }
 
+func (d directorySubspace) CreateOrOpenWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error) {
+	return d.dl.CreateOrOpenWithAttributes(t, d.dl.partitionSubpath(d.path, path), layer, attrs)
+}
+
 func (d directorySubspace) Create(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
 	return d.dl.Create(t, d.dl.partitionSubpath(d.path, path), layer)
 }
 
+func (d directorySubspace) CreateWithAttributes(t fdb.Transactor, path []string, layer []byte, attrs Attributes) (DirectorySubspace, error) {
+	return d.dl.CreateWithAttributes(t, d.dl.partitionSubpath(d.path, path), layer, attrs)
+}
+
 func (d directorySubspace) CreatePrefix(t fdb.Transactor, path []string, layer []byte, prefix []byte) (DirectorySubspace, error) {
 	return d.dl.CreatePrefix(t, d.dl.partitionSubpath(d.path, path), layer, prefix)
 }
@@ -78,6 +116,26 @@ func (d directorySubspace) List(rt fdb.ReadTransactor, path []string) (subdirs [
 	return d.dl.List(rt, d.dl.partitionSubpath(d.path, path))
 }
 
+func (d directorySubspace) ListDetailed(rt fdb.ReadTransactor, path []string, options ListOptions) ([]Subdirectory, error) {
+	return d.dl.ListDetailed(rt, d.dl.partitionSubpath(d.path, path), options)
+}
+
+func (d directorySubspace) GetAttributes(rt fdb.ReadTransactor) (Attributes, error) {
+	return getAttributes(rt, d.dl.nodeWithPrefix(d.Bytes()))
+}
+
+func (d directorySubspace) SetAttributes(t fdb.Transactor, attrs Attributes) error {
+	return updateAttributes(t, d.dl.nodeWithPrefix(d.Bytes()), attrs)
+}
+
+func (d directorySubspace) IsPartition() bool {
+	return false
+}
+
+func (d directorySubspace) AsSubspace() (subspace.Subspace, error) {
+	return d.Subspace, nil
+}
+
 func (d directorySubspace) GetLayer() []byte {
 	return d.layer
 }
@@ -0,0 +1,1075 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/memdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newRoot returns an empty database and a root directory storing its metadata
+// in keys beginning with 0xFE.
+func newRoot() (fdb.Database, directory.Directory) {
+	return memdb.New(), directory.NewDirectoryLayer(subspace.FromBytes([]byte{0xFE}), subspace.AllKeys(), false)
+}
+
+// countingReadTransactor counts the reads performed by the read-only
+// transactional functions run with it.
+type countingReadTransactor struct {
+	db fdb.Database
+	reads int
+}
+
+type countingReadTransaction struct {
+	fdb.ReadTransaction
+	reads *int
+}
+
+func (c *countingReadTransactor) ReadTransact(f func(fdb.ReadTransaction) (interface{}, error)) (interface{}, error) {
+	return c.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return f(countingReadTransaction{rtr, &c.reads})
+	})
+}
+
+func (rtr countingReadTransaction) Get(key fdb.KeyConvertible) fdb.FutureByteSlice {
+	*rtr.reads++
+	return rtr.ReadTransaction.Get(key)
+}
+
+func (rtr countingReadTransaction) GetRange(r fdb.Range, options fdb.RangeOptions) fdb.RangeResult {
+	*rtr.reads++
+	return rtr.ReadTransaction.GetRange(r, options)
+}
+
+func TestCachedDirectoryLayer(t *testing.T) {
+	db, other := newRoot()
+	dl := directory.NewCachedDirectoryLayer(subspace.FromBytes([]byte{0xFE}), subspace.AllKeys(), false)
+
+	created, e := dl.CreateOrOpen(db, []string{"app", "users"}, []byte("table"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	open := func(path ...string) (directory.DirectorySubspace, int, error) {
+		crt := &countingReadTransactor{db: db}
+		ds, e := dl.Open(crt, path, nil)
+		return ds, crt.reads, e
+	}
+
+	if _, _, e = open("app", "users"); e != nil {
+		t.Fatal(e)
+	}
+	ds, reads, e := open("app", "users")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if reads != 1 {
+		t.Errorf("got %d reads opening a cached directory, expected 1", reads)
+	}
+	if !bytes.Equal(ds.Bytes(), created.Bytes()) {
+		t.Errorf("got prefix %x from cache, expected %x", ds.Bytes(), created.Bytes())
+	}
+	if _, e = dl.Open(db, []string{"app", "users"}, []byte("queue")); e == nil {
+		t.Error("expected incompatible layer error from cached directory")
+	}
+
+	// A directory moved by another directory layer is no longer cached.
+	if _, e = other.Move(db, []string{"app", "users"}, []string{"app", "people"}); e != nil {
+		t.Fatal(e)
+	}
+	if _, _, e = open("app", "users"); e == nil {
+		t.Error("expected error opening moved directory")
+	}
+	if ds, _, e = open("app", "people"); e != nil || !bytes.Equal(ds.Bytes(), created.Bytes()) {
+		t.Errorf("got %v opening moved directory", e)
+	}
+
+	// Directories changed within a transaction are visible to it.
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if _, e := dl.Remove(tr, []string{"app", "people"}); e != nil {
+			return nil, e
+		}
+		if _, e := dl.Open(tr, []string{"app", "people"}, nil); e == nil {
+			t.Error("expected error opening directory removed in the same transaction")
+		}
+		if _, e := dl.CreateOrOpen(tr, []string{"app", "people"}, nil); e != nil {
+			return nil, e
+		}
+		return dl.Open(tr, []string{"app", "people"}, nil)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ds, _, e = open("app", "people"); e != nil || bytes.Equal(ds.Bytes(), created.Bytes()) {
+		t.Errorf("got %v opening recreated directory, expected a new prefix", e)
+	}
+}
+
+func TestDirectoryWalk(t *testing.T) {
+	db, root := newRoot()
+
+	for _, path := range [][]string{{"app", "users"}, {"app", "part", "a", "b"}, {"logs"}} {
+		if _, e := root.CreateOrOpen(db, path, nil); e != nil {
+			t.Fatal(e)
+		}
+	}
+	// Recreate app/part as a partition.
+	if _, e := root.Remove(db, []string{"app", "part"}); e != nil {
+		t.Fatal(e)
+	}
+	part, e := root.Create(db, []string{"app", "part"}, []byte("partition"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = part.CreateOrOpen(db, []string{"a", "b"}, []byte("queue")); e != nil {
+		t.Fatal(e)
+	}
+
+	var visited []string
+	e = directory.Walk(db, root, func(info directory.DirectoryInfo) error {
+		visited = append(visited, fmt.Sprintf("%s:%s:%s", strings.Join(info.Path, "/"), info.Layer, strings.Join(info.Partition, "/")))
+		if len(info.Prefix) == 0 {
+			t.Errorf("got no prefix for %v", info.Path)
+		}
+		if info.Path[len(info.Path)-1] == "logs" {
+			return directory.SkipDir
+		}
+		return nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := "[app:: app/part:partition: app/part/a::app/part app/part/a/b:queue:app/part app/users:: logs::]"
+	if s := fmt.Sprint(visited); s != expected {
+		t.Errorf("got %s, expected %s", s, expected)
+	}
+
+	// Walk reads the version of the directory layer, and for each directory
+	// whose subdirectories are visited (including root) a single range of
+	// subdirectories and the layer of each.
+	crt := &countingReadTransactor{db: db}
+	if e = directory.Walk(crt, root, func(info directory.DirectoryInfo) error { return nil }); e != nil {
+		t.Fatal(e)
+	}
+	if crt.reads != 14 {
+		t.Errorf("got %d reads walking 6 directories, expected 14", crt.reads)
+	}
+
+	visited = nil
+	e = directory.Walk(db, part, func(info directory.DirectoryInfo) error {
+		visited = append(visited, strings.Join(info.Path, "/"))
+		return directory.SkipDir
+	})
+	if e != nil || fmt.Sprint(visited) != "[app/part/a]" {
+		t.Errorf("got %v, %v walking partition, expected [app/part/a]", visited, e)
+	}
+
+	var b bytes.Buffer
+	if e = directory.ExportJSON(db, root, &b); e != nil {
+		t.Fatal(e)
+	}
+	var exported []struct {
+		Name string
+		Subdirectories []struct {
+			Name string
+			Layer string
+		}
+	}
+	if e = json.Unmarshal(b.Bytes(), &exported); e != nil {
+		t.Fatal(e)
+	}
+	if len(exported) != 2 || exported[0].Name != "app" || len(exported[0].Subdirectories) != 2 || exported[0].Subdirectories[0].Layer != "partition" {
+		t.Errorf("got unexpected JSON export %s", b.String())
+	}
+
+	b.Reset()
+	if e = directory.ExportDOT(db, root, &b); e != nil {
+		t.Fatal(e)
+	}
+	dot := b.String()
+	if !strings.HasPrefix(dot, "digraph directories {") || strings.Count(dot, "->") != 6 || !strings.Contains(dot, "shape=folder") {
+		t.Errorf("got unexpected DOT export %s", dot)
+	}
+}
+
+func TestDirectoryCheck(t *testing.T) {
+	db, root := newRoot()
+
+	a, e := root.Create(db, []string{"a"}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, path := range [][]string{{"b", "c"}, {"p"}} {
+		var layer []byte
+		if path[0] == "p" {
+			layer = []byte("partition")
+		}
+		if _, e = root.Create(db, path, layer); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if _, e = root.Create(db, []string{"p", "x"}, nil); e != nil {
+		t.Fatal(e)
+	}
+
+	issues, e := directory.Check(db, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("got issues %v in consistent directory layer", issues)
+	}
+
+	rootNode := subspace.FromBytes([]byte{0xFE}).Sub([]byte{0xFE})
+	hca := rootNode.Sub([]byte("hca"))
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(rootNode.Sub(0, "ghost"), []byte{0x99})
+		tr.Set(rootNode.Sub(0, "dup"), a.Bytes())
+		tr.Set(subspace.FromBytes([]byte{0xFE}).Sub([]byte{0x98}, []byte("layer")), nil)
+		tr.Set(fdb.Key("\x50unowned"), nil)
+		tr.Set(hca.Sub(0, -64), make([]byte, 8))
+		tr.ClearRange(hca.Sub(1))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	kinds := func(issues []directory.Issue) string {
+		var s []string
+		for _, i := range issues {
+			k := i.Kind.String()
+			if i.Repaired {
+				k += "*"
+			}
+			s = append(s, k)
+		}
+		return strings.Join(s, ", ")
+	}
+
+	issues, e = directory.Check(db, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := "overlapping prefixes, dangling subdirectory, orphaned node, unowned content, stale allocator counter, unrecorded prefix, unrecorded prefix, unrecorded prefix, unrecorded prefix"
+	if s := kinds(issues); s != expected {
+		t.Errorf("got issues %s, expected %s", s, expected)
+	}
+	if s := issues[0].String(); s != fmt.Sprintf("prefix %x of directory /a overlaps the prefix of directory /dup", a.Bytes()) {
+		t.Errorf("got %q for overlapping prefixes", s)
+	}
+
+	issues, e = directory.Repair(db, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected = "overlapping prefixes, dangling subdirectory*, orphaned node*, unowned content, stale allocator counter*, unrecorded prefix*, unrecorded prefix*, unrecorded prefix*, unrecorded prefix*"
+	if s := kinds(issues); s != expected {
+		t.Errorf("got repaired issues %s, expected %s", s, expected)
+	}
+
+	issues, e = directory.Check(db, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := kinds(issues); s != "overlapping prefixes, unowned content" {
+		t.Errorf("got issues %s after repair, expected overlapping prefixes, unowned content", s)
+	}
+}
+
+func TestDirectoryErrors(t *testing.T) {
+	db, root := newRoot()
+
+	if _, e := root.Create(db, []string{"a"}, []byte("table")); e != nil {
+		t.Fatal(e)
+	}
+	part, e := root.Create(db, []string{"p"}, []byte("partition"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = part.Create(db, []string{"x"}, nil); e != nil {
+		t.Fatal(e)
+	}
+
+	expect := func(e, target error) {
+		t.Helper()
+		if !errors.Is(e, target) {
+			t.Errorf("got error %v, expected %v", e, target)
+		}
+	}
+
+	_, e = root.Open(db, []string{"b"}, nil)
+	expect(e, directory.ErrDirNotExists)
+	_, e = root.Create(db, []string{"a"}, nil)
+	expect(e, directory.ErrDirAlreadyExists)
+	_, e = root.Open(db, []string{"a"}, []byte("queue"))
+	expect(e, directory.ErrIncompatibleLayer)
+	_, e = root.Move(db, []string{"b"}, []string{"c"})
+	expect(e, directory.ErrDirNotExists)
+	_, e = root.Move(db, []string{"a"}, []string{"p"})
+	expect(e, directory.ErrDirAlreadyExists)
+	_, e = root.Move(db, []string{"a"}, []string{"b", "c"})
+	expect(e, directory.ErrDirNotExists)
+	_, e = root.Move(db, []string{"a"}, []string{"p", "a"})
+	expect(e, directory.ErrPartitionMove)
+	_, e = part.List(db, []string{"y"})
+	expect(e, directory.ErrDirNotExists)
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(subspace.FromBytes([]byte{0xFE}).Sub([]byte{0xFE}, []byte("version")), []byte{2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = root.Open(db, []string{"a"}, nil)
+	expect(e, directory.ErrVersion)
+	var ve *directory.VersionError
+	if !errors.As(e, &ve) || ve.Major != 2 || ve.ReadOnly {
+		t.Errorf("got %#v, expected version 2.0.0", ve)
+	}
+}
+
+func TestDirectoryPartitionSubspace(t *testing.T) {
+	db, root := newRoot()
+
+	part, e := root.Create(db, []string{"p"}, []byte("partition"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	dir, e := part.Create(db, []string{"d"}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if !part.IsPartition() || dir.IsPartition() {
+		t.Errorf("got IsPartition %v for partition and %v for directory, expected true and false", part.IsPartition(), dir.IsPartition())
+	}
+	if _, e = part.AsSubspace(); !errors.Is(e, directory.ErrPartitionSubspace) {
+		t.Errorf("got %v from AsSubspace of partition, expected ErrPartitionSubspace", e)
+	}
+	if _, e = part.PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(0)}); !errors.Is(e, directory.ErrPartitionSubspace) {
+		t.Errorf("got %v from PackWithVersionstamp of partition, expected ErrPartitionSubspace", e)
+	}
+	if _, e = part.Range(tuple.Tuple{1}, tuple.Tuple{2}, true, false); !errors.Is(e, directory.ErrPartitionSubspace) {
+		t.Errorf("got %v from Range of partition, expected ErrPartitionSubspace", e)
+	}
+	if _, e = part.StringPrefixRange(nil, "a"); !errors.Is(e, directory.ErrPartitionSubspace) {
+		t.Errorf("got %v from StringPrefixRange of partition, expected ErrPartitionSubspace", e)
+	}
+	if _, e = part.BytesPrefixRange(nil, []byte("a")); !errors.Is(e, directory.ErrPartitionSubspace) {
+		t.Errorf("got %v from BytesPrefixRange of partition, expected ErrPartitionSubspace", e)
+	}
+	ss, e := dir.AsSubspace()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !bytes.Equal(ss.Bytes(), dir.Bytes()) || !bytes.Equal(ss.Pack(tuple.Tuple{1}), dir.Pack(tuple.Tuple{1})) {
+		t.Errorf("got subspace %x from AsSubspace, expected %x", ss.Bytes(), dir.Bytes())
+	}
+}
+
+// failingTransactor runs n transactional functions, and then fails.
+type failingTransactor struct {
+	fdb.Database
+	n int
+}
+
+func (ft *failingTransactor) Transact(f func(fdb.Transaction) (interface{}, error)) (interface{}, error) {
+	if ft.n == 0 {
+		return nil, errors.New("interrupted")
+	}
+	ft.n--
+	return ft.Database.Transact(f)
+}
+
+func TestMoveAcrossPartitions(t *testing.T) {
+	db, root := newRoot()
+
+	if _, e := root.Create(db, []string{"p"}, []byte("partition")); e != nil {
+		t.Fatal(e)
+	}
+	populate := func(path []string, n int) {
+		ds, e := root.CreateOrOpen(db, path, []byte("table"))
+		if e != nil {
+			t.Fatal(e)
+		}
+		_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			for i := 0; i < n; i++ {
+				tr.Set(ds.Pack(tuple.Tuple{i}), []byte(fmt.Sprint(i)))
+			}
+			return nil, nil
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	count := func(path []string) int {
+		ds, e := root.Open(db, path, []byte("table"))
+		if e != nil {
+			t.Fatalf("opening %v: %v", path, e)
+		}
+		kvs, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+			return rtr.GetRange(ds, fdb.RangeOptions{}).GetSliceWithError()
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		return len(kvs.([]fdb.KeyValue))
+	}
+	check := func() {
+		issues, e := directory.Check(db, root)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if len(issues) != 0 {
+			t.Errorf("got issues %v", issues)
+		}
+	}
+
+	populate([]string{"a"}, 25)
+	populate([]string{"a", "b"}, 5)
+
+	// Interrupt the move after it has begun and copied a batch.
+	_, e := directory.MoveAcrossPartitions(&failingTransactor{db, 2}, root, []string{"a"}, []string{"p", "a"}, directory.MoveOptions{BatchKeys: 10})
+	if e == nil {
+		t.Fatal("expected interrupted move to fail")
+	}
+	if n := count([]string{"a"}); n != 25 {
+		t.Errorf("got %d keys in source of interrupted move, expected 25", n)
+	}
+
+	moved, e := directory.MoveAcrossPartitions(db, root, []string{"a"}, []string{"p", "a"}, directory.MoveOptions{BatchKeys: 10})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := strings.Join(moved.GetPath(), "/"); s != "p/a" {
+		t.Errorf("got path %s for moved directory, expected p/a", s)
+	}
+	if n := count([]string{"p", "a"}); n != 25 {
+		t.Errorf("got %d keys in moved directory, expected 25", n)
+	}
+	if n := count([]string{"p", "a", "b"}); n != 5 {
+		t.Errorf("got %d keys in moved subdirectory, expected 5", n)
+	}
+	if ok, _ := root.Exists(db, []string{"a"}); ok {
+		t.Error("source directory still exists after move")
+	}
+	check()
+
+	// Moves within a partition are ordinary moves.
+	if _, e = directory.MoveAcrossPartitions(db, root, []string{"p", "a"}, []string{"p", "c"}, directory.MoveOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	if n := count([]string{"p", "c", "b"}); n != 5 {
+		t.Errorf("got %d keys after move within partition, expected 5", n)
+	}
+
+	// A move is abandoned if its source is removed before it completes.
+	_, e = directory.MoveAcrossPartitions(&failingTransactor{db, 2}, root, []string{"p", "c"}, []string{"d"}, directory.MoveOptions{BatchKeys: 10})
+	if e == nil {
+		t.Fatal("expected interrupted move to fail")
+	}
+	if _, e = root.Remove(db, []string{"p", "c", "b"}); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = directory.MoveAcrossPartitions(db, root, []string{"p", "c"}, []string{"d"}, directory.MoveOptions{}); e == nil {
+		t.Error("expected move of changed directory to fail")
+	}
+	if n := count([]string{"p", "c"}); n != 25 {
+		t.Errorf("got %d keys in source of abandoned move, expected 25", n)
+	}
+	check()
+
+	_, e = directory.MoveAcrossPartitions(db, root, []string{"p", "c"}, []string{"p", "c", "x"}, directory.MoveOptions{})
+	if e == nil {
+		t.Error("expected error moving directory into itself")
+	}
+
+	// A partition may be moved within the partition containing it, but not
+	// out of it.
+	if _, e = root.Create(db, []string{"p", "inner"}, []byte("partition")); e != nil {
+		t.Fatal(e)
+	}
+	_, e = directory.MoveAcrossPartitions(db, root, []string{"p", "inner"}, []string{"inner"}, directory.MoveOptions{})
+	if !errors.Is(e, directory.ErrPartitionMove) {
+		t.Errorf("got %v moving partition between partitions, expected ErrPartitionMove", e)
+	}
+	moved, e = directory.MoveAcrossPartitions(db, root, []string{"p"}, []string{"q"}, directory.MoveOptions{})
+	if e != nil {
+		t.Fatalf("moving partition within its partition: %v", e)
+	}
+	if !moved.IsPartition() || strings.Join(moved.GetPath(), "/") != "q" {
+		t.Errorf("got path %v and partition %v, expected partition q", moved.GetPath(), moved.IsPartition())
+	}
+	if n := count([]string{"q", "c"}); n != 25 {
+		t.Errorf("got %d keys in moved partition, expected 25", n)
+	}
+	check()
+}
+
+// unreadableTransactor counts the transactional functions run with it, and
+// makes the version of the root directory layer unreadable in the nth.
+type unreadableTransactor struct {
+	fdb.Database
+	n, calls int
+}
+
+func (ut *unreadableTransactor) Transact(f func(fdb.Transaction) (interface{}, error)) (interface{}, error) {
+	ut.calls++
+	if ut.calls != ut.n {
+		return ut.Database.Transact(f)
+	}
+	return ut.Database.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.SetVersionstampedValue(subspace.FromBytes([]byte{0xFE}).Sub([]byte{0xFE}, []byte("version")), make([]byte, 14))
+		return f(tr)
+	})
+}
+
+func TestMoveAcrossPartitionsReadError(t *testing.T) {
+	setup := func() (fdb.Database, directory.Directory) {
+		db, root := newRoot()
+		if _, e := root.Create(db, []string{"p"}, []byte("partition")); e != nil {
+			t.Fatal(e)
+		}
+		a, e := root.Create(db, []string{"a"}, nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			for i := 0; i < 25; i++ {
+				tr.Set(a.Pack(tuple.Tuple{i}), []byte(fmt.Sprint(i)))
+			}
+			return nil, nil
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		return db, root
+	}
+	options := directory.MoveOptions{BatchKeys: 10}
+
+	// Count the transactions of a complete move.
+	db, root := setup()
+	counter := &unreadableTransactor{Database: db}
+	if _, e := directory.MoveAcrossPartitions(counter, root, []string{"a"}, []string{"p", "a"}, options); e != nil {
+		t.Fatal(e)
+	}
+
+	// A failed read in the final transaction fails the move, rather than
+	// abandoning it.
+	db, root = setup()
+	_, e := directory.MoveAcrossPartitions(&unreadableTransactor{Database: db, n: counter.calls}, root, []string{"a"}, []string{"p", "a"}, options)
+	var fe fdb.Error
+	if !errors.As(e, &fe) || fe.Code != 1036 {
+		t.Fatalf("got %v from move with unreadable version, expected error 1036", e)
+	}
+	if ok, _ := root.Exists(db, []string{"p", "a"}); ok {
+		t.Error("destination directory exists after failed move")
+	}
+
+	// The move is then resumed from its checkpoint, without copying again.
+	resumed := &unreadableTransactor{Database: db}
+	moved, e := directory.MoveAcrossPartitions(resumed, root, []string{"a"}, []string{"p", "a"}, options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if resumed.calls >= counter.calls {
+		t.Errorf("resumed move ran %d transactions, expected fewer than %d", resumed.calls, counter.calls)
+	}
+	kvs, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.GetRange(moved, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if n := len(kvs.([]fdb.KeyValue)); n != 25 {
+		t.Errorf("got %d keys in moved directory, expected 25", n)
+	}
+}
+
+func TestDirectoryAttributes(t *testing.T) {
+	db, root := newRoot()
+
+	attrsOf := func(ds directory.DirectorySubspace) string {
+		attrs, e := ds.GetAttributes(db)
+		if e != nil {
+			t.Fatal(e)
+		}
+		names := make([]string, 0, len(attrs))
+		for name, value := range attrs {
+			names = append(names, name + "=" + string(value))
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+
+	a, e := root.CreateWithAttributes(db, []string{"a"}, nil, directory.Attributes{"owner": []byte("alice"), "schema": []byte("1")})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := attrsOf(a); s != "owner=alice,schema=1" {
+		t.Errorf("got attributes %s after create, expected owner=alice,schema=1", s)
+	}
+
+	// CreateOrOpen of an existing directory leaves its attributes unchanged.
+	a, e = root.CreateOrOpenWithAttributes(db, []string{"a"}, nil, directory.Attributes{"owner": []byte("bob")})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := attrsOf(a); s != "owner=alice,schema=1" {
+		t.Errorf("got attributes %s after open, expected owner=alice,schema=1", s)
+	}
+
+	if e = a.SetAttributes(db, directory.Attributes{"schema": []byte("2"), "owner": nil}); e != nil {
+		t.Fatal(e)
+	}
+	if s := attrsOf(a); s != "schema=2" {
+		t.Errorf("got attributes %s after set, expected schema=2", s)
+	}
+
+	b, e := root.Move(db, []string{"a"}, []string{"b"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := attrsOf(b); s != "schema=2" {
+		t.Errorf("got attributes %s after move, expected schema=2", s)
+	}
+
+	part, e := root.CreateWithAttributes(db, []string{"p"}, []byte("partition"), directory.Attributes{"kind": []byte("tenant")})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := attrsOf(part); s != "kind=tenant" {
+		t.Errorf("got attributes %s for partition, expected kind=tenant", s)
+	}
+	moved, e := directory.MoveAcrossPartitions(db, root, []string{"b"}, []string{"p", "b"}, directory.MoveOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := attrsOf(moved); s != "schema=2" {
+		t.Errorf("got attributes %s after move across partitions, expected schema=2", s)
+	}
+
+	issues, e := directory.Check(db, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(issues) != 0 {
+		t.Errorf("got issues %v", issues)
+	}
+
+	if _, e = root.Remove(db, []string{"p", "b"}); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = moved.GetAttributes(db); !errors.Is(e, directory.ErrDirNotExists) {
+		t.Errorf("got %v reading attributes of removed directory, expected ErrDirNotExists", e)
+	}
+	if e = moved.SetAttributes(db, directory.Attributes{"schema": []byte("3")}); !errors.Is(e, directory.ErrDirNotExists) {
+		t.Errorf("got %v setting attributes of removed directory, expected ErrDirNotExists", e)
+	}
+}
+
+func TestDirectoryListDetailed(t *testing.T) {
+	db, root := newRoot()
+
+	dirs := make(map[string]directory.DirectorySubspace)
+	for _, name := range []string{"e", "b", "d", "a", "c"} {
+		ds, e := root.Create(db, []string{"top", name}, []byte("layer-" + name))
+		if e != nil {
+			t.Fatal(e)
+		}
+		dirs[name] = ds
+	}
+	part, e := root.Create(db, []string{"top", "p"}, []byte("partition"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = part.Create(db, []string{"x"}, nil); e != nil {
+		t.Fatal(e)
+	}
+
+	page := func(path []string, options directory.ListOptions) string {
+		subdirs, e := root.ListDetailed(db, path, options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		var names []string
+		for _, sd := range subdirs {
+			names = append(names, sd.Name)
+		}
+		return strings.Join(names, "")
+	}
+
+	var pages []string
+	options := directory.ListOptions{Limit: 2}
+	for {
+		p := page([]string{"top"}, options)
+		if p == "" {
+			break
+		}
+		pages = append(pages, p)
+		options.After = p[len(p)-1:]
+	}
+	if s := strings.Join(pages, ","); s != "ab,cd,ep" {
+		t.Errorf("got pages %s, expected ab,cd,ep", s)
+	}
+	if s := page([]string{"top"}, directory.ListOptions{Limit: 3, After: "d", Reverse: true}); s != "cba" {
+		t.Errorf("got %s listing in reverse after d, expected cba", s)
+	}
+	if s := page([]string{"top", "p"}, directory.ListOptions{}); s != "x" {
+		t.Errorf("got %s listing partition, expected x", s)
+	}
+
+	subdirs, e := root.ListDetailed(db, []string{"top"}, directory.ListOptions{After: "d"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(subdirs) != 2 {
+		t.Fatalf("got %d subdirectories after d, expected 2", len(subdirs))
+	}
+	if sd := subdirs[0]; string(sd.Layer) != "layer-e" || !bytes.Equal(sd.Prefix, dirs["e"].Bytes()) || sd.IsPartition {
+		t.Errorf("got %+v for e", sd)
+	}
+	if sd := subdirs[1]; string(sd.Layer) != "partition" || len(sd.Prefix) == 0 || !sd.IsPartition {
+		t.Errorf("got %+v for p", sd)
+	}
+
+	if _, e = root.ListDetailed(db, []string{"missing"}, directory.ListOptions{}); !errors.Is(e, directory.ErrDirNotExists) {
+		t.Errorf("got %v listing missing directory, expected ErrDirNotExists", e)
+	}
+}
+
+func TestCreateOrOpenAll(t *testing.T) {
+	db := memdb.New()
+	root := directory.NewDirectoryLayer(subspace.FromBytes([]byte{0xFE}), subspace.FromBytes([]byte{0x01}), true)
+
+	existing, e := root.Create(db, []string{"tenant", "users"}, []byte("table"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	dirs, e := directory.CreateOrOpenAll(db, root, []directory.PathSpec{
+		{Path: []string{"tenant", "p", "events"}, Layer: []byte("log")},
+		{Path: []string{"tenant", "users"}, Layer: []byte("table")},
+		{Path: []string{"tenant", "orders"}, Prefix: []byte{0x02}, Attributes: directory.Attributes{"owner": []byte("billing")}},
+		{Path: []string{"tenant", "p"}, Layer: []byte("partition")},
+		{Path: []string{"tenant"}},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(dirs) != 5 {
+		t.Errorf("got %d directories, expected 5", len(dirs))
+	}
+
+	if !bytes.Equal(dirs["/tenant/users"].Bytes(), existing.Bytes()) {
+		t.Errorf("got prefix %x for existing directory, expected %x", dirs["/tenant/users"].Bytes(), existing.Bytes())
+	}
+	if !bytes.Equal(dirs["/tenant/orders"].Bytes(), []byte{0x02}) {
+		t.Errorf("got prefix %x for manual prefix, expected 02", dirs["/tenant/orders"].Bytes())
+	}
+	if !dirs["/tenant/p"].IsPartition() {
+		t.Error("expected tenant/p to be a partition")
+	}
+	events := dirs["/tenant/p/events"]
+	if s := strings.Join(events.GetPath(), "/"); s != "tenant/p/events" || string(events.GetLayer()) != "log" {
+		t.Errorf("got path %s and layer %s, expected tenant/p/events and log", s, events.GetLayer())
+	}
+	opened, e := root.Open(db, []string{"tenant", "p", "events"}, []byte("log"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !bytes.Equal(opened.Bytes(), events.Bytes()) {
+		t.Errorf("got prefix %x when opened, expected %x", opened.Bytes(), events.Bytes())
+	}
+	attrs, e := dirs["/tenant/orders"].GetAttributes(db)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if string(attrs["owner"]) != "billing" {
+		t.Errorf("got attributes %v, expected owner billing", attrs)
+	}
+
+	issues, e := directory.Check(db, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(issues) != 0 {
+		t.Errorf("got issues %v", issues)
+	}
+
+	// An error creates none of the directories.
+	_, e = directory.CreateOrOpenAll(db, root, []directory.PathSpec{
+		{Path: []string{"other"}},
+		{Path: []string{"tenant", "users"}, Layer: []byte("queue")},
+	})
+	if !errors.Is(e, directory.ErrIncompatibleLayer) {
+		t.Errorf("got %v, expected ErrIncompatibleLayer", e)
+	}
+	if ok, _ := root.Exists(db, []string{"other"}); ok {
+		t.Error("directory created by failed CreateOrOpenAll")
+	}
+}
+
+func TestDirectoryPaths(t *testing.T) {
+	for _, s := range []string{"/", "/app/users/index", `/a\/b/c\\d`, `/\\\/`} {
+		path, e := directory.ParsePath(s)
+		if e != nil {
+			t.Errorf("parsing %q: %v", s, e)
+			continue
+		}
+		if f := directory.FormatPath(path); f != s {
+			t.Errorf("got %q formatting %q, expected %q", f, path, s)
+		}
+	}
+
+	path, e := directory.ParsePath(`app/a\/b`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(path) != 2 || path[0] != "app" || path[1] != "a/b" {
+		t.Errorf("got %q, expected [app a/b]", path)
+	}
+	if path, e = directory.ParsePath(""); e != nil || len(path) != 0 {
+		t.Errorf("got %q, %v parsing empty string, expected empty path", path, e)
+	}
+
+	for _, s := range []string{"//", "/a//b", "/a/", `/a\`, `/a\b`, "/\xff"} {
+		_, e := directory.ParsePath(s)
+		var pe *directory.PathError
+		if !errors.As(e, &pe) || !errors.Is(e, directory.ErrInvalidPath) || pe.Path != s {
+			t.Errorf("got %v parsing %q, expected a PathError", e, s)
+		}
+	}
+
+	db, root := newRoot()
+
+	if _, e = root.Create(db, []string{"a", ""}, nil); !errors.Is(e, directory.ErrInvalidPath) {
+		t.Errorf("got %v creating directory with empty component, expected ErrInvalidPath", e)
+	}
+	if _, e = root.CreateOrOpen(db, []string{"", "b"}, nil); !errors.Is(e, directory.ErrInvalidPath) {
+		t.Errorf("got %v creating directory with empty parent, expected ErrInvalidPath", e)
+	}
+	if ok, _ := root.Exists(db, []string{"a"}); ok {
+		t.Error("parent of invalid directory was created")
+	}
+	if _, e = root.Create(db, []string{"a"}, nil); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = root.Move(db, []string{"a"}, []string{"\xff"}); !errors.Is(e, directory.ErrInvalidPath) {
+		t.Errorf("got %v moving directory to invalid path, expected ErrInvalidPath", e)
+	}
+	_, e = directory.CreateOrOpenAll(db, root, []directory.PathSpec{{Path: []string{"a", "b", ""}}})
+	if !errors.Is(e, directory.ErrInvalidPath) {
+		t.Errorf("got %v from CreateOrOpenAll, expected ErrInvalidPath", e)
+	}
+
+	// A path shorter than that of the partition containing a directory is
+	// outside the partition.
+	if _, e = root.Create(db, []string{"p"}, []byte("partition")); e != nil {
+		t.Fatal(e)
+	}
+	d, e := root.Create(db, []string{"p", "d"}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = d.MoveTo(db, []string{}); !errors.Is(e, directory.ErrPartitionMove) {
+		t.Errorf("got %v moving directory to empty path, expected ErrPartitionMove", e)
+	}
+}
+
+func TestHighContentionAllocator(t *testing.T) {
+	allocate := func(db fdb.Database, seed int64, n int) ([]int64, directory.AllocatorStats) {
+		hca := directory.NewHighContentionAllocator(subspace.Sub("ids"), directory.AllocatorOptions{Rand: rand.New(rand.NewSource(seed))})
+		ids, e := hca.AllocateN(db, n)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return ids, hca.Stats()
+	}
+
+	// The same source allocates the same values.
+	a, _ := allocate(memdb.New(), 1, 20)
+	b, _ := allocate(memdb.New(), 1, 20)
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("got %v and %v from the same source", a, b)
+	}
+
+	// Allocators sharing a subspace allocate distinct values.
+	db := memdb.New()
+	first, _ := allocate(db, 1, 10)
+	second, stats := allocate(db, 1, 10)
+	seen := make(map[int64]bool)
+	for _, id := range append(first, second...) {
+		if seen[id] {
+			t.Errorf("%d allocated twice", id)
+		}
+		if id < 0 || id >= 64 {
+			t.Errorf("%d allocated outside the first window", id)
+		}
+		seen[id] = true
+	}
+	if stats.Allocations != 10 || stats.Retries == 0 || stats.WindowAdvances != 0 {
+		t.Errorf("got stats %+v, expected 10 allocations with retries", stats)
+	}
+
+	// A window of 4 advances after each allocation.
+	db = memdb.New()
+	hca := directory.NewHighContentionAllocator(subspace.Sub("ids"), directory.AllocatorOptions{
+		WindowSize: func(start int64) int64 { return 4 },
+		Rand: rand.New(rand.NewSource(1)),
+	})
+	for i := int64(0); i < 5; i++ {
+		id, e := hca.Allocate(db)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if id < 4 * i || id >= 4 * (i + 1) {
+			t.Errorf("got %d for allocation %d, expected a value in [%d, %d)", id, i, 4 * i, 4 * (i + 1))
+		}
+	}
+	ids, e := hca.AllocateN(db, 3)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i, id := range ids {
+		if w := int64(5 + i); id < 4 * w || id >= 4 * (w + 1) {
+			t.Errorf("got %d for batch allocation %d, expected a value in [%d, %d)", id, i, 4 * w, 4 * (w + 1))
+		}
+	}
+	if s := hca.Stats(); s.Allocations != 8 || s.Retries != 0 || s.WindowAdvances != 7 {
+		t.Errorf("got stats %+v, expected 8 allocations and 7 window advances", s)
+	}
+
+	hca = directory.NewHighContentionAllocator(subspace.Sub("ids"), directory.AllocatorOptions{
+		WindowSize: func(start int64) int64 { return 0 },
+	})
+	if _, e = hca.Allocate(memdb.New()); e == nil {
+		t.Error("expected error allocating with an empty window")
+	}
+}
+
+func TestHighContentionAllocatorConcurrency(t *testing.T) {
+	db := memdb.New()
+	newHCA := func(seed int64) *directory.HighContentionAllocator {
+		return directory.NewHighContentionAllocator(subspace.Sub("ids"), directory.AllocatorOptions{
+			WindowSize: func(start int64) int64 { return 8 },
+			Rand: rand.New(rand.NewSource(seed)),
+		})
+	}
+	a, b := newHCA(1), newHCA(3)
+
+	seen := make(map[int64]bool)
+	record := func(id int64) {
+		if seen[id] {
+			t.Errorf("%d allocated twice", id)
+		}
+		seen[id] = true
+	}
+
+	id, e := a.Allocate(db)
+	if e != nil {
+		t.Fatal(e)
+	}
+	record(id)
+
+	// A transaction allocating from a window does not conflict with another
+	// that advances the window before it commits.
+	tr, e := db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	pending, e := a.Allocate(tr)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i := 0; i < 3; i++ {
+		id, e = b.Allocate(db)
+		if e != nil {
+			t.Fatal(e)
+		}
+		record(id)
+	}
+	if s := b.Stats(); s.WindowAdvances != 1 {
+		t.Fatalf("got stats %+v, expected the window to advance", s)
+	}
+	if e = tr.Commit().Get(); e != nil {
+		t.Fatalf("got %v committing allocation from an advanced window", e)
+	}
+	record(pending)
+
+	// Allocators used concurrently, in a shared transaction or in separate
+	// ones, allocate distinct values.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := make([]int64, 0, 200)
+	allocate := func(hca *directory.HighContentionAllocator, tr fdb.Transactor) {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			id, e := hca.Allocate(tr)
+			if e != nil {
+				t.Error(e)
+				return
+			}
+			mu.Lock()
+			ids = append(ids, id)
+			mu.Unlock()
+		}
+	}
+
+	tr, e = db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	shared := newHCA(4)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go allocate(shared, tr)
+	}
+	wg.Wait()
+	if e = tr.Commit().Get(); e != nil {
+		t.Fatal(e)
+	}
+
+	for i := int64(0); i < 5; i++ {
+		wg.Add(1)
+		go allocate(newHCA(10 + i), db)
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		record(id)
+	}
+}
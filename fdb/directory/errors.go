@@ -0,0 +1,124 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by directory operations. Operations may return errors with
+// more specific messages that match these errors with errors.Is.
+var (
+	// ErrDirNotExists is returned when a directory (or, when creating or
+	// moving a directory, a directory it requires) does not exist.
+	ErrDirNotExists = errors.New("the directory does not exist")
+
+	// ErrDirAlreadyExists is returned when creating or moving a directory to
+	// a path at which a directory already exists.
+	ErrDirAlreadyExists = errors.New("the directory already exists")
+
+	// ErrIncompatibleLayer is returned when opening a directory with a layer
+	// other than the one with which it was created.
+	ErrIncompatibleLayer = errors.New("the directory was created with an incompatible layer")
+
+	// ErrPartitionMove is returned when moving a directory into or out of a
+	// directory partition.
+	ErrPartitionMove = errors.New("cannot move between partitions")
+
+	// ErrPartitionSubspace is returned by the AsSubspace, PackWithVersionstamp,
+	// Range, StringPrefixRange and BytesPrefixRange methods of a
+	// DirectorySubspace for the root of a directory partition, which cannot
+	// store keys itself.
+	ErrPartitionSubspace = errors.New("cannot use the root of a directory partition as a subspace")
+
+	// ErrInvalidPath is returned when a path cannot be parsed, or when
+	// creating or moving a directory to a path with an empty or otherwise
+	// invalid component. Such errors are PathErrors.
+	ErrInvalidPath = errors.New("invalid directory path")
+
+	// ErrVersion is returned when the directory metadata in the database was
+	// written by an incompatible version of the directory layer. Such errors
+	// are VersionErrors, from which the version may be retrieved with
+	// errors.As.
+	ErrVersion = errors.New("incompatible directory layer version")
+)
+
+// VersionError is the error returned when the directory metadata in the
+// database has a version that this directory layer cannot read or (if ReadOnly
+// is true) cannot modify. A VersionError matches ErrVersion with errors.Is.
+type VersionError struct {
+	// Major, Minor and Micro are the components of the version of the
+	// directory metadata in the database.
+	Major, Minor, Micro int32
+
+	// ReadOnly is true if the directory metadata may be read, but not
+	// modified.
+	ReadOnly bool
+}
+
+func (e *VersionError) Error() string {
+	if e.ReadOnly {
+		return fmt.Sprintf("directory with version %d.%d.%d is read-only when opened using directory layer %d.%d.%d", e.Major, e.Minor, e.Micro, _MAJORVERSION, _MINORVERSION, _MICROVERSION)
+	}
+	return fmt.Sprintf("cannot load directory with version %d.%d.%d using directory layer %d.%d.%d", e.Major, e.Minor, e.Micro, _MAJORVERSION, _MINORVERSION, _MICROVERSION)
+}
+
+// Is reports whether target is ErrVersion.
+func (e *VersionError) Is(target error) bool {
+	return target == ErrVersion
+}
+
+// PathError is the error returned for an invalid path. A PathError matches
+// ErrInvalidPath with errors.Is.
+type PathError struct {
+	// Path is the invalid path, as passed to ParsePath or formatted by
+	// FormatPath.
+	Path string
+
+	// Reason describes why the path is invalid.
+	Reason string
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("invalid directory path %q: %s", e.Path, e.Reason)
+}
+
+// Is reports whether target is ErrInvalidPath.
+func (e *PathError) Is(target error) bool {
+	return target == ErrInvalidPath
+}
+
+// directoryError is an error with a more specific message than, but otherwise
+// matching, one of the errors above.
+type directoryError struct {
+	msg string
+	err error
+}
+
+func (e directoryError) Error() string {
+	return e.msg
+}
+
+func (e directoryError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,140 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"bytes"
+)
+
+// ListOptions control the subdirectories returned by ListDetailed.
+type ListOptions struct {
+	// Limit is the maximum number of subdirectories to return. A Limit of 0
+	// returns all subdirectories.
+	Limit int
+
+	// After is the name of the subdirectory after which the listing begins,
+	// typically the Name of the last Subdirectory returned by a previous
+	// call. If After is empty, the listing begins with the first (or, if
+	// Reverse is set, the last) subdirectory.
+	After string
+
+	// Reverse lists subdirectories in descending, rather than ascending, order
+	// of their names.
+	Reverse bool
+}
+
+// Subdirectory describes an immediate subdirectory returned by ListDetailed.
+type Subdirectory struct {
+	// Name is the last component of the path of the subdirectory.
+	Name string
+
+	// Prefix is the prefix of the keys stored in the subdirectory (or, for a
+	// partition, in the directories it contains).
+	Prefix []byte
+
+	// Layer is the layer specified when the subdirectory was created.
+	Layer []byte
+
+	// IsPartition is true if the subdirectory is a directory partition.
+	IsPartition bool
+}
+
+func (dl directoryLayer) ListDetailed(rt fdb.ReadTransactor, path []string, options ListOptions) ([]Subdirectory, error) {
+	r, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
+		if e := dl.checkVersion(rtr, nil); e != nil {
+			return nil, e
+		}
+
+		node := dl.find(rtr, path).prefetchMetadata(rtr)
+		if !node.exists() {
+			return nil, ErrDirNotExists
+		}
+
+		if node.isInPartition(nil, true) {
+			nc, e := node.getContents(dl, nil)
+			if e != nil {
+				return nil, e
+			}
+			return nc.ListDetailed(rtr, node.getPartitionSubpath(), options)
+		}
+
+		return dl.subdirectories(rtr, node.subspace, options)
+	})
+	if e != nil {
+		return nil, e
+	}
+	return r.([]Subdirectory), nil
+}
+
+// subdirectories reads the subdirectories of node selected by options, reading
+// only as many entries of node as are returned.
+func (dl directoryLayer) subdirectories(rtr fdb.ReadTransaction, node subspace.Subspace, options ListOptions) ([]Subdirectory, error) {
+	sd := node.Sub(_SUBDIRS)
+
+	begin, end := sd.FDBRangeKeys()
+	kr := fdb.KeyRange{Begin: begin, End: end}
+	if options.After != "" {
+		after := sd.Sub(options.After).FDBKey()
+		if options.Reverse {
+			kr.End = after
+		} else {
+			kr.Begin = append(after, 0x00)
+		}
+	}
+
+	kvs, e := rtr.GetRange(kr, fdb.RangeOptions{Limit: options.Limit, Reverse: options.Reverse}).GetSliceWithError()
+	if e != nil {
+		return nil, e
+	}
+
+	layers := make([]fdb.FutureByteSlice, len(kvs))
+	for i, kv := range kvs {
+		layers[i] = rtr.Get(dl.nodeWithPrefix(kv.Value).Sub([]byte("layer")))
+	}
+
+	ret := make([]Subdirectory, len(kvs))
+	for i, kv := range kvs {
+		p, e := sd.Unpack(kv.Key)
+		if e != nil {
+			return nil, e
+		}
+
+		layer, e := layers[i].Get()
+		if e != nil {
+			return nil, e
+		}
+		if layer == nil {
+			layer = []byte{}
+		}
+
+		ret[i] = Subdirectory{
+			Name: p[0].(string),
+			Prefix: kv.Value,
+			Layer: layer,
+			IsPartition: bytes.Equal(layer, []byte("partition")),
+		}
+	}
+
+	return ret, nil
+}
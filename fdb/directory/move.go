@@ -0,0 +1,441 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"errors"
+)
+
+// MoveOptions bounds the size of the transactions used by
+// MoveAcrossPartitions. The zero value of MoveOptions represents the defaults.
+type MoveOptions struct {
+	// BatchKeys is the maximum number of keys copied in each transaction. A
+	// value of 0 indicates the default of 10000.
+	BatchKeys int
+
+	// BatchBytes is the maximum number of bytes (of keys and values) copied
+	// in each transaction, after which the transaction is committed once the
+	// key being copied is written. A value of 0 indicates the default of 1MB.
+	BatchBytes int
+}
+
+// movedDirectory is a directory (or subdirectory) being moved by
+// MoveAcrossPartitions.
+type movedDirectory struct {
+	path []string // relative to the moved directory
+	layer []byte
+	source, destination []byte
+}
+
+// moveCheckpoint is the progress of MoveAcrossPartitions, stored in the node
+// subspace of the root directory until the move is complete.
+type moveCheckpoint struct {
+	dirs []movedDirectory
+	index int // of the directory being copied
+	cursor []byte // the next key to copy, relative to the prefix of the directory
+}
+
+func stringsTuple(s []string) tuple.Tuple {
+	t := make(tuple.Tuple, len(s))
+	for i := range s {
+		t[i] = s[i]
+	}
+	return t
+}
+
+func (mc moveCheckpoint) pack() []byte {
+	dirs := make(tuple.Tuple, len(mc.dirs))
+	for i, d := range mc.dirs {
+		dirs[i] = tuple.Tuple{stringsTuple(d.path), d.layer, d.source, d.destination}
+	}
+	return tuple.Tuple{int64(mc.index), mc.cursor, dirs}.Pack()
+}
+
+var errInvalidCheckpoint = errors.New("invalid directory move checkpoint")
+
+func unpackCheckpoint(b []byte) (mc moveCheckpoint, e error) {
+	var t struct {
+		Index int
+		Cursor []byte
+		Dirs []struct {
+			Path []string
+			Layer, Source, Destination []byte
+		}
+	}
+	if e = tuple.Unmarshal(b, &t); e != nil {
+		return mc, errInvalidCheckpoint
+	}
+
+	mc.index, mc.cursor = t.Index, t.Cursor
+	for _, d := range t.Dirs {
+		mc.dirs = append(mc.dirs, movedDirectory{d.Path, d.Layer, d.Source, d.Destination})
+	}
+	if len(mc.dirs) == 0 || mc.index > len(mc.dirs) {
+		return mc, errInvalidCheckpoint
+	}
+	return mc, nil
+}
+
+// MoveAcrossPartitions moves the directory at oldPath to newPath (both
+// relative to root, which must be a root directory as returned by Root,
+// NewDirectoryLayer or NewCachedDirectoryLayer), which may be in different
+// directory partitions, and returns the directory (at its new location) and
+// its contents as a DirectorySubspace. If oldPath and newPath are in the same
+// partition, MoveAcrossPartitions is equivalent to Move.
+//
+// Otherwise, since the prefix of a directory belongs to its partition, the
+// directory and each of its subdirectories are given new prefixes in the
+// destination partition, and their contents are copied, in as many
+// transactions as the bounds in options require. Only once all contents have
+// been copied are the directories created at newPath and removed from oldPath,
+// in a single transaction. Until then, the directory remains at oldPath. The
+// directory must neither be nor contain a directory partition.
+//
+// The progress of the move is recorded in the database after each
+// transaction. If MoveAcrossPartitions does not complete (for example,
+// because the process exits), calling it again with the same paths resumes the
+// move. The contents of the directory must not be modified during the move,
+// since modifications made after they are copied are lost. If the directories
+// at oldPath are moved or removed before the move completes, the copied
+// contents are removed and MoveAcrossPartitions returns an error.
+//
+// If t is a Transaction rather than a Database, the entire move is performed
+// in that transaction.
+func MoveAcrossPartitions(t fdb.Transactor, root Directory, oldPath, newPath []string, options MoveOptions) (DirectorySubspace, error) {
+	rootDL, e := rootLayer(root)
+	if e != nil {
+		return nil, e
+	}
+	if len(oldPath) == 0 {
+		return nil, errors.New("the root directory cannot be moved")
+	}
+	if len(newPath) == 0 {
+		return nil, directoryError{"the destination directory already exists. Remove it first", ErrDirAlreadyExists}
+	}
+	if e := validatePath(newPath); e != nil {
+		return nil, e
+	}
+	if len(newPath) >= len(oldPath) && stringsEqual(oldPath, newPath[:len(oldPath)]) {
+		return nil, errors.New("the destination directory cannot be a subdirectory of the source directory")
+	}
+
+	if options.BatchKeys == 0 {
+		options.BatchKeys = 10000
+	}
+	if options.BatchBytes == 0 {
+		options.BatchBytes = 1 << 20
+	}
+
+	checkpointKey := rootDL.rootNode.Sub([]byte("move"), stringsTuple(oldPath), stringsTuple(newPath))
+
+	// Begin the move (or move within a partition), unless resuming it.
+	r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		if tr.Get(checkpointKey).MustGet() != nil {
+			return nil, nil
+		}
+		return rootDL.beginMove(tr, checkpointKey, oldPath, newPath)
+	})
+	if e != nil {
+		return nil, e
+	}
+	if r != nil {
+		return r.(DirectorySubspace), nil
+	}
+
+	for {
+		r, e := t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+			return copyBatch(tr, checkpointKey, options)
+		})
+		if e != nil {
+			return nil, e
+		}
+		if r.(bool) {
+			break
+		}
+	}
+
+	var moveErr error
+	r, e = t.Transact(func (tr fdb.Transaction) (interface{}, error) {
+		ds, e := rootDL.finishMove(tr, checkpointKey, oldPath, newPath)
+		if e == errMoveAbandoned {
+			// Commit the removal of the copied contents.
+			moveErr = e
+			return nil, nil
+		}
+		return ds, e
+	})
+	if e != nil {
+		return nil, e
+	}
+	if moveErr != nil {
+		return nil, moveErr
+	}
+	return r.(DirectorySubspace), nil
+}
+
+var errMoveAbandoned = errors.New("the source directory was changed before it could be moved between partitions")
+
+// sourceOf returns the directory layer containing the directory at path, and
+// the directory itself. The directory layer containing a partition is that of
+// its parent.
+func (dl directoryLayer) sourceOf(tr fdb.Transaction, path []string) (directoryLayer, DirectorySubspace, error) {
+	ds, e := dl.Open(tr, path, nil)
+	if e != nil {
+		if errors.Is(e, ErrDirNotExists) {
+			return directoryLayer{}, nil, directoryError{"the source directory does not exist", ErrDirNotExists}
+		}
+		return directoryLayer{}, nil, e
+	}
+
+	switch d := ds.(type) {
+	case directoryPartition:
+		return d.parentDirectoryLayer, d, nil
+	case directorySubspace:
+		return d.dl, d, nil
+	}
+	return directoryLayer{}, nil, errors.New("unexpected directory type")
+}
+
+// destinationOf returns the directory layer that would contain a directory
+// created at path, and the node of the parent of that directory.
+func (dl directoryLayer) destinationOf(tr fdb.Transaction, path []string) (directoryLayer, subspace.Subspace, error) {
+	if len(path) == 1 {
+		return dl, dl.rootNode, nil
+	}
+
+	ds, e := dl.Open(tr, path[:len(path)-1], nil)
+	if e != nil {
+		if errors.Is(e, ErrDirNotExists) {
+			return directoryLayer{}, nil, directoryError{"the parent of the destination directory does not exist. Create it first", ErrDirNotExists}
+		}
+		return directoryLayer{}, nil, e
+	}
+
+	switch d := ds.(type) {
+	case directoryPartition:
+		return d.directoryLayer, d.directoryLayer.rootNode, nil
+	case directorySubspace:
+		return d.dl, d.dl.nodeWithPrefix(d.Bytes()), nil
+	}
+	return directoryLayer{}, nil, errors.New("unexpected directory type")
+}
+
+// collectMoved returns the directory at path (relative to dl) and its
+// subdirectories, without their destination prefixes.
+func collectMoved(tr fdb.Transaction, ds directorySubspace) ([]movedDirectory, error) {
+	dirs := []movedDirectory{{path: []string{}, layer: ds.layer, source: ds.Bytes()}}
+
+	e := walk(tr, ds, func(info DirectoryInfo) error {
+		if string(info.Layer) == "partition" {
+			return directoryError{"cannot move a directory containing a directory partition between partitions", ErrPartitionMove}
+		}
+		dirs = append(dirs, movedDirectory{path: info.Path[len(ds.path):], layer: info.Layer, source: info.Prefix})
+		return nil
+	})
+	return dirs, e
+}
+
+func (dl directoryLayer) beginMove(tr fdb.Transaction, checkpointKey fdb.KeyConvertible, oldPath, newPath []string) (DirectorySubspace, error) {
+	srcDL, ds, e := dl.sourceOf(tr, oldPath)
+	if e != nil {
+		return nil, e
+	}
+	dstDL, parentNode, e := dl.destinationOf(tr, newPath)
+	if e != nil {
+		return nil, e
+	}
+
+	if stringsEqual(srcDL.path, dstDL.path) {
+		return srcDL.Move(tr, oldPath[len(srcDL.path):], newPath[len(dstDL.path):])
+	}
+
+	src, ok := ds.(directorySubspace)
+	if !ok {
+		return nil, directoryError{"cannot move a directory partition between partitions", ErrPartitionMove}
+	}
+
+	if tr.Get(parentNode.Sub(_SUBDIRS, newPath[len(newPath)-1])).MustGet() != nil {
+		return nil, directoryError{"the destination directory already exists. Remove it first", ErrDirAlreadyExists}
+	}
+	if e := srcDL.checkVersion(tr, tr); e != nil {
+		return nil, e
+	}
+	if e := dstDL.checkVersion(tr, tr); e != nil {
+		return nil, e
+	}
+
+	dirs, e := collectMoved(tr, src)
+	if e != nil {
+		return nil, e
+	}
+
+	prefixes, e := dstDL.allocatePrefixes(tr, tr, len(dirs))
+	if e != nil {
+		return nil, e
+	}
+	for i := range dirs {
+		dirs[i].destination = prefixes[i]
+	}
+
+	tr.Set(checkpointKey, moveCheckpoint{dirs: dirs}.pack())
+	return nil, nil
+}
+
+// copyBatch copies the next batch of contents of the move recorded at
+// checkpointKey, and reports whether all contents have been copied.
+func copyBatch(tr fdb.Transaction, checkpointKey fdb.KeyConvertible, options MoveOptions) (bool, error) {
+	b := tr.Get(checkpointKey).MustGet()
+	if b == nil {
+		return false, errInvalidCheckpoint
+	}
+	mc, e := unpackCheckpoint(b)
+	if e != nil {
+		return false, e
+	}
+
+	var keys, size int
+	for mc.index < len(mc.dirs) && keys < options.BatchKeys && size < options.BatchBytes {
+		d := mc.dirs[mc.index]
+
+		kr, e := fdb.PrefixRange(d.source)
+		if e != nil {
+			return false, e
+		}
+		kr.Begin = fdb.Key(append(append([]byte{}, d.source...), mc.cursor...))
+
+		ri := tr.Snapshot().GetRange(kr, fdb.RangeOptions{Limit: options.BatchKeys - keys}).Iterator()
+		exhausted := true
+		for ri.Advance() {
+			kv := ri.MustGet()
+			suffix := kv.Key[len(d.source):]
+			tr.Set(fdb.Key(append(append([]byte{}, d.destination...), suffix...)), kv.Value)
+
+			keys++
+			size += len(kv.Key) + len(kv.Value)
+			mc.cursor = append(append([]byte{}, suffix...), 0x00)
+
+			if keys >= options.BatchKeys || size >= options.BatchBytes {
+				exhausted = false
+				break
+			}
+		}
+
+		if exhausted {
+			mc.index++
+			mc.cursor = nil
+		}
+	}
+
+	tr.Set(checkpointKey, mc.pack())
+	return mc.index == len(mc.dirs), nil
+}
+
+func (dl directoryLayer) finishMove(tr fdb.Transaction, checkpointKey fdb.KeyConvertible, oldPath, newPath []string) (DirectorySubspace, error) {
+	b := tr.Get(checkpointKey).MustGet()
+	if b == nil {
+		return nil, errInvalidCheckpoint
+	}
+	mc, e := unpackCheckpoint(b)
+	if e != nil {
+		return nil, e
+	}
+	tr.Clear(checkpointKey)
+
+	// The directories must not have changed since the move began. Any other
+	// error (such as a retryable fdb.Error) is returned without abandoning the
+	// move, so that it is retried or can be resumed.
+	srcDL, ds, e := dl.sourceOf(tr, oldPath)
+	src, ok := ds.(directorySubspace)
+	changed := errors.Is(e, ErrDirNotExists) || e == nil && !ok
+	if e == nil && ok {
+		var current []movedDirectory
+		current, e = collectMoved(tr, src)
+		changed = errors.Is(e, ErrPartitionMove) || e == nil && !sameDirectories(current, mc.dirs)
+	}
+	if changed {
+		for _, d := range mc.dirs {
+			kr, e := fdb.PrefixRange(d.destination)
+			if e != nil {
+				return nil, e
+			}
+			tr.ClearRange(kr)
+		}
+		return nil, errMoveAbandoned
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	dstDL, parentNode, e := dl.destinationOf(tr, newPath)
+	if e != nil {
+		return nil, e
+	}
+	if tr.Get(parentNode.Sub(_SUBDIRS, newPath[len(newPath)-1])).MustGet() != nil {
+		return nil, directoryError{"the destination directory already exists. Remove it first", ErrDirAlreadyExists}
+	}
+
+	// Create the directories at newPath.
+	nodes := make(map[string]subspace.Subspace)
+	for _, d := range mc.dirs {
+		node := dstDL.nodeWithPrefix(d.destination)
+		tr.Set(node.Sub([]byte("layer")), d.layer)
+
+		attrs, e := readAttributes(tr, srcDL.nodeWithPrefix(d.source))
+		if e != nil {
+			return nil, e
+		}
+		setAttributes(tr, node, attrs)
+
+		if len(d.path) == 0 {
+			tr.Set(parentNode.Sub(_SUBDIRS, newPath[len(newPath)-1]), d.destination)
+		} else {
+			parent := nodes[string(stringsTuple(d.path[:len(d.path)-1]).Pack())]
+			tr.Set(parent.Sub(_SUBDIRS, d.path[len(d.path)-1]), d.destination)
+		}
+		nodes[string(stringsTuple(d.path).Pack())] = node
+	}
+
+	// Remove the directories at oldPath.
+	if e := srcDL.removeRecursive(tr, srcDL.nodeWithPrefix(src.Bytes())); e != nil {
+		return nil, e
+	}
+	srcDL.removeFromParent(tr, oldPath[len(srcDL.path):])
+	dl.bumpMetadataVersion(tr)
+
+	return dstDL.contentsOfNode(dstDL.nodeWithPrefix(mc.dirs[0].destination), newPath[len(dstDL.path):], mc.dirs[0].layer)
+}
+
+func sameDirectories(a, b []movedDirectory) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !stringsEqual(a[i].path, b[i].path) || !bytes.Equal(a[i].layer, b[i].layer) || !bytes.Equal(a[i].source, b[i].source) {
+			return false
+		}
+	}
+	return true
+}
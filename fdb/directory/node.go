@@ -57,7 +57,7 @@ func (n *node) layer(rtr fdb.ReadTransaction) fdb.FutureByteSlice {
 	return n._layer
 }
 
-func (n *node) isInPartition(tr *fdb.Transaction, includeEmptySubpath bool) bool {
+func (n *node) isInPartition(tr fdb.WriteTransaction, includeEmptySubpath bool) bool {
 	return n.exists() && bytes.Compare(n._layer.MustGet(), []byte("partition")) == 0 && (includeEmptySubpath || len(n.targetPath) > len(n.path))
 }
 
@@ -65,6 +65,6 @@ func (n *node) getPartitionSubpath() []string {
 	return n.targetPath[len(n.path):]
 }
 
-func (n *node) getContents(dl directoryLayer, tr *fdb.Transaction) (DirectorySubspace, error) {
+func (n *node) getContents(dl directoryLayer, tr fdb.WriteTransaction) (DirectorySubspace, error) {
 	return dl.contentsOfNode(n.subspace, n.path, n._layer.MustGet())
 }
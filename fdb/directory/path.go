@@ -0,0 +1,103 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParsePath parses a path written as its components separated by "/", such as
+// "/app/users/index", into a slice of strings. The leading "/" is optional, and
+// "/" or an empty string is the empty path. Within a component, "\/" stands for
+// "/" and "\\" for "\".
+//
+// ParsePath returns a PathError if a component is empty or otherwise invalid,
+// or if s ends with an unpaired "\". Paths returned by ParsePath may be
+// formatted again with FormatPath.
+func ParsePath(s string) ([]string, error) {
+	rest := strings.TrimPrefix(s, "/")
+	if rest == "" {
+		return []string{}, nil
+	}
+
+	var path []string
+	var component strings.Builder
+
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; c {
+		case '\\':
+			if i + 1 == len(rest) || (rest[i+1] != '\\' && rest[i+1] != '/') {
+				return nil, &PathError{s, fmt.Sprintf("invalid escape at offset %d", len(s) - len(rest) + i)}
+			}
+			i++
+			component.WriteByte(rest[i])
+		case '/':
+			path = append(path, component.String())
+			component.Reset()
+		default:
+			component.WriteByte(c)
+		}
+	}
+	path = append(path, component.String())
+
+	if e := validatePath(path); e != nil {
+		e.Path = s
+		return nil, e
+	}
+	return path, nil
+}
+
+// FormatPath returns path written as its components separated by "/", with a
+// leading "/", escaping any "/" or "\" within a component. The result may be
+// parsed by ParsePath.
+func FormatPath(path []string) string {
+	var b strings.Builder
+	if len(path) == 0 {
+		b.WriteByte('/')
+	}
+	for _, name := range path {
+		b.WriteByte('/')
+		for i := 0; i < len(name); i++ {
+			if name[i] == '/' || name[i] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(name[i])
+		}
+	}
+	return b.String()
+}
+
+// validatePath returns a PathError if any component of path is empty or is not
+// valid UTF-8.
+func validatePath(path []string) *PathError {
+	for i, name := range path {
+		if name == "" {
+			return &PathError{FormatPath(path), fmt.Sprintf("component %d is empty", i)}
+		}
+		if !utf8.ValidString(name) {
+			return &PathError{FormatPath(path), fmt.Sprintf("component %d is not valid UTF-8", i)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// FoundationDB Go Directory Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package directory
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DirectoryInfo describes a directory visited by Walk.
+type DirectoryInfo struct {
+	// Path is the absolute path of the directory.
+	Path []string
+
+	// Layer is the layer specified when the directory was created. The layer
+	// of a directory partition is "partition".
+	Layer []byte
+
+	// Prefix is the prefix of the keys stored in the directory (or, for a
+	// partition, in the directories it contains).
+	Prefix []byte
+
+	// Partition is the absolute path of the innermost directory partition
+	// containing the directory, or nil if it is not in a partition.
+	Partition []string
+}
+
+// SkipDir may be returned by a WalkFunc to prevent Walk from visiting the
+// subdirectories of the directory passed to it. It is not returned as an error
+// by Walk.
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc is the type of the function called by Walk for each directory. If it
+// returns an error other than SkipDir, Walk stops and returns the error.
+type WalkFunc func(info DirectoryInfo) error
+
+// Walk visits every subdirectory of root (but not root itself), including the
+// contents of directory partitions, calling fn for each. Directories are
+// visited in depth-first order, each before its subdirectories, and the
+// subdirectories of a directory in the order of their names. Walk reads the
+// metadata of each directory once, without opening it.
+//
+// The entire walk is performed in a single transaction. As with any
+// transactional function, fn may be called again for the same directories if
+// the transaction is retried. A walk of a tree too large to be read within the
+// five second lifetime of a transaction never completes; such a tree may be
+// walked in parts, in separate transactions, by calling Walk for each of the
+// subdirectories of root returned by ListDetailed (which may itself be paged
+// using the After option).
+func Walk(rt fdb.ReadTransactor, root Directory, fn WalkFunc) error {
+	_, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
+		return nil, walk(rtr, root, fn)
+	})
+	return e
+}
+
+func walk(rtr fdb.ReadTransaction, d Directory, fn WalkFunc) error {
+	var dl directoryLayer
+	switch d := d.(type) {
+	case directoryLayer:
+		dl = d
+	case cachedDirectoryLayer:
+		dl = d.directoryLayer
+	case directoryPartition:
+		dl = d.directoryLayer
+	case directorySubspace:
+		dl = d.dl
+	default:
+		return errors.New("cannot walk a directory of an unknown type")
+	}
+
+	if e := dl.checkVersion(rtr, nil); e != nil {
+		return e
+	}
+
+	node := dl.rootNode
+	if ds, ok := d.(directorySubspace); ok {
+		n := dl.find(rtr, dl.partitionSubpath(ds.path, nil)).prefetchMetadata(rtr)
+		if !n.exists() {
+			return ErrDirNotExists
+		}
+		node = n.subspace
+	}
+
+	return walkNode(rtr, dl, node, d.GetPath(), fn)
+}
+
+// walkNode visits the subdirectories of node, a node of dl at the absolute path
+// path.
+func walkNode(rtr fdb.ReadTransaction, dl directoryLayer, node subspace.Subspace, path []string, fn WalkFunc) error {
+	subdirs, e := dl.subdirectories(rtr, node, ListOptions{})
+	if e != nil {
+		return e
+	}
+
+	for _, sd := range subdirs {
+		subpath := make([]string, len(path) + 1)
+		copy(subpath, path)
+		subpath[len(path)] = sd.Name
+
+		info := DirectoryInfo{Path: subpath, Layer: sd.Layer, Prefix: sd.Prefix}
+		if len(dl.path) > 0 {
+			info.Partition = dl.path
+		}
+
+		e = fn(info)
+		if e == SkipDir {
+			continue
+		}
+		if e != nil {
+			return e
+		}
+
+		if sd.IsPartition {
+			pdl := dl.partitionLayer(sd.Prefix, subpath)
+			e = walkNode(rtr, pdl, pdl.rootNode, subpath, fn)
+		} else {
+			e = walkNode(rtr, dl, dl.nodeWithPrefix(sd.Prefix), subpath, fn)
+		}
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// collect returns the DirectoryInfo of every subdirectory of root, in the order
+// visited by Walk.
+func collect(rt fdb.ReadTransactor, root Directory) ([]DirectoryInfo, error) {
+	var infos []DirectoryInfo
+	_, e := rt.ReadTransact(func (rtr fdb.ReadTransaction) (interface{}, error) {
+		infos = nil
+		return nil, walk(rtr, root, func(info DirectoryInfo) error {
+			infos = append(infos, info)
+			return nil
+		})
+	})
+	return infos, e
+}
+
+type exportedDirectory struct {
+	Name string `json:"name"`
+	Path []string `json:"path"`
+	Layer string `json:"layer,omitempty"`
+	Prefix string `json:"prefix"`
+	Partition []string `json:"partition,omitempty"`
+	Subdirectories []*exportedDirectory `json:"subdirectories,omitempty"`
+}
+
+// ExportJSON writes the subdirectories of root to w as an indented JSON array
+// of objects, one for each immediate subdirectory of root. Each object has the
+// name, path, layer (as a string), prefix (in hexadecimal) and partition of
+// the directory, as described for DirectoryInfo, and an array of its own
+// subdirectories. Empty layers, partitions and subdirectories are omitted.
+func ExportJSON(rt fdb.ReadTransactor, root Directory, w io.Writer) error {
+	infos, e := collect(rt, root)
+	if e != nil {
+		return e
+	}
+
+	depth := len(root.GetPath())
+	top := []*exportedDirectory{}
+	var stack []*exportedDirectory
+
+	for _, info := range infos {
+		ed := &exportedDirectory{
+			Name: info.Path[len(info.Path)-1],
+			Path: info.Path,
+			Layer: string(info.Layer),
+			Prefix: hex.EncodeToString(info.Prefix),
+			Partition: info.Partition,
+		}
+
+		level := len(info.Path) - depth - 1
+		stack = append(stack[:level], ed)
+		if level == 0 {
+			top = append(top, ed)
+		} else {
+			parent := stack[level-1]
+			parent.Subdirectories = append(parent.Subdirectories, ed)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(top)
+}
+
+// dotQuote returns s as a quoted Graphviz DOT string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// ExportDOT writes root and its subdirectories to w as a Graphviz DOT digraph,
+// with an edge from each directory to each of its subdirectories. Each
+// directory is labeled with its name, prefix (in hexadecimal) and any layer;
+// directory partitions are drawn as folders.
+func ExportDOT(rt fdb.ReadTransactor, root Directory, w io.Writer) error {
+	infos, e := collect(rt, root)
+	if e != nil {
+		return e
+	}
+
+	var b strings.Builder
+
+	rootLabel := "/" + strings.Join(root.GetPath(), "/")
+	fmt.Fprintf(&b, "digraph directories {\n")
+	fmt.Fprintf(&b, "\tnode [shape=box];\n")
+	fmt.Fprintf(&b, "\troot [label=%s, shape=ellipse];\n", dotQuote(rootLabel))
+
+	depth := len(root.GetPath())
+	stack := []string{"root"}
+
+	for i, info := range infos {
+		id := fmt.Sprintf("d%d", i)
+
+		label := info.Path[len(info.Path)-1] + "\nprefix " + hex.EncodeToString(info.Prefix)
+		shape := ""
+		if string(info.Layer) == "partition" {
+			shape = ", shape=folder"
+		} else if len(info.Layer) > 0 {
+			label += "\nlayer " + string(info.Layer)
+		}
+		fmt.Fprintf(&b, "\t%s [label=%s%s];\n", id, dotQuote(label), shape)
+
+		level := len(info.Path) - depth
+		stack = append(stack[:level], id)
+		fmt.Fprintf(&b, "\t%s -> %s;\n", stack[level-1], id)
+	}
+
+	fmt.Fprintf(&b, "}\n")
+
+	_, e = io.WriteString(w, b.String())
+	return e
+}
@@ -26,6 +26,12 @@ To build and run programs using this package, you must have an installed copy of
 the FoundationDB client libraries (version 2.0.0 or later), available for Linux,
 Windows and OS X at https://foundationdb.com/get.
 
+When built without cgo (CGO_ENABLED=0), this package does not require the
+client libraries, but it cannot start the networking engine or open a
+database: those functions return an error. Databases created with NewDatabase,
+such as the in-memory databases provided by the memdb package, remain fully
+usable.
+
 This documentation specifically applies to the FoundationDB Go binding. For more
 extensive guidance to programming with FoundationDB, as well as API
 documentation for the other FoundationDB interfaces, please see
@@ -45,7 +51,7 @@ A basic interaction with the FoundationDB API is demonstrated below:
 
     func main() {
         // Different API versions may expose different runtime behaviors.
-        fdb.MustAPIVersion(200)
+        fdb.MustAPIVersion(710)
 
         // Open the default database from the system cluster
         db := fdb.MustOpenDefault()
@@ -78,6 +84,25 @@ and have multiple Future objects outstanding inside a single goroutine. All
 operations will execute in parallel, and the calling goroutine will not block
 until a blocking method on any one of the Futures is called.
 
+Contexts
+
+Blocking operations may be bounded by a context.Context, allowing deadlines and
+cancellation to propagate from callers (such as RPC handlers) into the
+FoundationDB API. (Database).TransactContext and (Database).ReadTransactContext
+behave like Transact and ReadTransact, but cancel the transaction and return the
+context's error once the context is done:
+
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+
+    ret, e := db.TransactContext(ctx, func (tr fdb.Transaction) (interface{}, error) {
+        return tr.Get(fdb.Key("foo")).MustGet(), nil
+    })
+
+Outside of a transactional function, every Future type provides a GetContext
+method (and RangeIterator an AdvanceContext method) that stops waiting once the
+context is done, cancelling the underlying operation.
+
 On Panics
 
 Idiomatic Go code strongly frowns at panics that escape library/package
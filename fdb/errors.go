@@ -21,12 +21,6 @@
 
 package fdb
 
-/*
- #define FDB_API_VERSION 200
- #include <foundationdb/fdb_c.h>
-*/
-import "C"
-
 import (
 	"fmt"
 )
@@ -44,13 +38,14 @@ type Error struct {
 }
 
 func (e Error) Error() string {
-	return fmt.Sprintf("FoundationDB error code %d (%s)", e.Code, C.GoString(C.fdb_get_error(C.fdb_error_t(e.Code))))
+	return fmt.Sprintf("FoundationDB error code %d (%s)", e.Code, errorDescription(e.Code))
 }
 
 // SOMEDAY: these (along with others) should be coming from fdb.options?
 
 var (
 	errNetworkNotSetup = Error{2008}
+	errInvalidDatabaseName = Error{2013}
 
 	errAPIVersionUnset = Error{2200}
 	errAPIVersionAlreadySet = Error{2201}
@@ -21,30 +21,10 @@
 
 package fdb
 
-/*
- #define FDB_API_VERSION 200
- #include <foundationdb/fdb_c.h>
- #include <stdlib.h>
-*/
-import "C"
-
 import (
-	"runtime"
 	"sync"
-	"unsafe"
-	"fmt"
-	"log"
 )
 
-/* Would put this in futures.go but for the documented issue with
-/* exports and functions in preamble
-/* (https://code.google.com/p/go-wiki/wiki/cgo#Global_functions) */
-//export unlockMutex
-func unlockMutex(p unsafe.Pointer) {
-	m := (*sync.Mutex)(p)
-	m.Unlock()
-}
-
 // A Transactor can execute a function that requires a Transaction. Functions
 // written to accept a Transactor are called transactional functions, and may be
 // called with either a Database or a Transaction.
@@ -70,14 +50,6 @@ type ReadTransactor interface {
 	ReadTransact(func (ReadTransaction) (interface{}, error)) (interface{}, error)
 }
 
-func setOpt(setter func(*C.uint8_t, C.int) C.fdb_error_t, param []byte) error {
-	if err := setter(byteSliceToPtr(param), C.int(len(param))); err != 0 {
-		return Error{int(err)}
-	}
-
-	return nil
-}
-
 // NetworkOptions is a handle with which to set options that affect the entire
 // FoundationDB client. A NetworkOptions instance should be obtained with the
 // fdb.Options function.
@@ -98,9 +70,7 @@ func (opt NetworkOptions) setOpt(code int, param []byte) error {
 		return errAPIVersionUnset
 	}
 
-	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
-		return C.fdb_network_set_option(C.FDBNetworkOption(code), p, pl)
-	}, param)
+	return setNetworkOption(code, param)
 }
 
 // APIVersion determines the runtime behavior the fdb package. If the requested
@@ -108,7 +78,11 @@ func (opt NetworkOptions) setOpt(code int, param []byte) error {
 // library, an error will be returned. APIVersion must be called prior to any
 // other functions in the fdb package.
 //
-// Currently, this package supports API versions 200 and 300.
+// Currently, this package supports API versions 200 through 710, and requires a
+// FoundationDB C library that supports API version 710 (that is, FoundationDB
+// 7.1 or later). Behavior that changed between API versions, such as the
+// handling of transaction options across (Transaction).OnError, follows the
+// selected version.
 func APIVersion(version int) error {
 	networkMutex.Lock()
 	defer networkMutex.Unlock()
@@ -120,20 +94,12 @@ func APIVersion(version int) error {
 		return errAPIVersionAlreadySet
 	}
 
-	if version < 200 || version > 300 {
+	if version < 200 || version > headerVersion {
 		return errAPIVersionNotSupported
 	}
 
-	if e := C.fdb_select_api_version_impl(C.int(version), 300); e != 0 {
-		if e == 2203 && version == 200 {
-			e = C.fdb_select_api_version_impl(C.int(version), 200)
-		}
-		if e != 0 {
-			if e == 2203 {
-				return fmt.Errorf("API version %d not supported by the installed FoundationDB C library", version)
-			}
-			return Error{int(e)}
-		}
+	if e := selectAPIVersion(version); e != nil {
+		return e
 	}
 
 	apiVersion = version
@@ -150,35 +116,33 @@ func MustAPIVersion(version int) {
 	}
 }
 
+// GetAPIVersion returns the API version selected by a successful call to
+// APIVersion or MustAPIVersion, or an error if no API version has been selected.
+func GetAPIVersion() (int, error) {
+	networkMutex.Lock()
+	defer networkMutex.Unlock()
+
+	if apiVersion == 0 {
+		return 0, errAPIVersionUnset
+	}
+
+	return apiVersion, nil
+}
+
+// headerVersion is the FDB_API_VERSION against which this package is compiled,
+// and must match the definition at the top of each file that includes fdb_c.h.
+const headerVersion = 710
+
 var apiVersion int
 var networkStarted bool
 var networkMutex sync.Mutex
 
-var openClusters map[string]Cluster
 var openDatabases map[string]Database
 
 func init() {
-	openClusters = make(map[string]Cluster)
 	openDatabases = make(map[string]Database)
 }
 
-func startNetwork() error {
-	if e := C.fdb_setup_network(); e != 0 {
-		return Error{int(e)}
-	}
-
-	go func() {
-		e := C.fdb_run_network()
-		if e != 0 {
-			log.Printf("Unhandled error in FoundationDB network thread: %v (%v)\n", C.GoString(C.fdb_get_error(e)), e)
-		}
-	}()
-
-	networkStarted = true
-
-	return nil
-}
-
 // StartNetwork initializes the FoundationDB client networking engine. It is not
 // necessary to call StartNetwork when using the fdb.Open or fdb.OpenDefault
 // functions to obtain a database handle. StartNetwork must not be called more
@@ -194,17 +158,16 @@ func StartNetwork() error {
 	return startNetwork()
 }
 
-// DefaultClusterFile should be passed to fdb.Open or fdb.CreateCluster to allow
-// the FoundationDB C library to select the platform-appropriate default cluster
-// file on the current machine.
+// DefaultClusterFile should be passed to fdb.Open, fdb.OpenDatabase or
+// fdb.CreateCluster to allow the FoundationDB C library to select the
+// platform-appropriate default cluster file on the current machine.
 const DefaultClusterFile string = ""
 
-// OpenDefault returns a database handle to the default database from the
-// FoundationDB cluster identified by the DefaultClusterFile on the current
-// machine. The FoundationDB client networking engine will be initialized first,
-// if necessary.
+// OpenDefault returns a database handle to the FoundationDB cluster identified
+// by the DefaultClusterFile on the current machine. The FoundationDB client
+// networking engine will be initialized first, if necessary.
 func OpenDefault() (Database, error) {
-	return Open(DefaultClusterFile, []byte("DB"))
+	return OpenDatabase(DefaultClusterFile)
 }
 
 // MustOpenDefault is like OpenDefault but panics if the default database cannot
@@ -217,13 +180,11 @@ func MustOpenDefault() Database {
 	return db
 }
 
-// Open returns a database handle to the named database from the FoundationDB
-// cluster identified by the provided cluster file and database name. The
-// FoundationDB client networking engine will be initialized first, if
-// necessary.
-//
-// In the current release, the database name must be []byte("DB").
-func Open(clusterFile string, dbName []byte) (Database, error) {
+// OpenDatabase returns a database handle to the FoundationDB cluster identified
+// by the provided cluster file. The FoundationDB client networking engine will
+// be initialized first, if necessary. Handles are cached, so subsequent calls
+// with the same cluster file return the same Database.
+func OpenDatabase(clusterFile string) (Database, error) {
 	networkMutex.Lock()
 	defer networkMutex.Unlock()
 
@@ -231,72 +192,68 @@ func Open(clusterFile string, dbName []byte) (Database, error) {
 		return Database{}, errAPIVersionUnset
 	}
 
-	var e error
-
 	if !networkStarted {
-		e = startNetwork()
-		if e != nil {
+		if e := startNetwork(); e != nil {
 			return Database{}, e
 		}
 	}
 
-	cluster, ok := openClusters[clusterFile]
+	db, ok := openDatabases[clusterFile]
 	if !ok {
-		cluster, e = createCluster(clusterFile)
+		var e error
+		db, e = createDatabase(clusterFile)
 		if e != nil {
 			return Database{}, e
 		}
-		openClusters[clusterFile] = cluster
-	}
-
-	db, ok := openDatabases[string(dbName)]
-	if !ok {
-		db, e = cluster.OpenDatabase(dbName)
-		if e != nil {
-			return Database{}, e
-		}
-		openDatabases[string(dbName)] = db
+		openDatabases[clusterFile] = db
 	}
 
 	return db, nil
 }
 
-// MustOpen is like Open but panics if the database cannot be opened.
-func MustOpen(clusterFile string, dbName []byte) Database {
-	db, err := Open(clusterFile, dbName)
+// MustOpenDatabase is like OpenDatabase but panics if the database cannot be
+// opened.
+func MustOpenDatabase(clusterFile string) Database {
+	db, err := OpenDatabase(clusterFile)
 	if err != nil {
 		panic(err)
 	}
 	return db
 }
 
-func createCluster(clusterFile string) (Cluster, error) {
-	var cf *C.char
-
-	if len(clusterFile) != 0 {
-		cf = C.CString(clusterFile)
-		defer C.free(unsafe.Pointer(cf))
+// Open returns a database handle to the named database from the FoundationDB
+// cluster identified by the provided cluster file and database name. The
+// FoundationDB client networking engine will be initialized first, if
+// necessary.
+//
+// The database name must be []byte("DB"). FoundationDB clusters contain a single
+// database, and as of API version 610 the name is no longer part of the C
+// API; new code should use OpenDatabase.
+func Open(clusterFile string, dbName []byte) (Database, error) {
+	if string(dbName) != "DB" {
+		return Database{}, errInvalidDatabaseName
 	}
 
-	f := C.fdb_create_cluster(cf)
-	fdb_future_block_until_ready(f)
-
-	var outc *C.FDBCluster
+	return OpenDatabase(clusterFile)
+}
 
-	if err := C.fdb_future_get_cluster(f, &outc); err != 0 {
-		return Cluster{}, Error{int(err)}
+// MustOpen is like Open but panics if the database cannot be opened.
+func MustOpen(clusterFile string, dbName []byte) Database {
+	db, err := Open(clusterFile, dbName)
+	if err != nil {
+		panic(err)
 	}
-
-	C.fdb_future_destroy(f)
-
-	c := &cluster{outc}
-	runtime.SetFinalizer(c, (*cluster).destroy)
-
-	return Cluster{c}, nil
+	return db
 }
 
 // CreateCluster returns a cluster handle to the FoundationDB cluster identified
 // by the provided cluster file.
+//
+// Prior to API version 610, the FoundationDB client networking engine must have
+// been started (for example, with StartNetwork) before calling CreateCluster.
+//
+// Deprecated: Use OpenDatabase or OpenDefault to obtain a database handle
+// directly.
 func CreateCluster(clusterFile string) (Cluster, error) {
 	networkMutex.Lock()
 	defer networkMutex.Unlock()
@@ -305,19 +262,11 @@ func CreateCluster(clusterFile string) (Cluster, error) {
 		return Cluster{}, errAPIVersionUnset
 	}
 
-	if !networkStarted {
+	if apiVersion < 610 && !networkStarted {
 		return Cluster{}, errNetworkNotSetup
 	}
 
-	return createCluster(clusterFile)
-}
-
-func byteSliceToPtr(b []byte) *C.uint8_t {
-	if len(b) > 0 {
-		return (*C.uint8_t)(unsafe.Pointer(&b[0]))
-	} else {
-		return nil
-	}
+	return Cluster{clusterFile}, nil
 }
 
 // A KeyConvertible can be converted to a FoundationDB Key. All functions in the
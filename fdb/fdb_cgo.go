@@ -0,0 +1,122 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build cgo
+
+package fdb
+
+/*
+ #define FDB_API_VERSION 710
+ #include <foundationdb/fdb_c.h>
+ #include <stdlib.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+	"fmt"
+	"log"
+)
+
+/* Would put this in futures.go but for the documented issue with
+/* exports and functions in preamble
+/* (https://code.google.com/p/go-wiki/wiki/cgo#Global_functions) */
+//export unlockMutex
+func unlockMutex(p unsafe.Pointer) {
+	m := (*sync.Mutex)(p)
+	m.Unlock()
+}
+
+func setOpt(setter func(*C.uint8_t, C.int) C.fdb_error_t, param []byte) error {
+	if err := setter(byteSliceToPtr(param), C.int(len(param))); err != 0 {
+		return Error{int(err)}
+	}
+
+	return nil
+}
+
+func setNetworkOption(code int, param []byte) error {
+	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
+		return C.fdb_network_set_option(C.FDBNetworkOption(code), p, pl)
+	}, param)
+}
+
+func selectAPIVersion(version int) error {
+	if e := C.fdb_select_api_version_impl(C.int(version), headerVersion); e != 0 {
+		if e == 2203 {
+			maxSupported := int(C.fdb_get_max_api_version())
+			if maxSupported < headerVersion {
+				return fmt.Errorf("this version of the FoundationDB Go binding requires a FoundationDB C library supporting API version %d, but the installed library supports at most version %d", headerVersion, maxSupported)
+			}
+			return fmt.Errorf("API version %d not supported by the installed FoundationDB C library", version)
+		}
+		return Error{int(e)}
+	}
+
+	return nil
+}
+
+func startNetwork() error {
+	if e := C.fdb_setup_network(); e != 0 {
+		return Error{int(e)}
+	}
+
+	go func() {
+		e := C.fdb_run_network()
+		if e != 0 {
+			log.Printf("Unhandled error in FoundationDB network thread: %v (%v)\n", C.GoString(C.fdb_get_error(e)), e)
+		}
+	}()
+
+	networkStarted = true
+
+	return nil
+}
+
+func createDatabase(clusterFile string) (Database, error) {
+	var cf *C.char
+
+	if len(clusterFile) != 0 {
+		cf = C.CString(clusterFile)
+		defer C.free(unsafe.Pointer(cf))
+	}
+
+	var outdb *C.FDBDatabase
+
+	if err := C.fdb_create_database(cf, &outdb); err != 0 {
+		return Database{}, Error{int(err)}
+	}
+
+	return newNativeDatabase(outdb), nil
+}
+
+func errorDescription(code int) string {
+	return C.GoString(C.fdb_get_error(C.fdb_error_t(code)))
+}
+
+func byteSliceToPtr(b []byte) *C.uint8_t {
+	if len(b) > 0 {
+		return (*C.uint8_t)(unsafe.Pointer(&b[0]))
+	} else {
+		return nil
+	}
+}
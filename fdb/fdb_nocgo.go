@@ -0,0 +1,55 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build !cgo
+
+package fdb
+
+import (
+	"errors"
+)
+
+// errNoClientLibrary is returned by everything that needs the FoundationDB C
+// library when this package is built without cgo. An API version may still be
+// selected, and databases created with NewDatabase (such as those provided by
+// the memdb package) work as usual, but the client networking engine cannot be
+// started and no cluster can be opened.
+var errNoClientLibrary = errors.New("the FoundationDB C library is not available in programs built without cgo")
+
+func setNetworkOption(code int, param []byte) error {
+	return errNoClientLibrary
+}
+
+func selectAPIVersion(version int) error {
+	return nil
+}
+
+func startNetwork() error {
+	return errNoClientLibrary
+}
+
+func createDatabase(clusterFile string) (Database, error) {
+	return Database{}, errNoClientLibrary
+}
+
+func errorDescription(code int) string {
+	return "description unavailable without the FoundationDB C library"
+}
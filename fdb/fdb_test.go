@@ -19,6 +19,8 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
+//go:build cgo
+
 package fdb_test
 
 import (
@@ -29,14 +31,14 @@ import (
 func ExampleOpenDefault() {
 	var e error
 
-	e = fdb.APIVersion(200)
+	e = fdb.APIVersion(710)
 	if e != nil {
 		fmt.Printf("Unable to set API version: %v\n", e)
 		return
 	}
 
 	// OpenDefault opens the database described by the platform-specific default
-	// cluster file and the database name []byte("DB").
+	// cluster file.
 	db, e := fdb.OpenDefault()
 	if e != nil {
 		fmt.Printf("Unable to open default database: %v\n", e)
@@ -47,7 +49,7 @@ func ExampleOpenDefault() {
 }
 
 func ExampleTransactor() {
-	fdb.MustAPIVersion(200)
+	fdb.MustAPIVersion(710)
 	db := fdb.MustOpenDefault()
 
 	setOne := func(t fdb.Transactor, key fdb.Key, value []byte) error {
@@ -98,7 +100,7 @@ func ExampleTransactor() {
 }
 
 func ExampleReadTransactor() {
-	fdb.MustAPIVersion(200)
+	fdb.MustAPIVersion(710)
 	db := fdb.MustOpenDefault()
 
 	getOne := func(rt fdb.ReadTransactor, key fdb.Key) ([]byte, error) {
@@ -150,8 +152,47 @@ func ExampleReadTransactor() {
 	// getOne called with: fdb.Snapshot
 }
 
+// countingTransaction wraps a WriteTransaction, counting the keys set through
+// it.
+type countingTransaction struct {
+	fdb.WriteTransaction
+	sets int
+}
+
+func (ct *countingTransaction) Set(key fdb.KeyConvertible, value []byte) {
+	ct.sets++
+	ct.WriteTransaction.Set(key, value)
+}
+
+func ExampleWriteTransaction() {
+	fdb.MustAPIVersion(710)
+	db := fdb.MustOpenDefault()
+
+	setAll := func(tr fdb.WriteTransaction, value []byte, keys ...fdb.Key) {
+		for _, key := range keys {
+			tr.Set(key, value)
+		}
+	}
+
+	tr, e := db.CreateTransaction()
+	if e != nil {
+		fmt.Printf("Unable to create transaction: %v\n", e)
+		return
+	}
+
+	// In examples we do not commit transactions to avoid mutating a real
+	// database.
+	ct := &countingTransaction{WriteTransaction: tr}
+	setAll(ct, []byte("1"), fdb.Key("foo"), fdb.Key("bar"))
+
+	fmt.Printf("Set %d keys\n", ct.sets)
+
+	// Output:
+	// Set 2 keys
+}
+
 func ExamplePrefixRange() {
-	fdb.MustAPIVersion(200)
+	fdb.MustAPIVersion(710)
 	db := fdb.MustOpenDefault()
 
 	tr, e := db.CreateTransaction()
@@ -190,7 +231,7 @@ func ExamplePrefixRange() {
 }
 
 func ExampleRangeIterator() {
-	fdb.MustAPIVersion(200)
+	fdb.MustAPIVersion(710)
 	db := fdb.MustOpenDefault()
 
 	tr, e := db.CreateTransaction()
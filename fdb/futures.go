@@ -21,28 +21,8 @@
 
 package fdb
 
-/*
- #cgo LDFLAGS: -lfdb_c -lm
- #define FDB_API_VERSION 200
- #include <foundationdb/fdb_c.h>
- #include <string.h>
-
- extern void unlockMutex(void*);
-
- void go_callback(FDBFuture* f, void* m) {
-     unlockMutex(m);
- }
-
- void go_set_callback(void* f, void* m) {
-     fdb_future_set_callback(f, (FDBCallback)&go_callback, m);
- }
-*/
-import "C"
-
 import (
-	"unsafe"
-	"sync"
-	"runtime"
+	"context"
 )
 
 // A Future represents a value (or error) to be available at some later
@@ -50,12 +30,21 @@ import (
 // the Future interface. All Future types additionally implement Get and MustGet
 // methods with different return types. Calling BlockUntilReady, Get or MustGet
 // will block the calling goroutine until the Future is ready.
+//
+// Every Future type also implements a GetContext method, which behaves like Get
+// but stops waiting once the provided context is done, cancelling the Future
+// and returning the context's error.
 type Future interface {
 	// BlockUntilReady blocks the calling goroutine until the future is ready. A
 	// future becomes ready either when it receives a value of its enclosed type
 	// (if any) or is set to an error state.
 	BlockUntilReady()
 
+	// BlockUntilReadyContext blocks the calling goroutine until the future is
+	// ready or the provided context is done. If the context is done first, the
+	// future is cancelled and the context's error is returned.
+	BlockUntilReadyContext(ctx context.Context) error
+
 	// IsReady returns true if the future is ready, and false otherwise, without
 	// blocking. A future is ready either when has received a value of its
 	// enclosed type (if any) or has been set to an error state.
@@ -71,39 +60,6 @@ type Future interface {
 	Cancel()
 }
 
-type future struct {
-	ptr *C.FDBFuture
-}
-
-func newFuture(ptr *C.FDBFuture) *future {
-	f := &future{ptr}
-	runtime.SetFinalizer(f, func(f *future) { C.fdb_future_destroy(f.ptr) })
-	return f
-}
-
-func fdb_future_block_until_ready(f *C.FDBFuture) {
-	if C.fdb_future_is_ready(f) != 0 {
-		return
-	}
-
-	m := &sync.Mutex{}
-	m.Lock()
-	C.go_set_callback(unsafe.Pointer(f), unsafe.Pointer(m))
-	m.Lock()
-}
-
-func (f future) BlockUntilReady() {
-	fdb_future_block_until_ready(f.ptr)
-}
-
-func (f future) IsReady() bool {
-	return C.fdb_future_is_ready(f.ptr) != 0
-}
-
-func (f future) Cancel() {
-	C.fdb_future_cancel(f.ptr)
-}
-
 // FutureByteSlice represents the asynchronous result of a function that returns
 // a value from a database. FutureByteSlice is a lightweight object that may be
 // efficiently copied, and is safe for concurrent use by multiple goroutines.
@@ -120,44 +76,12 @@ type FutureByteSlice interface {
 	// future is ready.
 	MustGet() []byte
 
-	Future
-}
-
-type futureByteSlice struct {
-	*future
-	v []byte
-	e error
-	o sync.Once
-}
-
-func (f *futureByteSlice) Get() ([]byte, error) {
-	f.o.Do(func() {
-		var present C.fdb_bool_t
-		var value *C.uint8_t
-		var length C.int
-
-		f.BlockUntilReady()
-
-		if err := C.fdb_future_get_value(f.ptr, &present, &value, &length); err != 0 {
-			f.e = Error{int(err)}
-		} else {
-			if present != 0 {
-				f.v = C.GoBytes(unsafe.Pointer(value), length)
-			}
-		}
-
-		C.fdb_future_release_memory(f.ptr)
-	})
-
-	return f.v, f.e
-}
+	// GetContext is like Get, but returns the error of the provided context
+	// (and cancels the future) if the context is done before the future is
+	// ready.
+	GetContext(ctx context.Context) ([]byte, error)
 
-func (f *futureByteSlice) MustGet() []byte {
-	val, err := f.Get()
-	if err != nil {
-		panic(err)
-	}
-	return val
+	Future
 }
 
 // FutureKey represents the asynchronous result of a function that returns a key
@@ -174,41 +98,12 @@ type FutureKey interface {
 	// goroutine will be blocked until the future is ready.
 	MustGet() Key
 
-	Future
-}
-
-type futureKey struct {
-	*future
-	k Key
-	e error
-	o sync.Once
-}
-
-func (f *futureKey) Get() (Key, error) {
-	f.o.Do(func() {
-		var value *C.uint8_t
-		var length C.int
-
-		f.BlockUntilReady()
-
-		if err := C.fdb_future_get_key(f.ptr, &value, &length); err != 0 {
-			f.e = Error{int(err)}
-		} else {
-			f.k = C.GoBytes(unsafe.Pointer(value), length)
-		}
-
-		C.fdb_future_release_memory(f.ptr)
-	})
-
-	return f.k, f.e
-}
+	// GetContext is like Get, but returns the error of the provided context
+	// (and cancels the future) if the context is done before the future is
+	// ready.
+	GetContext(ctx context.Context) (Key, error)
 
-func (f *futureKey) MustGet() Key {
-	val, err := f.Get()
-	if err != nil {
-		panic(err)
-	}
-	return val
+	Future
 }
 
 // FutureNil represents the asynchronous result of a function that has no return
@@ -225,65 +120,32 @@ type FutureNil interface {
 	// until the future is ready.
 	MustGet()
 
-	Future
-}
-
-type futureNil struct {
-	*future
-}
-
-func (f futureNil) Get() error {
-	f.BlockUntilReady()
-	if err := C.fdb_future_get_error(f.ptr); err != 0 {
-		return Error{int(err)}
-	}
-
-	return nil
-}
-
-func (f futureNil) MustGet() {
-	if err := f.Get(); err != nil {
-		panic(err)
-	}
-}
-
-type futureKeyValueArray struct {
-	*future
-}
-
-func stringRefToSlice(ptr unsafe.Pointer) []byte {
-	size := *((*C.int)(unsafe.Pointer(uintptr(ptr)+8)))
-
-	if size == 0 {
-		return []byte{}
-	}
-
-	src := unsafe.Pointer(*(**C.uint8_t)(unsafe.Pointer(ptr)))
+	// GetContext is like Get, but returns the error of the provided context
+	// (and cancels the future) if the context is done before the future is
+	// ready.
+	GetContext(ctx context.Context) error
 
-	return C.GoBytes(src, size)
+	Future
 }
 
-func (f futureKeyValueArray) Get() ([]KeyValue, bool, error) {
-	f.BlockUntilReady()
-
-	var kvs *C.FDBKeyValue
-	var count C.int
-	var more C.fdb_bool_t
-
-	if err := C.fdb_future_get_keyvalue_array(f.ptr, &kvs, &count, &more); err != 0 {
-		return nil, false, Error{int(err)}
-	}
-
-	ret := make([]KeyValue, int(count))
-
-	for i := 0; i < int(count); i++ {
-		kvptr := unsafe.Pointer(uintptr(unsafe.Pointer(kvs)) + uintptr(i * 24))
+// FutureKeyValueArray represents the asynchronous result of a single batch of a
+// range read. Range reads are usually consumed through RangeResult and
+// RangeIterator, which issue as many batches as necessary; FutureKeyValueArray
+// is only used directly by implementations of TransactionBackend.
+type FutureKeyValueArray interface {
+	// Get returns a batch of key-value pairs, a flag indicating whether more
+	// key-value pairs remain in the range beyond this batch, or an error if
+	// the asynchronous operation associated with this future did not
+	// successfully complete. The current goroutine will be blocked until the
+	// future is ready.
+	Get() ([]KeyValue, bool, error)
 
-		ret[i].Key = stringRefToSlice(kvptr)
-		ret[i].Value = stringRefToSlice(unsafe.Pointer(uintptr(kvptr) + 12))
-	}
+	// GetContext is like Get, but returns the error of the provided context
+	// (and cancels the future) if the context is done before the future is
+	// ready.
+	GetContext(ctx context.Context) ([]KeyValue, bool, error)
 
- 	return ret, (more != 0), nil
+	Future
 }
 
 // FutureInt64 represents the asynchronous result of a function that returns a
@@ -300,29 +162,12 @@ type FutureInt64 interface {
 	// current goroutine will be blocked until the future is ready.
 	MustGet() int64
 
-	Future
-}
-
-type futureInt64 struct {
-	*future
-}
-
-func (f futureInt64) Get() (int64, error) {
-	f.BlockUntilReady()
-
-	var ver C.int64_t
-	if err := C.fdb_future_get_version(f.ptr, &ver); err != 0 {
-		return 0, Error{int(err)}
-	}
-	return int64(ver), nil
-}
+	// GetContext is like Get, but returns the error of the provided context
+	// (and cancels the future) if the context is done before the future is
+	// ready.
+	GetContext(ctx context.Context) (int64, error)
 
-func (f futureInt64) MustGet() int64 {
-	val, err := f.Get()
-	if err != nil {
-		panic(err)
-	}
-	return val
+	Future
 }
 
 // FutureStringSlice represents the asynchronous result of a function that
@@ -340,36 +185,10 @@ type FutureStringSlice interface {
 	// current goroutine will be blocked until the future is ready.
 	MustGet() []string
 
-	Future
-}
-
-type futureStringSlice struct {
-	*future
-}
-
-func (f futureStringSlice) Get() ([]string, error) {
-	f.BlockUntilReady()
-
-	var strings **C.char
-	var count C.int
-
-	if err := C.fdb_future_get_string_array(f.ptr, (***C.char)(unsafe.Pointer(&strings)), &count); err != 0 {
-		return nil, Error{int(err)}
-	}
-
-	ret := make([]string, int(count))
-
-	for i := 0; i < int(count); i++ {
-		ret[i] = C.GoString((*C.char)(*(**C.char)(unsafe.Pointer(uintptr(unsafe.Pointer(strings))+uintptr(i*8)))))
-	}
-
-	return ret, nil
-}
+	// GetContext is like Get, but returns the error of the provided context
+	// (and cancels the future) if the context is done before the future is
+	// ready.
+	GetContext(ctx context.Context) ([]string, error)
 
-func (f futureStringSlice) MustGet() []string {
-	val, err := f.Get()
-	if err != nil {
-		panic(err)
-	}
-	return val
+	Future
 }
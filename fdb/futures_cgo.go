@@ -0,0 +1,343 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build cgo
+
+package fdb
+
+/*
+ #cgo CFLAGS: -I/usr/local/include
+ #cgo LDFLAGS: -lfdb_c -lm
+ #define FDB_API_VERSION 710
+ #include <foundationdb/fdb_c.h>
+ #include <string.h>
+
+ extern void unlockMutex(void*);
+
+ void go_callback(FDBFuture* f, void* m) {
+     unlockMutex(m);
+ }
+
+ void go_set_callback(void* f, void* m) {
+     fdb_future_set_callback(f, (FDBCallback)&go_callback, m);
+ }
+*/
+import "C"
+
+import (
+	"context"
+	"unsafe"
+	"sync"
+	"runtime"
+)
+
+type future struct {
+	ptr *C.FDBFuture
+}
+
+func newFuture(ptr *C.FDBFuture) *future {
+	f := &future{ptr}
+	runtime.SetFinalizer(f, func(f *future) { C.fdb_future_destroy(f.ptr) })
+	return f
+}
+
+func fdb_future_block_until_ready(f *C.FDBFuture) {
+	if C.fdb_future_is_ready(f) != 0 {
+		return
+	}
+
+	m := &sync.Mutex{}
+	m.Lock()
+	C.go_set_callback(unsafe.Pointer(f), unsafe.Pointer(m))
+	m.Lock()
+}
+
+func fdb_future_block_until_ready_context(ctx context.Context, f *C.FDBFuture) error {
+	if C.fdb_future_is_ready(f) != 0 {
+		return nil
+	}
+
+	if ctx.Done() == nil {
+		fdb_future_block_until_ready(f)
+		return nil
+	}
+
+	m := &sync.Mutex{}
+	m.Lock()
+	C.go_set_callback(unsafe.Pointer(f), unsafe.Pointer(m))
+
+	ready := make(chan struct{})
+	go func() {
+		m.Lock()
+		close(ready)
+	}()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		/* Cancelling the future sets it to an error state, which fires
+		/* the callback and releases the goroutine above */
+		C.fdb_future_cancel(f)
+		<-ready
+		return ctx.Err()
+	}
+}
+
+func (f future) BlockUntilReady() {
+	fdb_future_block_until_ready(f.ptr)
+}
+
+func (f future) BlockUntilReadyContext(ctx context.Context) error {
+	return fdb_future_block_until_ready_context(ctx, f.ptr)
+}
+
+func (f future) IsReady() bool {
+	return C.fdb_future_is_ready(f.ptr) != 0
+}
+
+func (f future) Cancel() {
+	C.fdb_future_cancel(f.ptr)
+}
+
+type futureByteSlice struct {
+	*future
+	v []byte
+	e error
+	o sync.Once
+}
+
+func (f *futureByteSlice) Get() ([]byte, error) {
+	f.o.Do(func() {
+		var present C.fdb_bool_t
+		var value *C.uint8_t
+		var length C.int
+
+		f.BlockUntilReady()
+
+		if err := C.fdb_future_get_value(f.ptr, &present, &value, &length); err != 0 {
+			f.e = Error{int(err)}
+		} else {
+			if present != 0 {
+				f.v = C.GoBytes(unsafe.Pointer(value), length)
+			}
+		}
+
+		C.fdb_future_release_memory(f.ptr)
+	})
+
+	return f.v, f.e
+}
+
+func (f *futureByteSlice) MustGet() []byte {
+	val, err := f.Get()
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (f *futureByteSlice) GetContext(ctx context.Context) ([]byte, error) {
+	if err := f.BlockUntilReadyContext(ctx); err != nil {
+		return nil, err
+	}
+	return f.Get()
+}
+
+type futureKey struct {
+	*future
+	k Key
+	e error
+	o sync.Once
+}
+
+func (f *futureKey) Get() (Key, error) {
+	f.o.Do(func() {
+		var value *C.uint8_t
+		var length C.int
+
+		f.BlockUntilReady()
+
+		if err := C.fdb_future_get_key(f.ptr, &value, &length); err != 0 {
+			f.e = Error{int(err)}
+		} else {
+			f.k = C.GoBytes(unsafe.Pointer(value), length)
+		}
+
+		C.fdb_future_release_memory(f.ptr)
+	})
+
+	return f.k, f.e
+}
+
+func (f *futureKey) MustGet() Key {
+	val, err := f.Get()
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (f *futureKey) GetContext(ctx context.Context) (Key, error) {
+	if err := f.BlockUntilReadyContext(ctx); err != nil {
+		return nil, err
+	}
+	return f.Get()
+}
+
+type futureNil struct {
+	*future
+}
+
+func (f futureNil) Get() error {
+	f.BlockUntilReady()
+	if err := C.fdb_future_get_error(f.ptr); err != 0 {
+		return Error{int(err)}
+	}
+
+	return nil
+}
+
+func (f futureNil) MustGet() {
+	if err := f.Get(); err != nil {
+		panic(err)
+	}
+}
+
+func (f futureNil) GetContext(ctx context.Context) error {
+	if err := f.BlockUntilReadyContext(ctx); err != nil {
+		return err
+	}
+	return f.Get()
+}
+
+type futureKeyValueArray struct {
+	*future
+}
+
+func stringRefToSlice(ptr unsafe.Pointer) []byte {
+	size := *((*C.int)(unsafe.Pointer(uintptr(ptr)+8)))
+
+	if size == 0 {
+		return []byte{}
+	}
+
+	src := unsafe.Pointer(*(**C.uint8_t)(unsafe.Pointer(ptr)))
+
+	return C.GoBytes(src, size)
+}
+
+func (f futureKeyValueArray) Get() ([]KeyValue, bool, error) {
+	f.BlockUntilReady()
+
+	var kvs *C.FDBKeyValue
+	var count C.int
+	var more C.fdb_bool_t
+
+	if err := C.fdb_future_get_keyvalue_array(f.ptr, &kvs, &count, &more); err != 0 {
+		return nil, false, Error{int(err)}
+	}
+
+	ret := make([]KeyValue, int(count))
+
+	for i := 0; i < int(count); i++ {
+		kvptr := unsafe.Pointer(uintptr(unsafe.Pointer(kvs)) + uintptr(i * 24))
+
+		ret[i].Key = stringRefToSlice(kvptr)
+		ret[i].Value = stringRefToSlice(unsafe.Pointer(uintptr(kvptr) + 12))
+	}
+
+ 	return ret, (more != 0), nil
+}
+
+func (f futureKeyValueArray) GetContext(ctx context.Context) ([]KeyValue, bool, error) {
+	if err := f.BlockUntilReadyContext(ctx); err != nil {
+		return nil, false, err
+	}
+	return f.Get()
+}
+
+type futureInt64 struct {
+	*future
+}
+
+func (f futureInt64) Get() (int64, error) {
+	f.BlockUntilReady()
+
+	var ver C.int64_t
+	if err := C.fdb_future_get_int64(f.ptr, &ver); err != 0 {
+		return 0, Error{int(err)}
+	}
+	return int64(ver), nil
+}
+
+func (f futureInt64) MustGet() int64 {
+	val, err := f.Get()
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (f futureInt64) GetContext(ctx context.Context) (int64, error) {
+	if err := f.BlockUntilReadyContext(ctx); err != nil {
+		return 0, err
+	}
+	return f.Get()
+}
+
+type futureStringSlice struct {
+	*future
+}
+
+func (f futureStringSlice) Get() ([]string, error) {
+	f.BlockUntilReady()
+
+	var strings **C.char
+	var count C.int
+
+	if err := C.fdb_future_get_string_array(f.ptr, (***C.char)(unsafe.Pointer(&strings)), &count); err != 0 {
+		return nil, Error{int(err)}
+	}
+
+	ret := make([]string, int(count))
+
+	for i := 0; i < int(count); i++ {
+		ret[i] = C.GoString((*C.char)(*(**C.char)(unsafe.Pointer(uintptr(unsafe.Pointer(strings))+uintptr(i*8)))))
+	}
+
+	return ret, nil
+}
+
+func (f futureStringSlice) MustGet() []string {
+	val, err := f.Get()
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (f futureStringSlice) GetContext(ctx context.Context) ([]string, error) {
+	if err := f.BlockUntilReadyContext(ctx); err != nil {
+		return nil, err
+	}
+	return f.Get()
+}
@@ -22,6 +22,20 @@ func int64ToBytes(i int64) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// Deprecated: SetLocalAddress is no longer supported by FoundationDB.
+//
+// Parameter: IP:PORT
+func (o NetworkOptions) SetLocalAddress(param string) error {
+	return o.setOpt(10, []byte(param))
+}
+
+// Deprecated: SetClusterFile is no longer supported by FoundationDB.
+//
+// Parameter: path to cluster file
+func (o NetworkOptions) SetClusterFile(param string) error {
+	return o.setOpt(20, []byte(param))
+}
+
 // Enables trace output to a file in a directory of the clients choosing
 //
 // Parameter: path to output directory (or NULL for current working directory)
@@ -40,7 +54,7 @@ func (o NetworkOptions) SetTraceRollSize(param int64) error {
 	return o.setOpt(31, b)
 }
 
-// Sets the maximum size of a all the trace output files put together. This value should be in the range ``[0, INT64_MAX]``. If the value is set to 0, there is no limit on the total size of the files. The default is a maximum size of 104,857,600 bytes. If the default roll size is used, this means that a maximum of 10 trace files will be written at a time.
+// Sets the maximum size of all the trace output files put together. This value should be in the range ``[0, INT64_MAX]``. If the value is set to 0, there is no limit on the total size of the files. The default is a maximum size of 104,857,600 bytes. If the default roll size is used, this means that a maximum of 10 trace files will be written at a time.
 //
 // Parameter: max total size of trace files
 func (o NetworkOptions) SetTraceMaxLogsSize(param int64) error {
@@ -51,6 +65,41 @@ func (o NetworkOptions) SetTraceMaxLogsSize(param int64) error {
 	return o.setOpt(32, b)
 }
 
+// Sets the 'LogGroup' attribute with the specified value for all events in the trace output files. The default log group is 'default'.
+//
+// Parameter: value of the LogGroup attribute
+func (o NetworkOptions) SetTraceLogGroup(param string) error {
+	return o.setOpt(33, []byte(param))
+}
+
+// Select the format of the log files. xml (the default) and json are supported.
+//
+// Parameter: Format of trace files
+func (o NetworkOptions) SetTraceFormat(param string) error {
+	return o.setOpt(34, []byte(param))
+}
+
+// Select clock source for trace files. now (the default) or realtime are supported.
+//
+// Parameter: Trace clock source
+func (o NetworkOptions) SetTraceClockSource(param string) error {
+	return o.setOpt(35, []byte(param))
+}
+
+// Once provided, this string will be used to replace the port/PID in the log file names.
+//
+// Parameter: The identifier that will be part of all trace file names
+func (o NetworkOptions) SetTraceFileIdentifier(param string) error {
+	return o.setOpt(36, []byte(param))
+}
+
+// Set file suffix for partially written log files.
+//
+// Parameter: Append this suffix to partially written log files. When a log file is complete, it is renamed to remove the suffix. No separator is added between the file and the suffix. If you want to add a file extension, you should include the separator - e.g. '.tmp' instead of 'tmp' to add the 'tmp' extension.
+func (o NetworkOptions) SetTracePartialFileSuffix(param string) error {
+	return o.setOpt(39, []byte(param))
+}
+
 // Set internal tuning or debugging knobs
 //
 // Parameter: knob_name=knob_value
@@ -58,7 +107,7 @@ func (o NetworkOptions) SetKnob(param string) error {
 	return o.setOpt(40, []byte(param))
 }
 
-// Set the TLS plugin to load. This option, if used, must be set before any other TLS options
+// Deprecated: SetTLSPlugin is no longer supported by FoundationDB.
 //
 // Parameter: file path or linker-resolved name
 func (o NetworkOptions) SetTLSPlugin(param string) error {
@@ -100,6 +149,153 @@ func (o NetworkOptions) SetTLSVerifyPeers(param []byte) error {
 	return o.setOpt(47, param)
 }
 
+// Not yet implemented.
+func (o NetworkOptions) SetBuggifyEnable() error {
+	return o.setOpt(48, nil)
+}
+
+// Not yet implemented.
+func (o NetworkOptions) SetBuggifyDisable() error {
+	return o.setOpt(49, nil)
+}
+
+// Set the probability of a BUGGIFY section being active for the current execution.  Only applies to code paths first traversed AFTER this option is changed.
+//
+// Parameter: probability expressed as a percentage between 0 and 100
+func (o NetworkOptions) SetBuggifySectionActivatedProbability(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(50, b)
+}
+
+// Set the probability of an active BUGGIFY section being fired
+//
+// Parameter: probability expressed as a percentage between 0 and 100
+func (o NetworkOptions) SetBuggifySectionFiredProbability(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(51, b)
+}
+
+// Set the ca bundle
+//
+// Parameter: ca bundle
+func (o NetworkOptions) SetTLSCaBytes(param []byte) error {
+	return o.setOpt(52, param)
+}
+
+// Set the file from which to load the certificate authority bundle
+//
+// Parameter: file path
+func (o NetworkOptions) SetTLSCaPath(param string) error {
+	return o.setOpt(53, []byte(param))
+}
+
+// Set the passphrase for encrypted private key. Password should be set before setting the key for the password to be used.
+//
+// Parameter: key passphrase
+func (o NetworkOptions) SetTLSPassword(param string) error {
+	return o.setOpt(54, []byte(param))
+}
+
+// Disables the multi-version client API and instead uses the local client directly. Must be set before setting up the network.
+func (o NetworkOptions) SetDisableMultiVersionClientApi() error {
+	return o.setOpt(60, nil)
+}
+
+// If set, callbacks from external client libraries can be called from threads created by the FoundationDB client library. Otherwise, callbacks will be called from either the thread used to add the callback or the network thread. Setting this option can improve performance when connected using an external client, but may not be safe to use in all environments. Must be set before setting up the network. WARNING: This feature is considered experimental at this time.
+func (o NetworkOptions) SetCallbacksOnExternalThreads() error {
+	return o.setOpt(61, nil)
+}
+
+// Adds an external client library for use by the multi-version client API. Must be set before setting up the network.
+//
+// Parameter: path to client library
+func (o NetworkOptions) SetExternalClientLibrary(param string) error {
+	return o.setOpt(62, []byte(param))
+}
+
+// Searches the specified path for dynamic libraries and adds them to the list of client libraries for use by the multi-version client API. Must be set before setting up the network.
+//
+// Parameter: path to directory containing client libraries
+func (o NetworkOptions) SetExternalClientDirectory(param string) error {
+	return o.setOpt(63, []byte(param))
+}
+
+// Prevents connections through the local client, allowing only connections through externally loaded client libraries.
+func (o NetworkOptions) SetDisableLocalClient() error {
+	return o.setOpt(64, nil)
+}
+
+// Spawns multiple worker threads for each version of the client that is loaded.  Setting this to a number greater than one implies disable_local_client.
+//
+// Parameter: Number of client threads to be spawned.  Each cluster will be serviced by a single client thread.
+func (o NetworkOptions) SetClientThreadsPerVersion(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(65, b)
+}
+
+// Disables logging of client statistics, such as sampled transaction activity.
+func (o NetworkOptions) SetDisableClientStatisticsLogging() error {
+	return o.setOpt(70, nil)
+}
+
+// Deprecated: SetEnableSlowTaskProfiling is no longer supported by FoundationDB.
+func (o NetworkOptions) SetEnableSlowTaskProfiling() error {
+	return o.setOpt(71, nil)
+}
+
+// Enables debugging feature to perform run loop profiling. Requires trace logging to be enabled. WARNING: this feature is not recommended for use in production.
+func (o NetworkOptions) SetEnableRunLoopProfiling() error {
+	return o.setOpt(71, nil)
+}
+
+// Enable client buggify - will make requests randomly fail (intended for client testing)
+func (o NetworkOptions) SetClientBuggifyEnable() error {
+	return o.setOpt(80, nil)
+}
+
+// Disable client buggify
+func (o NetworkOptions) SetClientBuggifyDisable() error {
+	return o.setOpt(81, nil)
+}
+
+// Set the probability of a CLIENT_BUGGIFY section being active for the current execution.
+//
+// Parameter: probability expressed as a percentage between 0 and 100
+func (o NetworkOptions) SetClientBuggifySectionActivatedProbability(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(82, b)
+}
+
+// Set the probability of an active CLIENT_BUGGIFY section being fired. A section will only fire if it was activated
+//
+// Parameter: probability expressed as a percentage between 0 and 100
+func (o NetworkOptions) SetClientBuggifySectionFiredProbability(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(83, b)
+}
+
+// Set a tracer to run on the client. Should be set to the same value as the tracer set on the server.
+//
+// Parameter: Distributed tracer type. Choose from none, log_file, or network_lossy
+func (o NetworkOptions) SetDistributedClientTracer(param string) error {
+	return o.setOpt(90, []byte(param))
+}
+
 // Set the size of the client location cache. Raising this value can boost performance in very large databases where clients access data in a near-random pattern. Defaults to 100000.
 //
 // Parameter: Max location cache entries
@@ -136,12 +332,102 @@ func (o DatabaseOptions) SetDatacenterId(param string) error {
 	return o.setOpt(22, []byte(param))
 }
 
+// Snapshot read operations will see the results of writes done in the same transaction. This is the default behavior.
+func (o DatabaseOptions) SetSnapshotRywEnable() error {
+	return o.setOpt(26, nil)
+}
+
+// Snapshot read operations will not see the results of writes done in the same transaction. This was the default behavior prior to API version 300.
+func (o DatabaseOptions) SetSnapshotRywDisable() error {
+	return o.setOpt(27, nil)
+}
+
+// Sets the maximum escaped length of key and value fields to be logged to the trace file via the LOG_TRANSACTION option. This sets the ``transaction_logging_max_field_length`` option of each transaction created by this database. See the transaction option description for more information.
+//
+// Parameter: Maximum length of escaped key and value fields.
+func (o DatabaseOptions) SetTransactionLoggingMaxFieldLength(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(405, b)
+}
+
+// Set a timeout in milliseconds which, when elapsed, will cause each transaction automatically to be cancelled. This sets the ``timeout`` option of each transaction created by this database. See the transaction option description for more information. Using this option requires that the API version is 610 or higher.
+//
+// Parameter: value in milliseconds of timeout
+func (o DatabaseOptions) SetTransactionTimeout(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(500, b)
+}
+
+// Set a maximum number of retries after which additional calls to ``onError`` will throw the most recently seen error code. This sets the ``retry_limit`` option of each transaction created by this database. See the transaction option description for more information.
+//
+// Parameter: number of times to retry
+func (o DatabaseOptions) SetTransactionRetryLimit(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(501, b)
+}
+
+// Set the maximum amount of backoff delay incurred in the call to ``onError`` if the error is retryable. This sets the ``max_retry_delay`` option of each transaction created by this database. See the transaction option description for more information.
+//
+// Parameter: value in milliseconds of maximum delay
+func (o DatabaseOptions) SetTransactionMaxRetryDelay(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(502, b)
+}
+
+// Set the maximum transaction size in bytes. This sets the ``size_limit`` option on each transaction created by this database. See the transaction option description for more information.
+//
+// Parameter: value in bytes
+func (o DatabaseOptions) SetTransactionSizeLimit(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(503, b)
+}
+
+// The read version will be committed, and usually will be the latest committed, but might not be the latest committed in the event of a simultaneous fault and misbehaving clock.
+func (o DatabaseOptions) SetTransactionCausalReadRisky() error {
+	return o.setOpt(504, nil)
+}
+
+// Deprecated. Addresses returned by get_addresses_for_key include the port when enabled. As of api version 630, this option is enabled by default and setting this has no effect.
+func (o DatabaseOptions) SetTransactionIncludePortInAddress() error {
+	return o.setOpt(505, nil)
+}
+
+// Allows ``get`` operations to read from sections of keyspace that have become unreadable because of versionstamp operations. This sets the ``bypass_unreadable`` option of each transaction created by this database. See the transaction option description for more information.
+func (o DatabaseOptions) SetTransactionBypassUnreadable() error {
+	return o.setOpt(700, nil)
+}
+
+// Use configuration database.
+func (o DatabaseOptions) SetUseConfigDatabase() error {
+	return o.setOpt(800, nil)
+}
+
+// An integer between 0 and 100 (default is 0) expressing the probability that a client will verify it can't read stale data whenever it detects a recovery.
+func (o DatabaseOptions) SetTestCausalReadRisky() error {
+	return o.setOpt(900, nil)
+}
+
 // The transaction, if not self-conflicting, may be committed a second time after commit succeeds, in the event of a fault
 func (o TransactionOptions) SetCausalWriteRisky() error {
 	return o.setOpt(10, nil)
 }
 
-// The read version will be committed, and usually will be the latest committed, but might not be the latest committed in the event of a fault or partition
+// The read version will be committed, and usually will be the latest committed, but might not be the latest committed in the event of a simultaneous fault and misbehaving clock.
 func (o TransactionOptions) SetCausalReadRisky() error {
 	return o.setOpt(20, nil)
 }
@@ -151,22 +437,22 @@ func (o TransactionOptions) SetCausalReadDisable() error {
 	return o.setOpt(21, nil)
 }
 
+// Addresses returned by get_addresses_for_key include the port when enabled. As of api version 630, this option is enabled by default and setting this has no effect.
+func (o TransactionOptions) SetIncludePortInAddress() error {
+	return o.setOpt(23, nil)
+}
+
 // The next write performed on this transaction will not generate a write conflict range. As a result, other transactions which read the key(s) being modified by the next write will not conflict with this transaction. Care needs to be taken when using this option on a transaction that is shared between multiple threads. When setting this option, write conflict ranges will be disabled on the next write operation, regardless of what thread it is on.
 func (o TransactionOptions) SetNextWriteNoWriteConflictRange() error {
 	return o.setOpt(30, nil)
 }
 
-// Not yet implemented.
-func (o TransactionOptions) SetCheckWritesEnable() error {
-	return o.setOpt(50, nil)
-}
-
-// Reads performed by a transaction will not see any prior mutations that occured in that transaction, instead seeing the value which was in the database at the transaction's read version. This option may provide a small performance benefit for the client, but also disables a number of client-side optimizations which are beneficial for transactions which tend to read and write the same keys within a single transaction.
+// Reads performed by a transaction will not see any prior mutations that occured in that transaction, instead seeing the value which was in the database at the transaction's read version. This option may provide a small performance benefit for the client, but also disables a number of client-side optimizations which are beneficial for transactions which tend to read and write the same keys within a single transaction. It is an error to set this option after performing any reads or writes on the transaction.
 func (o TransactionOptions) SetReadYourWritesDisable() error {
 	return o.setOpt(51, nil)
 }
 
-// Disables read-ahead caching for range reads. Under normal operation, a transaction will read extra rows from the database into cache if range reads are used to page through a series of data one row at a time (i.e. if a range read with a one row limit is followed by another one row range read starting immediately after the result of the first).
+// Deprecated: SetReadAheadDisable is no longer supported by FoundationDB.
 func (o TransactionOptions) SetReadAheadDisable() error {
 	return o.setOpt(52, nil)
 }
@@ -181,7 +467,7 @@ func (o TransactionOptions) SetDurabilityRisky() error {
 	return o.setOpt(120, nil)
 }
 
-// Not yet implemented.
+// Deprecated: SetDurabilityDevNullIsWebScale is no longer supported by FoundationDB.
 func (o TransactionOptions) SetDurabilityDevNullIsWebScale() error {
 	return o.setOpt(130, nil)
 }
@@ -191,7 +477,7 @@ func (o TransactionOptions) SetPrioritySystemImmediate() error {
 	return o.setOpt(200, nil)
 }
 
-// Specifies that this transaction should be treated as low priority and that default priority transactions should be processed first. Useful for doing batch work simultaneously with latency-sensitive work
+// Specifies that this transaction should be treated as low priority and that default priority transactions will be processed first. Batch priority transactions will also be throttled at load levels smaller than for other types of transactions and may be fully cut off in the event of machine failures. Useful for doing batch work simultaneously with latency-sensitive work
 func (o TransactionOptions) SetPriorityBatch() error {
 	return o.setOpt(201, nil)
 }
@@ -201,19 +487,19 @@ func (o TransactionOptions) SetInitializeNewDatabase() error {
 	return o.setOpt(300, nil)
 }
 
-// Allows this transaction to read and modify system keys (those that start with the byte 0xFF)
+// Allows this transaction to read and modify system keys (those that start with the byte 0xFF). Implies raw_access.
 func (o TransactionOptions) SetAccessSystemKeys() error {
 	return o.setOpt(301, nil)
 }
 
-// Allows this transaction to read system keys (those that start with the byte 0xFF)
+// Allows this transaction to read system keys (those that start with the byte 0xFF). Implies raw_access.
 func (o TransactionOptions) SetReadSystemKeys() error {
 	return o.setOpt(302, nil)
 }
 
-// Not yet implemented.
-func (o TransactionOptions) SetDebugDump() error {
-	return o.setOpt(400, nil)
+// Allows this transaction to access the raw key-space when tenant mode is on.
+func (o TransactionOptions) SetRawAccess() error {
+	return o.setOpt(303, nil)
 }
 
 // Not yet implemented.
@@ -221,7 +507,42 @@ func (o TransactionOptions) SetDebugRetryLogging(param string) error {
 	return o.setOpt(401, []byte(param))
 }
 
-// Set a timeout in milliseconds which, when elapsed, will cause the transaction automatically to be cancelled. Valid parameter values are ``[0, INT_MAX]``. If set to 0, will disable all timeouts. All pending and any future uses of the transaction will throw an exception. The transaction can be used again after it is reset. Like all transaction options, a timeout must be reset after a call to onError. This behavior allows the user to make the timeout dynamic.
+// Deprecated: SetTransactionLoggingEnable is no longer supported by FoundationDB.
+//
+// Parameter: String identifier to be used in the logs when tracing this transaction. The identifier must not exceed 100 characters.
+func (o TransactionOptions) SetTransactionLoggingEnable(param string) error {
+	return o.setOpt(402, []byte(param))
+}
+
+// Sets a client provided identifier for the transaction that will be used in scenarios like tracing or profiling. Client trace logging or transaction profiling must be separately enabled.
+//
+// Parameter: String identifier to be used when tracing or profiling this transaction. The identifier must not exceed 100 characters.
+func (o TransactionOptions) SetDebugTransactionIdentifier(param string) error {
+	return o.setOpt(403, []byte(param))
+}
+
+// Enables tracing for this transaction and logs results to the client trace logs. The DEBUG_TRANSACTION_IDENTIFIER option must be set before using this option, and client trace logging must be enabled to get log output.
+func (o TransactionOptions) SetLogTransaction() error {
+	return o.setOpt(404, nil)
+}
+
+// Sets the maximum escaped length of key and value fields to be logged to the trace file via the LOG_TRANSACTION option, after which the field will be truncated. A negative value disables truncation.
+//
+// Parameter: Maximum length of escaped key and value fields.
+func (o TransactionOptions) SetTransactionLoggingMaxFieldLength(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(405, b)
+}
+
+// Sets an identifier for server tracing of this transaction. When committed, this identifier triggers logging when each part of the transaction authority encounters it, which is helpful in diagnosing slowness in misbehaving clusters. The identifier is randomly generated. When there is also a debug_transaction_identifier, both IDs are logged together.
+func (o TransactionOptions) SetServerRequestTracing() error {
+	return o.setOpt(406, nil)
+}
+
+// Set a timeout in milliseconds which, when elapsed, will cause the transaction automatically to be cancelled. Valid parameter values are ``[0, INT_MAX]``. If set to 0, will disable all timeouts. All pending and any future uses of the transaction will throw an exception. The transaction can be used again after it is reset. Prior to API version 610, like all other transaction options, the timeout must be reset after a call to ``onError``. If the API version is 610 or greater, the timeout is not reset after an ``onError`` call. This allows the user to specify a longer timeout on specific transactions than the default timeout specified through the ``transaction_timeout`` database option without the shorter database timeout cancelling transactions that encounter a retryable error. Note that at all API versions, it is safe and legal to set the timeout each time the transaction begins, so most code written assuming the older behavior can be upgraded to the newer behavior without requiring any modification, and the caller is not required to implement special logic in retry loops to only conditionally set this option.
 //
 // Parameter: value in milliseconds of timeout
 func (o TransactionOptions) SetTimeout(param int64) error {
@@ -232,7 +553,7 @@ func (o TransactionOptions) SetTimeout(param int64) error {
 	return o.setOpt(500, b)
 }
 
-// Set a maximum number of retries after which additional calls to onError will throw the most recently seen error code. Valid parameter values are ``[-1, INT_MAX]``. If set to -1, will disable the retry limit. Like all transaction options, the retry limit must be reset after a call to onError. This behavior allows the user to make the retry limit dynamic.
+// Set a maximum number of retries after which additional calls to ``onError`` will throw the most recently seen error code. Valid parameter values are ``[-1, INT_MAX]``. If set to -1, will disable the retry limit. Prior to API version 610, like all other transaction options, the retry limit must be reset after a call to ``onError``. If the API version is 610 or greater, the retry limit is not reset after an ``onError`` call. Note that at all API versions, it is safe and legal to set the retry limit each time the transaction begins, so most code written assuming the older behavior can be upgraded to the newer behavior without requiring any modification, and the caller is not required to implement special logic in retry loops to only conditionally set this option.
 //
 // Parameter: number of times to retry
 func (o TransactionOptions) SetRetryLimit(param int64) error {
@@ -243,7 +564,7 @@ func (o TransactionOptions) SetRetryLimit(param int64) error {
 	return o.setOpt(501, b)
 }
 
-// Set the maximum amount of backoff delay incurred in the call to onError if the error is retryable. Defaults to 1000 ms. Valid parameter values are ``[0, INT_MAX]``. Like all transaction options, the maximum retry delay must be reset after a call to onError. If the maximum retry delay is less than the current retry delay of the transaction, then the current retry delay will be clamped to the maximum retry delay.
+// Set the maximum amount of backoff delay incurred in the call to ``onError`` if the error is retryable. Defaults to 1000 ms. Valid parameter values are ``[0, INT_MAX]``. If the maximum retry delay is less than the current retry delay of the transaction, then the current retry delay will be clamped to the maximum retry delay. Prior to API version 610, like all other transaction options, the maximum retry delay must be reset after a call to ``onError``. If the API version is 610 or greater, the retry limit is not reset after an ``onError`` call. Note that at all API versions, it is safe and legal to set the maximum retry delay each time the transaction begins, so most code written assuming the older behavior can be upgraded to the newer behavior without requiring any modification, and the caller is not required to implement special logic in retry loops to only conditionally set this option.
 //
 // Parameter: value in milliseconds of maximum delay
 func (o TransactionOptions) SetMaxRetryDelay(param int64) error {
@@ -254,16 +575,98 @@ func (o TransactionOptions) SetMaxRetryDelay(param int64) error {
 	return o.setOpt(502, b)
 }
 
-// Snapshot read operations will see the results of writes done in the same transaction.
+// Set the transaction size limit in bytes. The size is calculated by combining the sizes of all keys and values written or mutated, all key ranges cleared, and all read and write conflict ranges. (In other words, it includes the total size of all data included in the request to the cluster to commit the transaction.) Large transactions can cause performance problems on FoundationDB clusters, so setting this limit to a smaller value than the default can help prevent the client from accidentally degrading the cluster's performance. This value must be at least 32 and cannot be set to higher than 10,000,000, the default transaction size limit.
+//
+// Parameter: value in bytes
+func (o TransactionOptions) SetSizeLimit(param int64) error {
+	b, e := int64ToBytes(param)
+	if e != nil {
+		return e
+	}
+	return o.setOpt(503, b)
+}
+
+// Snapshot read operations will see the results of writes done in the same transaction. This is the default behavior.
 func (o TransactionOptions) SetSnapshotRywEnable() error {
 	return o.setOpt(600, nil)
 }
 
-// Snapshot read operations will not see the results of writes done in the same transaction.
+// Snapshot read operations will not see the results of writes done in the same transaction. This was the default behavior prior to API version 300.
 func (o TransactionOptions) SetSnapshotRywDisable() error {
 	return o.setOpt(601, nil)
 }
 
+// The transaction can read and write to locked databases, and is responsible for checking that it took the lock.
+func (o TransactionOptions) SetLockAware() error {
+	return o.setOpt(700, nil)
+}
+
+// By default, operations that are performed on a transaction while it is being committed will not only fail themselves, but they will attempt to fail other in-flight operations (such as the commit) as well. This behavior is intended to help developers discover situations where operations could be unintentionally executed after the transaction has been reset. Setting this option removes that protection, causing only the offending operation to fail.
+func (o TransactionOptions) SetUsedDuringCommitProtectionDisable() error {
+	return o.setOpt(701, nil)
+}
+
+// The transaction can read from locked databases.
+func (o TransactionOptions) SetReadLockAware() error {
+	return o.setOpt(702, nil)
+}
+
+// This option should only be used by tools which change the database configuration.
+func (o TransactionOptions) SetUseProvisionalProxies() error {
+	return o.setOpt(711, nil)
+}
+
+// The transaction can retrieve keys that are conflicting with other transactions.
+func (o TransactionOptions) SetReportConflictingKeys() error {
+	return o.setOpt(712, nil)
+}
+
+// By default, the special key space will only allow users to read from exactly one module (a subspace in the special key space). Use this option to allow reading from zero or more modules. Users who set this option should be prepared for new modules, which may have different behaviors than the modules they're currently reading. For example, a new module might block or return an error.
+func (o TransactionOptions) SetSpecialKeySpaceRelaxed() error {
+	return o.setOpt(713, nil)
+}
+
+// By default, users are not allowed to write to special keys. Enable this option will implicitly enable all options required to achieve the configuration change.
+func (o TransactionOptions) SetSpecialKeySpaceEnableWrites() error {
+	return o.setOpt(714, nil)
+}
+
+// Adds a tag to the transaction that can be used to apply manual targeted throttling. At most 5 tags can be set on a transaction.
+//
+// Parameter: String identifier used to associated this transaction with a throttling group. Must not exceed 16 characters.
+func (o TransactionOptions) SetTag(param string) error {
+	return o.setOpt(800, []byte(param))
+}
+
+// Adds a tag to the transaction that can be used to apply manual or automatic targeted throttling. At most 5 tags can be set on a transaction.
+//
+// Parameter: String identifier used to associated this transaction with a throttling group. Must not exceed 16 characters.
+func (o TransactionOptions) SetAutoThrottleTag(param string) error {
+	return o.setOpt(801, []byte(param))
+}
+
+// Adds a parent to the Span of this transaction. Used for transaction tracing. A span can be identified with any 16 bytes
+//
+// Parameter: A byte string of length 16 used to associate the span of this transaction with a parent
+func (o TransactionOptions) SetSpanParent(param []byte) error {
+	return o.setOpt(900, param)
+}
+
+// Asks storage servers for how many bytes a clear key range contains. Otherwise uses the location cache to roughly estimate this.
+func (o TransactionOptions) SetExpensiveClearCostEstimationEnable() error {
+	return o.setOpt(1000, nil)
+}
+
+// Allows ``get`` operations to read from sections of keyspace that have become unreadable because of versionstamp operations. These reads will view versionstamp operations as if they were set operations that did not fill in the versionstamp.
+func (o TransactionOptions) SetBypassUnreadable() error {
+	return o.setOpt(1100, nil)
+}
+
+// Allows this transaction to use cached GRV from the database context. Defaults to off. Upon first usage, starts a background updater to periodically update the cache to avoid stale read versions.
+func (o TransactionOptions) SetUseGrvCache() error {
+	return o.setOpt(1101, nil)
+}
+
 type StreamingMode int
 const (
 
@@ -272,22 +675,23 @@ const (
 	StreamingModeWantAll StreamingMode = -1
 
     // The default. The client doesn't know how much of the range it is likely
-    // to used and wants different performance concerns to be balanced. Only a
-    // small portion of data is transferred to the client initially (in order to
-    // minimize costs if the client doesn't read the entire range), and as the
-    // caller iterates over more items in the range larger batches will be
-    // transferred in order to minimize latency.
+    // to used and wants different performance concerns to be balanced.
+    // Only a small portion of data is transferred to the client initially (in
+    // order to minimize costs if the client doesn't read the entire range), and
+    // as the caller iterates over more items in the range larger batches will
+    // be transferred in order to minimize latency. After enough iterations,
+    // the iterator mode will eventually reach the same byte limit as “WANT_ALL“
 	StreamingModeIterator StreamingMode = 0
 
     // Infrequently used. The client has passed a specific row limit and wants
     // that many rows delivered in a single batch. Because of iterator operation
     // in client drivers make request batches transparent to the user, consider
-    // ``WANT_ALL`` StreamingMode instead. A row limit must be specified if this
+    // “WANT_ALL“ StreamingMode instead. A row limit must be specified if this
     // mode is used.
 	StreamingModeExact StreamingMode = 1
 
-    // Infrequently used. Transfer data in batches small enough to not be much
-    // more expensive than reading individual rows, to minimize cost if
+    // Infrequently used. Transfer data in batches small enough to not be
+    // much more expensive than reading individual rows, to minimize cost if
     // iteration stops early.
 	StreamingModeSmall StreamingMode = 2
 
@@ -295,16 +699,16 @@ const (
     // large.
 	StreamingModeMedium StreamingMode = 3
 
-    // Infrequently used. Transfer data in batches large enough to be, in a
-    // high-concurrency environment, nearly as efficient as possible. If the
-    // client stops iteration early, some disk and network bandwidth may be
-    // wasted. The batch size may still be too small to allow a single client to
-    // get high throughput from the database, so if that is what you need
+    // Infrequently used. Transfer data in batches large enough to be,
+    // in a high-concurrency environment, nearly as efficient as possible.
+    // If the client stops iteration early, some disk and network bandwidth may
+    // be wasted. The batch size may still be too small to allow a single client
+    // to get high throughput from the database, so if that is what you need
     // consider the SERIAL StreamingMode.
 	StreamingModeLarge StreamingMode = 4
 
-    // Transfer data in batches large enough that an individual client can get
-    // reasonable read bandwidth from the database. If the client stops
+    // Transfer data in batches large enough that an individual client can
+    // get reasonable read bandwidth from the database. If the client stops
     // iteration early, considerable disk and network bandwidth may be wasted.
 	StreamingModeSerial StreamingMode = 5
 )
@@ -314,7 +718,7 @@ func (t Transaction) Add(key KeyConvertible, param []byte) {
 	t.atomicOp(key.FDBKey(), param, 2)
 }
 
-// BitAnd performs a bitwise ``and`` operation.  If the existing value in the database is not present or shorter than ``param``, it is first extended to the length of ``param`` with zero bytes.  If ``param`` is shorter than the existing value in the database, the existing value is truncated to match the length of ``param``.
+// BitAnd performs a bitwise ``and`` operation.  If the existing value in the database is not present, then ``param`` is stored in the database. If the existing value in the database is shorter than ``param``, it is first extended to the length of ``param`` with zero bytes.  If ``param`` is shorter than the existing value in the database, the existing value is truncated to match the length of ``param``.
 func (t Transaction) BitAnd(key KeyConvertible, param []byte) {
 	t.atomicOp(key.FDBKey(), param, 6)
 }
@@ -329,16 +733,46 @@ func (t Transaction) BitXor(key KeyConvertible, param []byte) {
 	t.atomicOp(key.FDBKey(), param, 8)
 }
 
+// AppendIfFits appends ``param`` to the end of the existing value already in the database at the given key (or creates the key and sets the value to ``param`` if the key is empty). This will only append the value if the final concatenated value size is less than or equal to the maximum value size (i.e., if it fits). WARNING: No error is surfaced back to the user if the final value is too large because the mutation will not be applied until after the transaction has been committed. Therefore, it is only safe to use this mutation type if one can guarantee that one will keep the total value size under the maximum size.
+func (t Transaction) AppendIfFits(key KeyConvertible, param []byte) {
+	t.atomicOp(key.FDBKey(), param, 9)
+}
+
 // Max performs a little-endian comparison of byte strings. If the existing value in the database is not present or shorter than ``param``, it is first extended to the length of ``param`` with zero bytes.  If ``param`` is shorter than the existing value in the database, the existing value is truncated to match the length of ``param``. The larger of the two values is then stored in the database.
 func (t Transaction) Max(key KeyConvertible, param []byte) {
 	t.atomicOp(key.FDBKey(), param, 12)
 }
 
-// Min performs a little-endian comparison of byte strings. If the existing value in the database is not present or shorter than ``param``, it is first extended to the length of ``param`` with zero bytes.  If ``param`` is shorter than the existing value in the database, the existing value is truncated to match the length of ``param``. The smaller of the two values is then stored in the database.
+// Min performs a little-endian comparison of byte strings. If the existing value in the database is not present, then ``param`` is stored in the database. If the existing value in the database is shorter than ``param``, it is first extended to the length of ``param`` with zero bytes.  If ``param`` is shorter than the existing value in the database, the existing value is truncated to match the length of ``param``. The smaller of the two values is then stored in the database.
 func (t Transaction) Min(key KeyConvertible, param []byte) {
 	t.atomicOp(key.FDBKey(), param, 13)
 }
 
+// SetVersionstampedKey transforms ``key`` using a versionstamp for the transaction. Sets the transformed key in the database to ``param``. The key is transformed by removing the final four bytes from the key and reading those as a little-Endian 32-bit integer to get a position ``pos``. The 10 bytes of the key from ``pos`` to ``pos + 10`` are replaced with the versionstamp of the transaction used. The first byte of the key is position 0. A versionstamp is a 10 byte, unique, monotonically (but not sequentially) increasing value for each committed transaction. The first 8 bytes are the committed version of the database (serialized in big-Endian order). The last 2 bytes are monotonic in the serialization order for transactions. WARNING: At this time, versionstamps are compatible with the Tuple layer only in the Java, Python, and Go bindings. Also, note that prior to API version 520, the offset was computed from only the final two bytes rather than the final four bytes.
+func (t Transaction) SetVersionstampedKey(key KeyConvertible, param []byte) {
+	t.atomicOp(key.FDBKey(), param, 14)
+}
+
+// SetVersionstampedValue transforms ``param`` using a versionstamp for the transaction. Sets the ``key`` given to the transformed ``param``. The parameter is transformed by removing the final four bytes from ``param`` and reading those as a little-Endian 32-bit integer to get a position ``pos``. The 10 bytes of the parameter from ``pos`` to ``pos + 10`` are replaced with the versionstamp of the transaction used. The first byte of the parameter is position 0. A versionstamp is a 10 byte, unique, monotonically (but not sequentially) increasing value for each committed transaction. The first 8 bytes are the committed version of the database (serialized in big-Endian order). The last 2 bytes are monotonic in the serialization order for transactions. WARNING: At this time, versionstamps are compatible with the Tuple layer only in the Java, Python, and Go bindings. Also, note that prior to API version 520, the versionstamp was always placed at the beginning of the parameter rather than computing an offset.
+func (t Transaction) SetVersionstampedValue(key KeyConvertible, param []byte) {
+	t.atomicOp(key.FDBKey(), param, 15)
+}
+
+// ByteMin performs lexicographic comparison of byte strings. If the existing value in the database is not present, then ``param`` is stored. Otherwise the smaller of the two values is then stored in the database.
+func (t Transaction) ByteMin(key KeyConvertible, param []byte) {
+	t.atomicOp(key.FDBKey(), param, 16)
+}
+
+// ByteMax performs lexicographic comparison of byte strings. If the existing value in the database is not present, then ``param`` is stored. Otherwise the larger of the two values is then stored in the database.
+func (t Transaction) ByteMax(key KeyConvertible, param []byte) {
+	t.atomicOp(key.FDBKey(), param, 17)
+}
+
+// CompareAndClear performs an atomic ``compare and clear`` operation. If the existing value in the database is equal to the given value, then given key is cleared.
+func (t Transaction) CompareAndClear(key KeyConvertible, param []byte) {
+	t.atomicOp(key.FDBKey(), param, 20)
+}
+
 type conflictRangeType int
 const (
 
@@ -348,3 +782,19 @@ const (
     // Used to add a write conflict range
 	conflictRangeTypeWrite conflictRangeType = 1
 )
+
+type ErrorPredicate int
+const (
+
+    // Returns “true“ if the error indicates the operations in the transactions
+    // should be retried because of transient error.
+	ErrorPredicateRetryable ErrorPredicate = 50000
+
+    // Returns “true“ if the error indicates the transaction may have succeeded,
+    // though not in a way the system can verify.
+	ErrorPredicateMaybeCommitted ErrorPredicate = 50001
+
+    // Returns “true“ if the error indicates the transaction has not committed,
+    // though in a way that can be retried.
+	ErrorPredicateRetryableNotCommitted ErrorPredicate = 50002
+)
@@ -0,0 +1,253 @@
+// FoundationDB Go In-Memory Database
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+
+package memdb
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"context"
+	"sync"
+)
+
+var errOperationCancelled = fdb.Error{Code: 1101}
+
+// future is the common implementation of the fdb.Future interface. Most
+// futures are ready as soon as they are created; watches and the backoff
+// delay returned by OnError become ready later.
+type future struct {
+	ready chan struct{}
+	once sync.Once
+	err error
+	onCancel func()
+}
+
+func newFuture() *future {
+	return &future{ready: make(chan struct{})}
+}
+
+func readyFuture(err error) *future {
+	f := newFuture()
+	f.set(err)
+	return f
+}
+
+// set makes the future ready with the provided error (or nil). Only the first
+// call has any effect.
+func (f *future) set(err error) bool {
+	var set bool
+	f.once.Do(func() {
+		f.err = err
+		close(f.ready)
+		set = true
+	})
+	return set
+}
+
+func (f *future) BlockUntilReady() {
+	<-f.ready
+}
+
+func (f *future) BlockUntilReadyContext(ctx context.Context) error {
+	// A future that is already ready is returned even if the context is done,
+	// as it is by futures of the FoundationDB C library
+	if f.IsReady() {
+		return nil
+	}
+
+	select {
+	case <-f.ready:
+		return nil
+	case <-ctx.Done():
+		f.Cancel()
+		return ctx.Err()
+	}
+}
+
+func (f *future) IsReady() bool {
+	select {
+	case <-f.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *future) Cancel() {
+	if f.set(errOperationCancelled) && f.onCancel != nil {
+		f.onCancel()
+	}
+}
+
+type futureByteSlice struct {
+	*future
+	v []byte
+}
+
+func (f *futureByteSlice) Get() ([]byte, error) {
+	f.BlockUntilReady()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.v, nil
+}
+
+func (f *futureByteSlice) MustGet() []byte {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f *futureByteSlice) GetContext(ctx context.Context) ([]byte, error) {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return nil, e
+	}
+	return f.Get()
+}
+
+type futureKey struct {
+	*future
+	k fdb.Key
+}
+
+func (f *futureKey) Get() (fdb.Key, error) {
+	f.BlockUntilReady()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.k, nil
+}
+
+func (f *futureKey) MustGet() fdb.Key {
+	k, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return k
+}
+
+func (f *futureKey) GetContext(ctx context.Context) (fdb.Key, error) {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return nil, e
+	}
+	return f.Get()
+}
+
+type futureNil struct {
+	*future
+}
+
+func (f futureNil) Get() error {
+	f.BlockUntilReady()
+	return f.err
+}
+
+func (f futureNil) MustGet() {
+	if e := f.Get(); e != nil {
+		panic(e)
+	}
+}
+
+func (f futureNil) GetContext(ctx context.Context) error {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return e
+	}
+	return f.Get()
+}
+
+type futureInt64 struct {
+	*future
+	v int64
+}
+
+func (f *futureInt64) Get() (int64, error) {
+	f.BlockUntilReady()
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.v, nil
+}
+
+func (f *futureInt64) MustGet() int64 {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f *futureInt64) GetContext(ctx context.Context) (int64, error) {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return 0, e
+	}
+	return f.Get()
+}
+
+type futureStringSlice struct {
+	*future
+	v []string
+}
+
+func (f *futureStringSlice) Get() ([]string, error) {
+	f.BlockUntilReady()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.v, nil
+}
+
+func (f *futureStringSlice) MustGet() []string {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f *futureStringSlice) GetContext(ctx context.Context) ([]string, error) {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return nil, e
+	}
+	return f.Get()
+}
+
+type futureKeyValueArray struct {
+	*future
+	kvs []fdb.KeyValue
+	more bool
+}
+
+func (f *futureKeyValueArray) Get() ([]fdb.KeyValue, bool, error) {
+	f.BlockUntilReady()
+	if f.err != nil {
+		return nil, false, f.err
+	}
+	return f.kvs, f.more, nil
+}
+
+func (f *futureKeyValueArray) GetContext(ctx context.Context) ([]fdb.KeyValue, bool, error) {
+	if e := f.BlockUntilReadyContext(ctx); e != nil {
+		return nil, false, e
+	}
+	return f.Get()
+}
@@ -0,0 +1,371 @@
+// FoundationDB Go In-Memory Database
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+
+// Package memdb provides an in-memory implementation of a FoundationDB
+// database, suitable for testing code written against the fdb package without
+// a running FoundationDB cluster. A database returned by New is an ordinary
+// fdb.Database: it may be used wherever an fdb.Transactor or
+// fdb.ReadTransactor is accepted, including with the directory, subspace and
+// tuple packages, and does not require an API version to be selected or the
+// network to be started.
+//
+// Transactions on an in-memory database follow the semantics of FoundationDB
+// transactions:
+//
+//   - Each transaction reads from a consistent snapshot of the database at its
+//     read version (MVCC). Versions older than five seconds are discarded, after
+//     which reads and commits at those versions fail with transaction_too_old
+//     (1007).
+//   - Commits are serializable: a transaction whose read conflict ranges were
+//     modified by another transaction committed after its read version fails
+//     with not_committed (1020). Snapshot reads do not add read conflict
+//     ranges.
+//   - Reads see the effects of earlier writes in the same transaction, subject
+//     to the ReadYourWritesDisable and SnapshotRyw options.
+//   - Key selectors, range limits and reverse range reads, atomic operations
+//     (including versionstamp operations), watches and the conflict range
+//     methods behave as they do against a cluster. The versionstamp of a
+//     transaction is its commit version followed by a batch order of zero.
+//   - OnError retries not_committed, transaction_too_old, future_version and
+//     commit_unknown_result errors with an exponential backoff, honoring the
+//     Timeout, RetryLimit and MaxRetryDelay options. As of API version 610,
+//     these three options are preserved by OnError.
+//   - The transaction defaults among the database options (SnapshotRyw and
+//     the TransactionTimeout, TransactionRetryLimit and
+//     TransactionMaxRetryDelay options) apply to transactions created, or
+//     reset, after they are set.
+//   - Behavior that depends on the API version (such as the treatment of
+//     missing values by the BitAnd and Min operations) follows the version
+//     selected with fdb.APIVersion, or the most recent version if none has
+//     been selected.
+//
+// Locality information is not available: LocalityGetAddressesForKey returns
+// no addresses, and LocalityGetBoundaryKeys returns no keys. Options without
+// an in-memory equivalent are accepted and ignored.
+//
+// An in-memory database never calls into the FoundationDB C library. Programs
+// built with cgo still link against it (as the fdb package does); programs
+// built without cgo (CGO_ENABLED=0) need neither the library nor a running
+// cluster, although fdb.OpenDatabase and the other functions that require the
+// library then return an error.
+package memdb
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The duration for which old versions of the database remain readable, and
+// the period over which commits are checked for conflicts.
+const mvccWindow = 5 * time.Second
+
+const defaultMaxWatches = 10000
+
+// New returns a new, empty in-memory database.
+func New() fdb.Database {
+	return fdb.NewDatabase(NewBackend())
+}
+
+// NewBackend returns the backend of a new, empty in-memory database, for use
+// with fdb.NewDatabase by a DatabaseBackend that wraps it.
+func NewBackend() fdb.DatabaseBackend {
+	return &database{newStore()}
+}
+
+type database struct {
+	s *store
+}
+
+func (d *database) CreateTransaction() (fdb.TransactionBackend, error) {
+	return newTransaction(d.s), nil
+}
+
+func (d *database) SetOption(code int, param []byte) error {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	switch code {
+	case 20: // MaxWatches
+		n, e := int64Param(param)
+		if e != nil {
+			return e
+		}
+		d.s.maxWatches = int(n)
+	case 26: // SnapshotRywEnable
+		delete(d.s.txDefaults, 601)
+		d.s.txDefaults[600] = nil
+	case 27: // SnapshotRywDisable
+		delete(d.s.txDefaults, 600)
+		d.s.txDefaults[601] = nil
+	case 500, 501, 502: // TransactionTimeout, TransactionRetryLimit, TransactionMaxRetryDelay
+		if _, e := int64Param(param); e != nil {
+			return e
+		}
+		d.s.txDefaults[code] = copyBytes(param)
+	}
+	return nil
+}
+
+type revision struct {
+	version int64
+	value []byte
+	present bool
+}
+
+type keyRange struct {
+	begin, end string
+}
+
+func (r keyRange) intersects(o keyRange) bool {
+	return r.begin < o.end && o.begin < r.end
+}
+
+func (r keyRange) contains(key string) bool {
+	return r.begin <= key && key < r.end
+}
+
+func keyAfter(key string) string {
+	return key + "\x00"
+}
+
+type commitRecord struct {
+	version int64
+	at time.Time
+	writes []keyRange
+}
+
+type watch struct {
+	key string
+	value []byte
+	present bool
+	f *future
+}
+
+// store holds the committed, versioned contents of an in-memory database.
+type store struct {
+	mu sync.Mutex
+
+	// The most recently committed version, and the oldest version that may
+	// still be read.
+	version int64
+	oldest int64
+
+	// All keys with retained history, in order.
+	keys []string
+	history map[string][]revision
+
+	// Recently committed transactions, oldest first.
+	commits []commitRecord
+	compacted time.Time
+
+	watches map[string][]*watch
+	nwatches int
+	maxWatches int
+
+	// Transaction options set on the database, by transaction option code.
+	txDefaults map[int][]byte
+}
+
+func newStore() *store {
+	return &store{
+		history: make(map[string][]revision),
+		txDefaults: make(map[int][]byte),
+		watches: make(map[string][]*watch),
+		maxWatches: defaultMaxWatches,
+		compacted: time.Now(),
+	}
+}
+
+func (s *store) valueAt(key string, version int64) ([]byte, bool) {
+	revs := s.history[key]
+	i := sort.Search(len(revs), func(i int) bool { return revs[i].version > version })
+	if i == 0 {
+		return nil, false
+	}
+	return revs[i-1].value, revs[i-1].present
+}
+
+// write records a new value for key at version (which must not be older than
+// any existing revision of key) and notifies any watches of the change.
+func (s *store) write(key string, version int64, value []byte, present bool) {
+	old, wasPresent := s.valueAt(key, version)
+	if !present && !wasPresent {
+		return
+	}
+
+	revs, ok := s.history[key]
+	if !ok {
+		i := sort.SearchStrings(s.keys, key)
+		s.keys = append(s.keys, "")
+		copy(s.keys[i+1:], s.keys[i:])
+		s.keys[i] = key
+	}
+
+	if n := len(revs); n > 0 && revs[n-1].version == version {
+		revs[n-1] = revision{version, value, present}
+	} else {
+		revs = append(revs, revision{version, value, present})
+	}
+	s.history[key] = revs
+
+	if present != wasPresent || !bytes.Equal(value, old) {
+		s.notify(key, value, present)
+	}
+}
+
+func (s *store) clearRange(r keyRange, version int64) {
+	i := sort.SearchStrings(s.keys, r.begin)
+	var cleared []string
+	for ; i < len(s.keys) && s.keys[i] < r.end; i++ {
+		cleared = append(cleared, s.keys[i])
+	}
+	for _, k := range cleared {
+		s.write(k, version, nil, false)
+	}
+}
+
+// checkConflicts returns not_committed if any transaction committed after
+// readVersion wrote to one of the provided read conflict ranges.
+func (s *store) checkConflicts(readVersion int64, reads []keyRange) error {
+	if readVersion < s.oldest {
+		return errTransactionTooOld
+	}
+
+	for i := len(s.commits) - 1; i >= 0 && s.commits[i].version > readVersion; i-- {
+		for _, w := range s.commits[i].writes {
+			for _, r := range reads {
+				if w.intersects(r) {
+					return errNotCommitted
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// record remembers the write conflict ranges of a commit, and discards
+// versions that have fallen outside of the MVCC window.
+func (s *store) record(version int64, writes []keyRange) {
+	now := time.Now()
+
+	s.commits = append(s.commits, commitRecord{version, now, writes})
+
+	var i int
+	for i < len(s.commits) && now.Sub(s.commits[i].at) > mvccWindow {
+		s.oldest = s.commits[i].version
+		i++
+	}
+	if i > 0 {
+		s.commits = append([]commitRecord(nil), s.commits[i:]...)
+	}
+
+	if now.Sub(s.compacted) > mvccWindow {
+		s.compact()
+		s.compacted = now
+	}
+}
+
+// compact discards revisions that are no longer visible at any readable
+// version.
+func (s *store) compact() {
+	keys := s.keys[:0]
+
+	for _, k := range s.keys {
+		revs := s.history[k]
+		i := sort.Search(len(revs), func(i int) bool { return revs[i].version > s.oldest })
+		if i > 1 {
+			revs = append([]revision(nil), revs[i-1:]...)
+		}
+		if len(revs) == 1 && !revs[0].present && revs[0].version <= s.oldest {
+			delete(s.history, k)
+			continue
+		}
+		s.history[k] = revs
+		keys = append(keys, k)
+	}
+
+	s.keys = keys
+}
+
+func (s *store) addWatch(w *watch) {
+	if w.f.IsReady() {
+		return
+	}
+
+	if s.nwatches >= s.maxWatches {
+		w.f.set(errTooManyWatches)
+		return
+	}
+
+	value, present := s.valueAt(w.key, s.version)
+	if present != w.present || !bytes.Equal(value, w.value) {
+		w.f.set(nil)
+		return
+	}
+
+	s.watches[w.key] = append(s.watches[w.key], w)
+	s.nwatches++
+}
+
+func (s *store) removeWatch(w *watch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws := s.watches[w.key]
+	for i := range ws {
+		if ws[i] == w {
+			s.watches[w.key] = append(ws[:i:i], ws[i+1:]...)
+			s.nwatches--
+			break
+		}
+	}
+	if len(s.watches[w.key]) == 0 {
+		delete(s.watches, w.key)
+	}
+}
+
+func (s *store) notify(key string, value []byte, present bool) {
+	ws := s.watches[key]
+	if len(ws) == 0 {
+		return
+	}
+
+	remaining := ws[:0]
+	for _, w := range ws {
+		if present != w.present || !bytes.Equal(value, w.value) {
+			w.f.set(nil)
+			s.nwatches--
+		} else {
+			remaining = append(remaining, w)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(s.watches, key)
+	} else {
+		s.watches[key] = remaining
+	}
+}
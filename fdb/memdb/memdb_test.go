@@ -0,0 +1,474 @@
+// FoundationDB Go In-Memory Database
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+
+package memdb_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/memdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func errorCode(e error) int {
+	if ep, ok := e.(fdb.Error); ok {
+		return ep.Code
+	}
+	return 0
+}
+
+func set(t *testing.T, db fdb.Database, kvs ...string) {
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for i := 0; i < len(kvs); i += 2 {
+			tr.Set(fdb.Key(kvs[i]), []byte(kvs[i+1]))
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func keys(kvs []fdb.KeyValue) string {
+	var b bytes.Buffer
+	for _, kv := range kvs {
+		b.Write(kv.Key)
+	}
+	return b.String()
+}
+
+func ExampleNew() {
+	db := memdb.New()
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fdb.Key("hello"), []byte("world"))
+		return nil, nil
+	})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+
+	v, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(fdb.Key("hello")).Get()
+	})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+
+	fmt.Printf("%s\n", v)
+
+	// Output:
+	// world
+}
+
+func TestReadYourWrites(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "a", "1", "b", "2", "c", "3")
+
+	tr, _ := db.CreateTransaction()
+	tr.Set(fdb.Key("bb"), []byte("4"))
+	tr.ClearRange(fdb.KeyRange{Begin: fdb.Key("c"), End: fdb.Key("d")})
+	tr.Clear(fdb.Key("a"))
+
+	if v := tr.Get(fdb.Key("bb")).MustGet(); string(v) != "4" {
+		t.Errorf("got %q for uncommitted write, expected \"4\"", v)
+	}
+	if v := tr.Get(fdb.Key("a")).MustGet(); v != nil {
+		t.Errorf("got %q for cleared key, expected nil", v)
+	}
+	if k := keys(tr.GetRange(fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key("\xff")}, fdb.RangeOptions{}).GetSliceOrPanic()); k != "bbb" {
+		t.Errorf("got keys %q, expected \"bbb\"", k)
+	}
+
+	tr.Options().SetReadYourWritesDisable()
+	tr.Reset()
+	tr.Options().SetReadYourWritesDisable()
+	tr.Set(fdb.Key("a"), []byte("5"))
+	if v := tr.Get(fdb.Key("a")).MustGet(); string(v) != "1" {
+		t.Errorf("got %q with read your writes disabled, expected \"1\"", v)
+	}
+
+	// Writes are invisible to other transactions until committed
+	tr2, _ := db.CreateTransaction()
+	if v := tr2.Get(fdb.Key("a")).MustGet(); string(v) != "1" {
+		t.Errorf("got %q from another transaction, expected \"1\"", v)
+	}
+	tr.Commit().MustGet()
+	if v := tr2.Get(fdb.Key("a")).MustGet(); string(v) != "1" {
+		t.Errorf("got %q at an earlier read version, expected \"1\"", v)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "a", "1")
+
+	tr1, _ := db.CreateTransaction()
+	tr1.Get(fdb.Key("a")).MustGet()
+	tr1.Snapshot().Get(fdb.Key("b")).MustGet()
+	tr1.Set(fdb.Key("c"), []byte("1"))
+
+	set(t, db, "b", "2")
+
+	// Snapshot reads do not conflict
+	tr1.Commit().MustGet()
+
+	tr2, _ := db.CreateTransaction()
+	tr2.GetRange(fdb.KeyRange{Begin: fdb.Key("a"), End: fdb.Key("b")}, fdb.RangeOptions{}).GetSliceOrPanic()
+	tr2.Set(fdb.Key("d"), []byte("1"))
+
+	set(t, db, "a", "3")
+
+	e := tr2.Commit().Get()
+	if errorCode(e) != 1020 {
+		t.Fatalf("got error %v, expected not_committed", e)
+	}
+
+	if e = tr2.OnError(e.(fdb.Error)).Get(); e != nil {
+		t.Fatalf("OnError returned %v", e)
+	}
+	tr2.GetRange(fdb.KeyRange{Begin: fdb.Key("a"), End: fdb.Key("b")}, fdb.RangeOptions{}).GetSliceOrPanic()
+	tr2.Set(fdb.Key("d"), []byte("1"))
+	if e = tr2.Commit().Get(); e != nil {
+		t.Fatalf("got error %v after retry", e)
+	}
+}
+
+func TestTransactRetries(t *testing.T) {
+	db := memdb.New()
+	key := fdb.Key("counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+					var n uint64
+					if v := tr.Get(key).MustGet(); v != nil {
+						n = binary.LittleEndian.Uint64(v)
+					}
+					b := make([]byte, 8)
+					binary.LittleEndian.PutUint64(b, n+1)
+					tr.Set(key, b)
+					return nil, nil
+				})
+				if e != nil {
+					t.Error(e)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, _ := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(key).MustGet(), nil
+	})
+	if n := binary.LittleEndian.Uint64(v.([]byte)); n != 100 {
+		t.Errorf("got counter %d, expected 100", n)
+	}
+}
+
+func TestRetryOptions(t *testing.T) {
+	db := memdb.New()
+	db.Options().SetTransactionRetryLimit(1)
+
+	tr, _ := db.CreateTransaction()
+	if e := tr.OnError(fdb.Error{Code: 1020}).Get(); e != nil {
+		t.Fatalf("first retry failed: %v", e)
+	}
+	// The retry limit is preserved by OnError
+	if errorCode(tr.OnError(fdb.Error{Code: 1020}).Get()) != 1020 {
+		t.Fatal("retry limit was not enforced")
+	}
+
+	tr.Reset()
+	tr.Options().SetRetryLimit(0)
+	if errorCode(tr.OnError(fdb.Error{Code: 1020}).Get()) != 1020 {
+		t.Fatal("transaction retry limit was not enforced")
+	}
+
+	// Reset restores the database default
+	tr.Reset()
+	if e := tr.OnError(fdb.Error{Code: 1020}).Get(); e != nil {
+		t.Fatalf("retry after reset failed: %v", e)
+	}
+}
+
+func TestKeySelectors(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "a", "", "b", "", "c", "")
+
+	tr, _ := db.CreateTransaction()
+	tests := []struct {
+		sel fdb.KeySelector
+		key string
+	}{
+		{fdb.FirstGreaterOrEqual(fdb.Key("b")), "b"},
+		{fdb.FirstGreaterOrEqual(fdb.Key("bb")), "c"},
+		{fdb.FirstGreaterThan(fdb.Key("b")), "c"},
+		{fdb.LastLessThan(fdb.Key("b")), "a"},
+		{fdb.LastLessOrEqual(fdb.Key("b")), "b"},
+		{fdb.KeySelector{Key: fdb.Key("a"), OrEqual: false, Offset: 3}, "c"},
+		{fdb.KeySelector{Key: fdb.Key("c"), OrEqual: true, Offset: -1}, "b"},
+		{fdb.FirstGreaterThan(fdb.Key("c")), "\xff"},
+		{fdb.LastLessThan(fdb.Key("a")), ""},
+	}
+	for _, test := range tests {
+		if k := tr.GetKey(test.sel).MustGet(); string(k) != test.key {
+			t.Errorf("%+v resolved to %q, expected %q", test.sel, k, test.key)
+		}
+	}
+}
+
+func TestRangeOptions(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "a", "", "b", "", "c", "", "d", "")
+
+	tr, _ := db.CreateTransaction()
+	r := fdb.KeyRange{Begin: fdb.Key("b"), End: fdb.Key("\xff")}
+	tests := []struct {
+		options fdb.RangeOptions
+		keys string
+	}{
+		{fdb.RangeOptions{}, "bcd"},
+		{fdb.RangeOptions{Limit: 2}, "bc"},
+		{fdb.RangeOptions{Reverse: true}, "dcb"},
+		{fdb.RangeOptions{Limit: 2, Reverse: true}, "dc"},
+	}
+	for _, test := range tests {
+		if k := keys(tr.GetRange(r, test.options).GetSliceOrPanic()); k != test.keys {
+			t.Errorf("%+v returned %q, expected %q", test.options, k, test.keys)
+		}
+	}
+
+	sr := fdb.SelectorRange{Begin: fdb.FirstGreaterThan(fdb.Key("a")), End: fdb.LastLessOrEqual(fdb.Key("d"))}
+	if k := keys(tr.GetRange(sr, fdb.RangeOptions{}).GetSliceOrPanic()); k != "bc" {
+		t.Errorf("selector range returned %q, expected \"bc\"", k)
+	}
+}
+
+func TestAtomicOps(t *testing.T) {
+	db := memdb.New()
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Add(fdb.Key("add"), []byte{0xff, 0x00})
+		tr.Add(fdb.Key("add"), []byte{0x01, 0x00})
+		tr.Max(fdb.Key("max"), []byte{0x02})
+		tr.Set(fdb.Key("min"), []byte{0x05, 0x01})
+		tr.Min(fdb.Key("min"), []byte{0x07, 0x00})
+		tr.BitOr(fdb.Key("or"), []byte{0x0f})
+		tr.BitAnd(fdb.Key("and"), []byte{0x0f})
+		tr.AppendIfFits(fdb.Key("append"), []byte("ab"))
+		tr.AppendIfFits(fdb.Key("append"), []byte("cd"))
+		tr.ByteMin(fdb.Key("bytemin"), []byte("b"))
+		tr.ByteMin(fdb.Key("bytemin"), []byte("ab"))
+		tr.ByteMax(fdb.Key("bytemax"), []byte("ab"))
+		tr.ByteMax(fdb.Key("bytemax"), []byte("b"))
+		tr.Set(fdb.Key("cac"), []byte("x"))
+		tr.Set(fdb.Key("cac2"), []byte("y"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.BitAnd(fdb.Key("or"), []byte{0x3c})
+		tr.BitXor(fdb.Key("or"), []byte{0x01})
+		tr.CompareAndClear(fdb.Key("cac"), []byte("x"))
+		tr.CompareAndClear(fdb.Key("cac2"), []byte("x"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tr, _ := db.CreateTransaction()
+	tests := map[string][]byte{
+		"add": {0x00, 0x01},
+		"max": {0x02},
+		"min": {0x07, 0x00},
+		"or": {0x0d},
+		"and": {0x0f},
+		"append": []byte("abcd"),
+		"bytemin": []byte("ab"),
+		"bytemax": []byte("b"),
+		"cac": nil,
+		"cac2": []byte("y"),
+	}
+	for k, expected := range tests {
+		if v := tr.Get(fdb.Key(k)).MustGet(); !bytes.Equal(v, expected) {
+			t.Errorf("got %x for %s, expected %x", v, k, expected)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "w", "1")
+
+	tr, _ := db.CreateTransaction()
+	w := tr.Watch(fdb.Key("w"))
+	tr.Commit().MustGet()
+
+	set(t, db, "w", "1")
+	if w.IsReady() {
+		t.Fatal("watch fired without a change")
+	}
+
+	set(t, db, "w", "2")
+	select {
+	case <-wait(w):
+	case <-time.After(time.Second):
+		t.Fatal("watch did not fire")
+	}
+	if e := w.Get(); e != nil {
+		t.Fatal(e)
+	}
+
+	tr, _ = db.CreateTransaction()
+	w = tr.Watch(fdb.Key("w"))
+	tr.Cancel()
+	if e := w.Get(); errorCode(e) != 1025 {
+		t.Errorf("got error %v from watch of cancelled transaction, expected transaction_cancelled", e)
+	}
+}
+
+func wait(f fdb.Future) <-chan struct{} {
+	c := make(chan struct{})
+	go func() {
+		f.BlockUntilReady()
+		close(c)
+	}()
+	return c
+}
+
+func TestDirectoryLayer(t *testing.T) {
+	db := memdb.New()
+
+	dir, e := directory.CreateOrOpen(db, []string{"app", "users"}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(dir.Pack(tuple.Tuple{"alice", 1}), []byte("x"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	moved, e := directory.Move(db, []string{"app", "users"}, []string{"app", "people"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	names, e := directory.List(db, []string{"app"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(names) != 1 || names[0] != "people" {
+		t.Errorf("got %v, expected [people]", names)
+	}
+
+	kvs, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.GetRange(moved, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(kvs.([]fdb.KeyValue)) != 1 {
+		t.Fatalf("got %d keys in moved directory, expected 1", len(kvs.([]fdb.KeyValue)))
+	}
+	tup, e := moved.Unpack(kvs.([]fdb.KeyValue)[0].Key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if tup[0] != "alice" || tup[1] != int64(1) {
+		t.Errorf("got %v, expected (alice, 1)", tup)
+	}
+}
+
+func TestVersionstamps(t *testing.T) {
+	db := memdb.New()
+	log := subspace.Sub("log")
+
+	var stamps []fdb.FutureKey
+	for i := 0; i < 3; i++ {
+		tr, _ := db.CreateTransaction()
+		for j := 0; j < 2; j++ {
+			k, e := log.PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(uint16(j))})
+			if e != nil {
+				t.Fatal(e)
+			}
+			tr.SetVersionstampedKey(k, []byte(fmt.Sprintf("%d.%d", i, j)))
+		}
+		tr.SetVersionstampedValue(fdb.Key("last"), append(make([]byte, 10), 0, 0, 0, 0))
+		if _, e := tr.Get(fdb.Key("last")).Get(); errorCode(e) != 1036 {
+			t.Errorf("got error %v reading versionstamped value, expected accessed_unreadable", e)
+		}
+		stamps = append(stamps, tr.GetVersionstamp())
+		tr.Commit().MustGet()
+	}
+
+	tr, _ := db.CreateTransaction()
+	kvs := tr.GetRange(log, fdb.RangeOptions{}).GetSliceOrPanic()
+	if len(kvs) != 6 {
+		t.Fatalf("got %d log entries, expected 6", len(kvs))
+	}
+	for i, kv := range kvs {
+		tup, e := log.Unpack(kv.Key)
+		if e != nil {
+			t.Fatal(e)
+		}
+		vs := tup[0].(tuple.Versionstamp)
+		if !bytes.Equal(vs.TransactionVersion[:], stamps[i/2].MustGet()) || int(vs.UserVersion) != i%2 {
+			t.Errorf("entry %d has %v, expected versionstamp %x and user version %d", i, vs, stamps[i/2].MustGet(), i%2)
+		}
+		if string(kv.Value) != fmt.Sprintf("%d.%d", i/2, i%2) {
+			t.Errorf("entry %d has value %q", i, kv.Value)
+		}
+	}
+	if v := tr.Get(fdb.Key("last")).MustGet(); !bytes.Equal(v, stamps[2].MustGet()) {
+		t.Errorf("got versionstamped value %x, expected %x", v, stamps[2].MustGet())
+	}
+
+	// A read-only transaction has no versionstamp
+	tr, _ = db.CreateTransaction()
+	vs := tr.GetVersionstamp()
+	tr.Commit().MustGet()
+	if _, e := vs.Get(); errorCode(e) != 2021 {
+		t.Errorf("got error %v for read-only transaction, expected no_commit_version", e)
+	}
+}
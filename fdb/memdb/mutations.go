@@ -0,0 +1,205 @@
+// FoundationDB Go In-Memory Database
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+
+package memdb
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"bytes"
+	"encoding/binary"
+)
+
+// A mutation is an atomic operation whose result depends on a value that has
+// not yet been read by the transaction that issued it.
+type mutation struct {
+	code int
+	param []byte
+}
+
+func applyMutations(value []byte, present bool, ms []mutation) ([]byte, bool) {
+	for _, m := range ms {
+		value, present = applyMutation(value, present, m)
+	}
+	return value, present
+}
+
+// applyMutation returns the result of applying m to an existing value. For the
+// arithmetic and bitwise mutations, the existing value is first zero-extended
+// or truncated to the length of the operand.
+func applyMutation(value []byte, present bool, m mutation) ([]byte, bool) {
+	p := m.param
+
+	switch m.code {
+	case 6, 13, 16, 17: // BitAnd, Min, ByteMin, ByteMax
+		// As of API version 510, these store the operand if there is no
+		// existing value, rather than treating it as zero.
+		if !present && (m.code > 13 || !apiVersionBefore(510)) {
+			return copyBytes(p), true
+		}
+	}
+
+	switch m.code {
+	case 9: // AppendIfFits
+		if len(value)+len(p) > maxValueSize {
+			return value, present
+		}
+		v := make([]byte, 0, len(value)+len(p))
+		return append(append(v, value...), p...), true
+	case 16: // ByteMin
+		if bytes.Compare(p, value) < 0 {
+			return copyBytes(p), true
+		}
+		return value, present
+	case 17: // ByteMax
+		if bytes.Compare(p, value) > 0 {
+			return copyBytes(p), true
+		}
+		return value, present
+	case 20: // CompareAndClear
+		if present && bytes.Equal(p, value) {
+			return nil, false
+		}
+		return value, present
+	}
+
+	v := make([]byte, len(p))
+	copy(v, value)
+
+	switch m.code {
+	case 2: // Add
+		var carry int
+		for i := range v {
+			sum := int(v[i]) + int(p[i]) + carry
+			v[i] = byte(sum)
+			carry = sum >> 8
+		}
+	case 6: // BitAnd
+		for i := range v {
+			v[i] &= p[i]
+		}
+	case 7: // BitOr
+		for i := range v {
+			v[i] |= p[i]
+		}
+	case 8: // BitXor
+		for i := range v {
+			v[i] ^= p[i]
+		}
+	case 12: // Max
+		if compareLittleEndian(p, v) > 0 {
+			copy(v, p)
+		}
+	case 13: // Min
+		if compareLittleEndian(p, v) < 0 {
+			copy(v, p)
+		}
+	default:
+		return value, present
+	}
+
+	return v, true
+}
+
+// compareLittleEndian compares two equal-length little-endian unsigned
+// integers.
+func compareLittleEndian(a, b []byte) int {
+	for i := len(a) - 1; i >= 0; i-- {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+func isMutation(code int) bool {
+	switch code {
+	case 2, 6, 7, 8, 9, 12, 13, 16, 17, 20:
+		return true
+	}
+	return false
+}
+
+// A stamped byte string has the versionstamp of the committing transaction
+// written at pos.
+type stamped struct {
+	b []byte
+	pos int
+}
+
+// parseStamped decodes the parameter of a versionstamp operation, which is
+// followed by the little-endian position of the versionstamp (4 bytes, or 2
+// bytes for keys prior to API version 520). Prior to API version 520,
+// versionstamped values have no position, and are stamped at the beginning.
+func parseStamped(param []byte, value bool) (stamped, bool) {
+	var st stamped
+
+	legacy := apiVersionBefore(520)
+
+	switch {
+	case legacy && value:
+		st.b = param
+	case legacy:
+		if len(param) < 2 {
+			return st, false
+		}
+		st.b = param[:len(param)-2]
+		st.pos = int(binary.LittleEndian.Uint16(param[len(param)-2:]))
+	default:
+		if len(param) < 4 {
+			return st, false
+		}
+		st.b = param[:len(param)-4]
+		st.pos = int(binary.LittleEndian.Uint32(param[len(param)-4:]))
+	}
+
+	if st.pos+10 > len(st.b) {
+		return st, false
+	}
+
+	st.b = copyBytes(st.b)
+	return st, true
+}
+
+func (st stamped) apply(vs []byte) []byte {
+	b := copyBytes(st.b)
+	copy(b[st.pos:], vs)
+	return b
+}
+
+// versionstamp returns the 10-byte versionstamp of the transaction committed
+// at version. Each commit has its own version, so the batch order is always 0.
+func versionstamp(version int64) []byte {
+	vs := make([]byte, 10)
+	binary.BigEndian.PutUint64(vs, uint64(version))
+	return vs
+}
+
+// apiVersionBefore reports whether the selected API version is earlier than
+// version. If no API version has been selected, the most recent behavior
+// applies.
+func apiVersionBefore(version int) bool {
+	v, e := fdb.GetAPIVersion()
+	return e == nil && v < version
+}
@@ -0,0 +1,896 @@
+// FoundationDB Go In-Memory Database
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+
+package memdb
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	errTransactionTooOld = fdb.Error{Code: 1007}
+	errFutureVersion = fdb.Error{Code: 1009}
+	errNotCommitted = fdb.Error{Code: 1020}
+	errCommitUnknownResult = fdb.Error{Code: 1021}
+	errTransactionCancelled = fdb.Error{Code: 1025}
+	errTransactionTimedOut = fdb.Error{Code: 1031}
+	errTooManyWatches = fdb.Error{Code: 1032}
+	errWatchesDisabled = fdb.Error{Code: 1034}
+	errAccessedUnreadable = fdb.Error{Code: 1036}
+
+	errClientInvalidOperation = fdb.Error{Code: 2000}
+
+	errKeyOutsideLegalRange = fdb.Error{Code: 2004}
+	errInvertedRange = fdb.Error{Code: 2005}
+	errInvalidOptionValue = fdb.Error{Code: 2006}
+	errUsedDuringCommit = fdb.Error{Code: 2017}
+	errInvalidMutationType = fdb.Error{Code: 2018}
+	errNoCommitVersion = fdb.Error{Code: 2021}
+
+	errTransactionTooLarge = fdb.Error{Code: 2101}
+	errKeyTooLarge = fdb.Error{Code: 2102}
+	errValueTooLarge = fdb.Error{Code: 2103}
+)
+
+const (
+	maxKeySize = 10000
+	maxValueSize = 100000
+	maxTransactionSize = 10000000
+
+	initialBackoff = time.Millisecond
+	defaultMaxRetryDelay = time.Second
+)
+
+type txState int
+
+const (
+	txActive txState = iota
+	txCommitted
+	txCancelled
+)
+
+// A write is the pending state of a single key written by a transaction. If
+// known is true, the value of the key is fully determined by the transaction;
+// otherwise ops must be applied to the value read from the database (or, if
+// stamp is set, to the versionstamped value) at commit time.
+type write struct {
+	known bool
+	value []byte
+	present bool
+	stamp *stamped
+	ops []mutation
+}
+
+// A stampedKey is a key written with SetVersionstampedKey, which is not known
+// until commit time.
+type stampedKey struct {
+	key stamped
+	value []byte
+	conflict bool
+}
+
+type transaction struct {
+	s *store
+
+	mu sync.Mutex
+	state txState
+
+	readVersion int64
+	hasReadVersion bool
+	committedVersion int64
+
+	writes map[string]*write
+	writeKeys []string
+	clears []keyRange
+	reads []keyRange
+	writeConflicts []keyRange
+	watches []*watch
+	stampedKeys []stampedKey
+	versionstamp *futureKey
+	size int
+
+	// The first error caused by a mutation, reported by Commit.
+	err error
+
+	// Options
+	nextWriteNoConflict bool
+	rywDisabled bool
+	snapshotRywDisabled bool
+	accessSystemKeys bool
+	readSystemKeys bool
+	deadline time.Time
+	retryLimit int64
+	maxRetryDelay time.Duration
+
+	// Retry state, preserved by OnError
+	retries int64
+	backoff time.Duration
+}
+
+func newTransaction(s *store) *transaction {
+	t := &transaction{s: s}
+	t.reset(true)
+	return t
+}
+
+// reset returns the transaction to its initial state. Unless full is true,
+// the retry state used by OnError (and, as of API version 610, the options
+// that govern retries) is preserved.
+func (t *transaction) reset(full bool) {
+	t.abandon()
+
+	t.state = txActive
+	t.readVersion = 0
+	t.hasReadVersion = false
+	t.committedVersion = -1
+	t.writes = make(map[string]*write)
+	t.writeKeys = nil
+	t.clears = nil
+	t.reads = nil
+	t.writeConflicts = nil
+	t.watches = nil
+	t.stampedKeys = nil
+	t.versionstamp = nil
+	t.size = 0
+	t.err = nil
+
+	t.nextWriteNoConflict = false
+	t.rywDisabled = false
+	t.snapshotRywDisabled = apiVersionBefore(300)
+	t.accessSystemKeys = false
+	t.readSystemKeys = false
+
+	persistent := !full && !apiVersionBefore(610)
+	if !persistent {
+		t.deadline = time.Time{}
+		t.retryLimit = -1
+		t.maxRetryDelay = defaultMaxRetryDelay
+	}
+
+	if full {
+		t.retries = 0
+		t.backoff = initialBackoff
+	}
+
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	for code, param := range t.s.txDefaults {
+		if persistent && code >= 500 {
+			continue
+		}
+		t.setOption(code, param)
+	}
+}
+
+// abandon fails the futures that depend on the transaction committing.
+func (t *transaction) abandon() {
+	for _, w := range t.watches {
+		w.f.set(errTransactionCancelled)
+	}
+	if t.versionstamp != nil {
+		t.versionstamp.set(errTransactionCancelled)
+	}
+}
+
+// check returns the error, if any, that prevents the transaction from being
+// used.
+func (t *transaction) check() error {
+	switch t.state {
+	case txCancelled:
+		return errTransactionCancelled
+	case txCommitted:
+		return errUsedDuringCommit
+	}
+	if !t.deadline.IsZero() && !time.Now().Before(t.deadline) {
+		return errTransactionTimedOut
+	}
+	return nil
+}
+
+func (t *transaction) fail(e error) {
+	if t.err == nil {
+		t.err = e
+	}
+}
+
+// keyLimit returns the first key that the transaction is not allowed to read
+// or write.
+func (t *transaction) keyLimit(read bool) string {
+	if t.accessSystemKeys || (read && t.readSystemKeys) {
+		return "\xff\xff"
+	}
+	return "\xff"
+}
+
+// writable reports whether a mutation of key may be applied to the
+// transaction, recording an error to be returned by Commit if not.
+func (t *transaction) writable(key string) bool {
+	if t.check() != nil {
+		// The error will be reported by Commit
+		return false
+	}
+	if len(key) > maxKeySize {
+		t.fail(errKeyTooLarge)
+		return false
+	}
+	if key >= t.keyLimit(false) {
+		t.fail(errKeyOutsideLegalRange)
+		return false
+	}
+	return true
+}
+
+func (t *transaction) acquireReadVersion() (int64, error) {
+	if !t.hasReadVersion {
+		t.readVersion = t.s.version
+		t.hasReadVersion = true
+	}
+	if t.readVersion < t.s.oldest {
+		return 0, errTransactionTooOld
+	}
+	if t.readVersion > t.s.version {
+		return 0, errFutureVersion
+	}
+	return t.readVersion, nil
+}
+
+func (t *transaction) view(snapshot bool) (view, error) {
+	if e := t.check(); e != nil {
+		return view{}, e
+	}
+	rv, e := t.acquireReadVersion()
+	if e != nil {
+		return view{}, e
+	}
+	local := !t.rywDisabled && !(snapshot && t.snapshotRywDisabled)
+	return view{t, rv, local, t.keyLimit(true)}, nil
+}
+
+func (t *transaction) addRead(begin, end string) {
+	if begin < end {
+		t.reads = append(t.reads, keyRange{begin, end})
+	}
+}
+
+func (t *transaction) addWriteConflict(begin, end string) {
+	if t.nextWriteNoConflict {
+		t.nextWriteNoConflict = false
+		return
+	}
+	if begin < end {
+		t.writeConflicts = append(t.writeConflicts, keyRange{begin, end})
+	}
+}
+
+func (t *transaction) setWrite(key string, w *write) {
+	if _, ok := t.writes[key]; !ok {
+		i := sort.SearchStrings(t.writeKeys, key)
+		t.writeKeys = append(t.writeKeys, "")
+		copy(t.writeKeys[i+1:], t.writeKeys[i:])
+		t.writeKeys[i] = key
+	}
+	t.writes[key] = w
+}
+
+// unreadable reports whether key has been written with SetVersionstampedValue,
+// and so cannot be read until the transaction has committed.
+func (t *transaction) unreadable(key string) bool {
+	w, ok := t.writes[key]
+	return ok && w.stamp != nil
+}
+
+func (t *transaction) cleared(key string) bool {
+	for _, r := range t.clears {
+		if r.contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *transaction) Get(key fdb.Key, snapshot bool) fdb.FutureByteSlice {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	v, e := t.view(snapshot)
+	if e != nil {
+		return &futureByteSlice{future: readyFuture(e)}
+	}
+
+	k := string(key)
+	if k >= v.end {
+		return &futureByteSlice{future: readyFuture(errKeyOutsideLegalRange)}
+	}
+
+	if v.local && t.unreadable(k) {
+		return &futureByteSlice{future: readyFuture(errAccessedUnreadable)}
+	}
+
+	value, present, underlying := v.value(k)
+	if underlying && !snapshot {
+		t.addRead(k, keyAfter(k))
+	}
+	if !present {
+		return &futureByteSlice{future: readyFuture(nil)}
+	}
+	return &futureByteSlice{future: readyFuture(nil), v: copyBytes(value)}
+}
+
+func (t *transaction) GetKey(sel fdb.KeySelector, snapshot bool) fdb.FutureKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	v, e := t.view(snapshot)
+	if e != nil {
+		return &futureKey{future: readyFuture(e)}
+	}
+
+	k := v.resolve(sel)
+	if !snapshot {
+		t.addSelectorRead(string(sel.Key.FDBKey()), k)
+	}
+	return &futureKey{future: readyFuture(nil), k: fdb.Key(k)}
+}
+
+// addSelectorRead adds a read conflict range covering every key between the
+// key of a selector and the key it resolved to.
+func (t *transaction) addSelectorRead(a, b string) {
+	if a > b {
+		a, b = b, a
+	}
+	t.addRead(a, keyAfter(b))
+}
+
+// boundary resolves a key selector used as one end of a range read. Selectors
+// of the form FirstGreaterOrEqual need not be resolved, since the range
+// contains the same keys either way.
+func (t *transaction) boundary(v view, sel fdb.KeySelector, snapshot bool) string {
+	key := string(sel.Key.FDBKey())
+	if !sel.OrEqual && sel.Offset == 1 {
+		if key > v.end {
+			return v.end
+		}
+		return key
+	}
+
+	k := v.resolve(sel)
+	if !snapshot {
+		t.addSelectorRead(key, k)
+	}
+	return k
+}
+
+func (t *transaction) GetRange(begin, end fdb.KeySelector, options fdb.RangeOptions, snapshot bool, iteration int) fdb.FutureKeyValueArray {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	v, e := t.view(snapshot)
+	if e != nil {
+		return &futureKeyValueArray{future: readyFuture(e)}
+	}
+
+	b := t.boundary(v, begin, snapshot)
+	en := t.boundary(v, end, snapshot)
+
+	var kvs []fdb.KeyValue
+	var unreadable bool
+	if b < en {
+		add := func(k string) bool {
+			if v.local && t.unreadable(k) {
+				unreadable = true
+				return false
+			}
+			value, _, _ := v.value(k)
+			kvs = append(kvs, fdb.KeyValue{Key: fdb.Key(k), Value: copyBytes(value)})
+			return options.Limit <= 0 || len(kvs) < options.Limit
+		}
+
+		if options.Reverse {
+			for k, ok := v.prev(en, false); ok && k >= b; k, ok = v.prev(k, false) {
+				if !add(k) {
+					break
+				}
+			}
+		} else {
+			for k, ok := v.next(b, true); ok && k < en; k, ok = v.next(k, false) {
+				if !add(k) {
+					break
+				}
+			}
+		}
+
+		if unreadable {
+			return &futureKeyValueArray{future: readyFuture(errAccessedUnreadable)}
+		}
+
+		if !snapshot {
+			// When the limit was reached, only the keys actually returned
+			// (and the gaps between them) have been read
+			switch {
+			case options.Limit <= 0 || len(kvs) < options.Limit:
+				t.addRead(b, en)
+			case options.Reverse:
+				t.addRead(string(kvs[len(kvs)-1].Key), en)
+			default:
+				t.addRead(b, keyAfter(string(kvs[len(kvs)-1].Key)))
+			}
+		}
+	}
+
+	// The whole range is always returned in a single batch
+	return &futureKeyValueArray{future: readyFuture(nil), kvs: kvs, more: false}
+}
+
+func (t *transaction) GetReadVersion() fdb.FutureInt64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	if e := t.check(); e != nil {
+		return &futureInt64{future: readyFuture(e)}
+	}
+	rv, e := t.acquireReadVersion()
+	if e != nil {
+		return &futureInt64{future: readyFuture(e)}
+	}
+	return &futureInt64{future: readyFuture(nil), v: rv}
+}
+
+func (t *transaction) SetReadVersion(version int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.readVersion = version
+	t.hasReadVersion = true
+}
+
+func (t *transaction) GetCommittedVersion() (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.committedVersion, nil
+}
+
+func (t *transaction) Set(key fdb.Key, value []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := string(key)
+	if !t.writable(k) {
+		return
+	}
+	if len(value) > maxValueSize {
+		t.fail(errValueTooLarge)
+		return
+	}
+
+	t.setWrite(k, &write{known: true, value: copyBytes(value), present: true})
+	t.addWriteConflict(k, keyAfter(k))
+	t.size += len(k) + len(value)
+}
+
+func (t *transaction) Clear(key fdb.Key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := string(key)
+	if !t.writable(k) {
+		return
+	}
+
+	t.setWrite(k, &write{known: true})
+	t.addWriteConflict(k, keyAfter(k))
+	t.size += len(k)
+}
+
+func (t *transaction) ClearRange(begin, end fdb.Key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.check() != nil {
+		return
+	}
+
+	b, e := string(begin), string(end)
+	if b > e {
+		t.fail(errInvertedRange)
+		return
+	}
+	if limit := t.keyLimit(false); b >= limit || e > limit {
+		t.fail(errKeyOutsideLegalRange)
+		return
+	}
+	if b == e {
+		return
+	}
+
+	i := sort.SearchStrings(t.writeKeys, b)
+	j := sort.SearchStrings(t.writeKeys, e)
+	for _, k := range t.writeKeys[i:j] {
+		delete(t.writes, k)
+	}
+	t.writeKeys = append(t.writeKeys[:i], t.writeKeys[j:]...)
+
+	t.clears = append(t.clears, keyRange{b, e})
+	t.addWriteConflict(b, e)
+	t.size += len(b) + len(e)
+}
+
+func (t *transaction) AtomicOp(key fdb.Key, param []byte, code int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := string(key)
+	if !t.writable(k) {
+		return
+	}
+	if len(param) > maxValueSize {
+		t.fail(errValueTooLarge)
+		return
+	}
+
+	switch code {
+	case 14: // SetVersionstampedKey
+		t.setVersionstampedKey(k, param)
+		return
+	case 15: // SetVersionstampedValue
+		t.setVersionstampedValue(k, param)
+		return
+	}
+
+	if !isMutation(code) {
+		t.fail(errInvalidMutationType)
+		return
+	}
+
+	m := mutation{code, copyBytes(param)}
+	w, ok := t.writes[k]
+	switch {
+	case ok && w.known:
+		w.value, w.present = applyMutation(w.value, w.present, m)
+	case ok:
+		w.ops = append(w.ops, m)
+	case t.cleared(k):
+		value, present := applyMutation(nil, false, m)
+		t.setWrite(k, &write{known: true, value: value, present: present})
+	default:
+		t.setWrite(k, &write{ops: []mutation{m}})
+	}
+
+	t.addWriteConflict(k, keyAfter(k))
+	t.size += len(k) + len(param)
+}
+
+func (t *transaction) setVersionstampedKey(key string, param []byte) {
+	st, ok := parseStamped([]byte(key), false)
+	if !ok {
+		t.fail(errClientInvalidOperation)
+		return
+	}
+
+	conflict := !t.nextWriteNoConflict
+	t.nextWriteNoConflict = false
+
+	t.stampedKeys = append(t.stampedKeys, stampedKey{st, copyBytes(param), conflict})
+	t.size += len(key) + len(param)
+}
+
+func (t *transaction) setVersionstampedValue(key string, param []byte) {
+	st, ok := parseStamped(param, true)
+	if !ok {
+		t.fail(errClientInvalidOperation)
+		return
+	}
+
+	t.setWrite(key, &write{stamp: &st})
+	t.addWriteConflict(key, keyAfter(key))
+	t.size += len(key) + len(param)
+}
+
+func (t *transaction) AddReadConflictRange(begin, end fdb.Key) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e := t.check(); e != nil {
+		return e
+	}
+	if string(begin) > string(end) {
+		return errInvertedRange
+	}
+	t.addRead(string(begin), string(end))
+	return nil
+}
+
+func (t *transaction) AddWriteConflictRange(begin, end fdb.Key) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e := t.check(); e != nil {
+		return e
+	}
+	if string(begin) > string(end) {
+		return errInvertedRange
+	}
+	if string(begin) < string(end) {
+		t.writeConflicts = append(t.writeConflicts, keyRange{string(begin), string(end)})
+	}
+	return nil
+}
+
+func int64Param(param []byte) (int64, error) {
+	if len(param) != 8 {
+		return 0, errInvalidOptionValue
+	}
+	return int64(binary.LittleEndian.Uint64(param)), nil
+}
+
+func (t *transaction) SetOption(code int, param []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.setOption(code, param)
+}
+
+func (t *transaction) setOption(code int, param []byte) error {
+	switch code {
+	case 30: // NextWriteNoWriteConflictRange
+		t.nextWriteNoConflict = true
+	case 51: // ReadYourWritesDisable
+		t.rywDisabled = true
+	case 301: // AccessSystemKeys
+		t.accessSystemKeys = true
+	case 302: // ReadSystemKeys
+		t.readSystemKeys = true
+	case 500: // Timeout
+		ms, e := int64Param(param)
+		if e != nil {
+			return e
+		}
+		if ms == 0 {
+			t.deadline = time.Time{}
+		} else {
+			t.deadline = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+	case 501: // RetryLimit
+		n, e := int64Param(param)
+		if e != nil {
+			return e
+		}
+		t.retryLimit = n
+	case 502: // MaxRetryDelay
+		ms, e := int64Param(param)
+		if e != nil {
+			return e
+		}
+		t.maxRetryDelay = time.Duration(ms) * time.Millisecond
+	case 600: // SnapshotRywEnable
+		t.snapshotRywDisabled = false
+	case 601: // SnapshotRywDisable
+		t.snapshotRywDisabled = true
+	}
+	return nil
+}
+
+func (t *transaction) Watch(key fdb.Key) fdb.FutureNil {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	if t.rywDisabled {
+		return futureNil{readyFuture(errWatchesDisabled)}
+	}
+
+	v, e := t.view(false)
+	if e != nil {
+		return futureNil{readyFuture(e)}
+	}
+
+	k := string(key)
+	if k >= v.end {
+		return futureNil{readyFuture(errKeyOutsideLegalRange)}
+	}
+
+	value, present, _ := v.value(k)
+	w := &watch{key: k, value: copyBytes(value), present: present, f: newFuture()}
+	w.f.onCancel = func() { t.s.removeWatch(w) }
+	t.watches = append(t.watches, w)
+
+	return futureNil{w.f}
+}
+
+func (t *transaction) Commit() fdb.FutureNil {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e := t.check(); e != nil {
+		return futureNil{readyFuture(e)}
+	}
+
+	t.s.mu.Lock()
+	e := t.commit()
+	t.s.mu.Unlock()
+
+	t.state = txCommitted
+	if e != nil {
+		for _, w := range t.watches {
+			w.f.set(e)
+		}
+	}
+	t.watches = nil
+
+	if vs := t.versionstamp; vs != nil {
+		switch {
+		case e != nil:
+			vs.set(e)
+		case t.committedVersion < 0:
+			vs.set(errNoCommitVersion)
+		default:
+			vs.k = fdb.Key(versionstamp(t.committedVersion))
+			vs.set(nil)
+		}
+	}
+
+	return futureNil{readyFuture(e)}
+}
+
+// commit applies the transaction to the store, which must be locked.
+func (t *transaction) commit() error {
+	if t.err != nil {
+		return t.err
+	}
+	if t.size > maxTransactionSize {
+		return errTransactionTooLarge
+	}
+
+	if len(t.writes) > 0 || len(t.clears) > 0 || len(t.writeConflicts) > 0 || len(t.stampedKeys) > 0 {
+		if t.hasReadVersion {
+			if e := t.s.checkConflicts(t.readVersion, t.reads); e != nil {
+				return e
+			}
+		}
+
+		t.s.version++
+		version := t.s.version
+		vs := versionstamp(version)
+
+		for _, r := range t.clears {
+			t.s.clearRange(r, version)
+		}
+		for _, k := range t.writeKeys {
+			w := t.writes[k]
+			switch {
+			case w.known:
+				t.s.write(k, version, w.value, w.present)
+			case w.stamp != nil:
+				value, present := applyMutations(w.stamp.apply(vs), true, w.ops)
+				t.s.write(k, version, value, present)
+			default:
+				value, present := t.s.valueAt(k, version)
+				value, present = applyMutations(value, present, w.ops)
+				t.s.write(k, version, value, present)
+			}
+		}
+
+		writes := t.writeConflicts
+		for _, sk := range t.stampedKeys {
+			k := string(sk.key.apply(vs))
+			t.s.write(k, version, sk.value, true)
+			if sk.conflict {
+				writes = append(writes, keyRange{k, keyAfter(k)})
+			}
+		}
+
+		t.s.record(version, writes)
+		t.committedVersion = version
+	}
+
+	for _, w := range t.watches {
+		t.s.addWatch(w)
+	}
+
+	return nil
+}
+
+func retryable(code int) bool {
+	switch code {
+	case errTransactionTooOld.Code, errFutureVersion.Code, errNotCommitted.Code, errCommitUnknownResult.Code:
+		return true
+	}
+	return false
+}
+
+func (t *transaction) GetVersionstamp() fdb.FutureKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e := t.check(); e != nil {
+		return &futureKey{future: readyFuture(e)}
+	}
+	if t.versionstamp == nil {
+		t.versionstamp = &futureKey{future: newFuture()}
+	}
+	return t.versionstamp
+}
+
+func (t *transaction) OnError(e fdb.Error) fdb.FutureNil {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !retryable(e.Code) {
+		return futureNil{readyFuture(e)}
+	}
+	if t.retryLimit >= 0 && t.retries >= t.retryLimit {
+		return futureNil{readyFuture(e)}
+	}
+
+	delay := t.backoff
+	if delay > t.maxRetryDelay {
+		delay = t.maxRetryDelay
+	}
+	t.backoff *= 2
+	t.retries++
+	t.reset(false)
+
+	f := newFuture()
+	time.AfterFunc(delay, func() { f.set(nil) })
+	return futureNil{f}
+}
+
+func (t *transaction) Cancel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state == txCommitted {
+		return
+	}
+	t.abandon()
+	t.watches = nil
+	t.versionstamp = nil
+	t.state = txCancelled
+}
+
+func (t *transaction) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reset(true)
+}
+
+func (t *transaction) GetAddressesForKey(key fdb.Key) fdb.FutureStringSlice {
+	return &futureStringSlice{future: readyFuture(nil), v: []string{}}
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,166 @@
+// FoundationDB Go In-Memory Database
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+
+package memdb
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"sort"
+)
+
+// A view is the database as seen by a transaction at its read version: the
+// committed contents of the store, overlaid (unless local is false) with the
+// transaction's own uncommitted writes. Keys at or after end are not visible.
+//
+// Both the transaction and the store must be locked while a view is in use.
+type view struct {
+	t *transaction
+	version int64
+	local bool
+	end string
+}
+
+// value returns the value of key, whether it is present, and whether
+// determining it required reading from the store.
+func (v view) value(key string) ([]byte, bool, bool) {
+	t := v.t
+	if v.local {
+		if w, ok := t.writes[key]; ok {
+			if w.known {
+				return w.value, w.present, false
+			}
+			if w.stamp != nil {
+				// Present, but unreadable until committed
+				return nil, true, false
+			}
+			value, present := t.s.valueAt(key, v.version)
+			value, present = applyMutations(value, present, w.ops)
+			return value, present, true
+		}
+		if t.cleared(key) {
+			return nil, false, false
+		}
+	}
+	value, present := t.s.valueAt(key, v.version)
+	return value, present, true
+}
+
+func (v view) present(key string) bool {
+	_, present, _ := v.value(key)
+	return present
+}
+
+// next returns the first visible key after (or, if orEqual, equal to) key.
+func (v view) next(key string, orEqual bool) (string, bool) {
+	for {
+		c, ok := after(v.t.s.keys, key, orEqual)
+		if v.local {
+			if lc, lok := after(v.t.writeKeys, key, orEqual); lok && (!ok || lc < c) {
+				c, ok = lc, true
+			}
+		}
+		if !ok || c >= v.end {
+			return "", false
+		}
+		if v.present(c) {
+			return c, true
+		}
+		key, orEqual = c, false
+	}
+}
+
+// prev returns the last visible key before (or, if orEqual, equal to) key.
+func (v view) prev(key string, orEqual bool) (string, bool) {
+	if key >= v.end {
+		key, orEqual = v.end, false
+	}
+	for {
+		c, ok := before(v.t.s.keys, key, orEqual)
+		if v.local {
+			if lc, lok := before(v.t.writeKeys, key, orEqual); lok && (!ok || lc > c) {
+				c, ok = lc, true
+			}
+		}
+		if !ok {
+			return "", false
+		}
+		if v.present(c) {
+			return c, true
+		}
+		key, orEqual = c, false
+	}
+}
+
+// resolve returns the key referenced by a key selector, clamped to the
+// beginning of the database and to end.
+func (v view) resolve(sel fdb.KeySelector) string {
+	cur, ok := v.prev(string(sel.Key.FDBKey()), sel.OrEqual)
+
+	for offset := sel.Offset; offset > 0; offset-- {
+		var next string
+		var found bool
+		if ok {
+			next, found = v.next(cur, false)
+		} else {
+			next, found = v.next("", true)
+		}
+		if !found {
+			return v.end
+		}
+		cur, ok = next, true
+	}
+
+	for offset := sel.Offset; offset < 0 && ok; offset++ {
+		cur, ok = v.prev(cur, false)
+	}
+
+	if !ok {
+		return ""
+	}
+	return cur
+}
+
+func after(keys []string, key string, orEqual bool) (string, bool) {
+	i := sort.Search(len(keys), func(i int) bool {
+		if orEqual {
+			return keys[i] >= key
+		}
+		return keys[i] > key
+	})
+	if i == len(keys) {
+		return "", false
+	}
+	return keys[i], true
+}
+
+func before(keys []string, key string, orEqual bool) (string, bool) {
+	i := sort.Search(len(keys), func(i int) bool {
+		if orEqual {
+			return keys[i] > key
+		}
+		return keys[i] >= key
+	})
+	if i == 0 {
+		return "", false
+	}
+	return keys[i-1], true
+}
@@ -21,13 +21,8 @@
 
 package fdb
 
-/*
- #define FDB_API_VERSION 200
- #include <foundationdb/fdb_c.h>
-*/
-import "C"
-
 import (
+	"context"
 	"fmt"
 )
 
@@ -124,7 +119,7 @@ type RangeResult struct {
 	sr SelectorRange
 	options RangeOptions
 	snapshot bool
-	f *futureKeyValueArray
+	f FutureKeyValueArray
 }
 
 // GetSliceWithError returns a slice of KeyValue objects satisfying the range
@@ -193,7 +188,7 @@ func (rr RangeResult) Iterator() *RangeIterator {
 // a transactional function passed to the Transact method of a Transactor.
 type RangeIterator struct {
 	t *transaction
-	f *futureKeyValueArray
+	f FutureKeyValueArray
 	sr SelectorRange
 	options RangeOptions
 	iteration int
@@ -229,6 +224,22 @@ func (ri *RangeIterator) Advance() bool {
 	return false
 }
 
+// AdvanceContext is like Advance, but stops waiting for the next batch of
+// key-value pairs once the provided context is done. In that case the pending
+// read is cancelled, AdvanceContext returns true and the following call to Get
+// returns the context's error.
+func (ri *RangeIterator) AdvanceContext(ctx context.Context) bool {
+	if !ri.done && ri.f != nil {
+		if e := ri.f.BlockUntilReadyContext(ctx); e != nil {
+			ri.err = e
+			ri.f = nil
+			return true
+		}
+	}
+
+	return ri.Advance()
+}
+
 func (ri *RangeIterator) fetchNextBatch() {
 	if !ri.more || ri.index == ri.options.Limit {
 		ri.done = true
@@ -248,8 +259,7 @@ func (ri *RangeIterator) fetchNextBatch() {
 
 	ri.iteration += 1
 
-	f := ri.t.doGetRange(ri.sr, ri.options, ri.snapshot, ri.iteration)
-	ri.f = &f
+	ri.f = ri.t.doGetRange(ri.sr, ri.options, ri.snapshot, ri.iteration)
 }
 
 // Get returns the next KeyValue in a range read, or an error if one of the
@@ -310,7 +320,7 @@ func PrefixRange(prefix []byte) (KeyRange, error) {
 	copy(begin, prefix)
 	end, e := strinc(begin)
 	if e != nil {
-		return KeyRange{}, nil
+		return KeyRange{}, e
 	}
 	return KeyRange{Key(begin), Key(end)}, nil
 }
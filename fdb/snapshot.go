@@ -60,12 +60,12 @@ func (s Snapshot) Snapshot() Snapshot {
 
 // Get is equivalent to (Transaction).Get, performed as a snapshot read.
 func (s Snapshot) Get(key KeyConvertible) FutureByteSlice {
-	return s.get(key.FDBKey(), 1)
+	return s.get(key.FDBKey(), true)
 }
 
 // GetKey is equivalent to (Transaction).GetKey, performed as a snapshot read.
 func (s Snapshot) GetKey(sel Selectable) FutureKey {
-	return s.getKey(sel.FDBKeySelector(), 1)
+	return s.getKey(sel.FDBKeySelector(), true)
 }
 
 // GetRange is equivalent to (Transaction).GetRange, performed as a snapshot
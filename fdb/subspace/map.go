@@ -0,0 +1,410 @@
+// FoundationDB Go Subspace Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package subspace
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// A KeyCodec converts the keys of a Map to and from the bytes that follow the
+// prefix of its Subspace. For a Map to be read in the order of its keys, the
+// encodings must sort in the same order as the keys themselves, as do those of
+// the codecs provided by this package (which pack keys as tuples).
+type KeyCodec[K any] interface {
+	EncodeKey(k K) ([]byte, error)
+	DecodeKey(b []byte) (K, error)
+}
+
+// A ValueCodec converts the values of a Map to and from the bytes stored in
+// the database.
+type ValueCodec[V any] interface {
+	EncodeValue(v V) ([]byte, error)
+	DecodeValue(b []byte) (V, error)
+}
+
+type tupleCodec struct{}
+
+func (tupleCodec) EncodeKey(k tuple.Tuple) ([]byte, error) { return k.PackWithError() }
+func (tupleCodec) DecodeKey(b []byte) (tuple.Tuple, error) { return tuple.Unpack(b) }
+func (tupleCodec) EncodeValue(v tuple.Tuple) ([]byte, error) { return v.PackWithError() }
+func (tupleCodec) DecodeValue(b []byte) (tuple.Tuple, error) { return tuple.Unpack(b) }
+
+// TupleKeys returns a codec that packs keys (or values) that are tuples.
+func TupleKeys() interface {
+	KeyCodec[tuple.Tuple]
+	ValueCodec[tuple.Tuple]
+} {
+	return tupleCodec{}
+}
+
+type elementCodec[T any] struct{}
+
+func (elementCodec[T]) encode(v T) ([]byte, error) {
+	return tuple.Marshal(struct{ E T }{v})
+}
+
+func (elementCodec[T]) decode(b []byte) (T, error) {
+	var s struct{ E T }
+	e := tuple.Unmarshal(b, &s)
+	return s.E, e
+}
+
+func (c elementCodec[T]) EncodeKey(k T) ([]byte, error) { return c.encode(k) }
+func (c elementCodec[T]) DecodeKey(b []byte) (T, error) { return c.decode(b) }
+func (c elementCodec[T]) EncodeValue(v T) ([]byte, error) { return c.encode(v) }
+func (c elementCodec[T]) DecodeValue(b []byte) (T, error) { return c.decode(b) }
+
+// ElementKeys returns a codec that packs each key (or value) as a tuple of one
+// element. T may be any type that tuple.Marshal accepts as a struct field, such
+// as a string, an integer type or a type with a registered tuple.Codec.
+func ElementKeys[T any]() interface {
+	KeyCodec[T]
+	ValueCodec[T]
+} {
+	return elementCodec[T]{}
+}
+
+type structCodec[T any] struct{}
+
+func (structCodec[T]) EncodeKey(k T) ([]byte, error) { return tuple.Marshal(k) }
+func (structCodec[T]) EncodeValue(v T) ([]byte, error) { return tuple.Marshal(v) }
+
+func (structCodec[T]) DecodeKey(b []byte) (T, error) {
+	var k T
+	e := tuple.Unmarshal(b, &k)
+	return k, e
+}
+
+func (c structCodec[T]) DecodeValue(b []byte) (T, error) {
+	return c.DecodeKey(b)
+}
+
+// StructKeys returns a codec that packs each key (or value), which must be a
+// struct, as a tuple of its fields with tuple.Marshal.
+func StructKeys[T any]() interface {
+	KeyCodec[T]
+	ValueCodec[T]
+} {
+	return structCodec[T]{}
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) EncodeValue(v []byte) ([]byte, error) { return v, nil }
+func (bytesCodec) DecodeValue(b []byte) ([]byte, error) { return b, nil }
+
+// BytesValues returns a codec that stores byte slice values as they are.
+func BytesValues() ValueCodec[[]byte] {
+	return bytesCodec{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) EncodeValue(v T) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec[T]) DecodeValue(b []byte) (T, error) {
+	var v T
+	e := json.Unmarshal(b, &v)
+	return v, e
+}
+
+// JSONValues returns a codec that stores values as JSON.
+func JSONValues[T any]() ValueCodec[T] {
+	return jsonCodec[T]{}
+}
+
+// Map is a collection of key-value pairs, of types K and V, stored in a
+// Subspace. The key of each pair is encoded by a KeyCodec and appended to the
+// prefix of the Subspace, and its value is encoded by a ValueCodec. For
+// example,
+//
+//	users := subspace.NewMap[string, User](subspace.Sub("users"), subspace.ElementKeys[string](), subspace.JSONValues[User]())
+//	e := users.Set(db, "alice", User{Name: "Alice"})
+//
+// Map is a lightweight value that may be copied, and is safe for concurrent
+// use by multiple goroutines. Get, Set, Delete and Count are transactional
+// functions, and may be called with either a Database or a Transaction; Range
+// and All, like GetRange, require a ReadTransaction.
+type Map[K, V any] struct {
+	ss Subspace
+	keys KeyCodec[K]
+	values ValueCodec[V]
+}
+
+// NewMap returns a Map storing its key-value pairs in ss, encoded by the
+// provided codecs.
+func NewMap[K, V any](ss Subspace, keys KeyCodec[K], values ValueCodec[V]) Map[K, V] {
+	return Map[K, V]{ss, keys, values}
+}
+
+// Subspace returns the Subspace in which the Map is stored.
+func (m Map[K, V]) Subspace() Subspace {
+	return m.ss
+}
+
+// Key returns the database key at which the value for k is stored.
+func (m Map[K, V]) Key(k K) (fdb.Key, error) {
+	b, e := m.keys.EncodeKey(k)
+	if e != nil {
+		return nil, fmt.Errorf("cannot encode map key: %w", e)
+	}
+	return fdb.Key(concat(m.ss.Bytes(), b...)), nil
+}
+
+// Get returns the value stored for k, and whether there was one.
+func (m Map[K, V]) Get(rt fdb.ReadTransactor, k K) (V, bool, error) {
+	var v V
+
+	key, e := m.Key(k)
+	if e != nil {
+		return v, false, e
+	}
+
+	b, e := rt.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(key).Get()
+	})
+	if e != nil {
+		return v, false, e
+	}
+	if b.([]byte) == nil {
+		return v, false, nil
+	}
+
+	v, e = m.values.DecodeValue(b.([]byte))
+	if e != nil {
+		return v, false, fmt.Errorf("cannot decode map value: %w", e)
+	}
+	return v, true, nil
+}
+
+// Set stores v as the value for k.
+func (m Map[K, V]) Set(t fdb.Transactor, k K, v V) error {
+	key, e := m.Key(k)
+	if e != nil {
+		return e
+	}
+	b, e := m.values.EncodeValue(v)
+	if e != nil {
+		return fmt.Errorf("cannot encode map value: %w", e)
+	}
+
+	_, e = t.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(key, b)
+		return nil, nil
+	})
+	return e
+}
+
+// Delete removes the value stored for k, if any.
+func (m Map[K, V]) Delete(t fdb.Transactor, k K) error {
+	key, e := m.Key(k)
+	if e != nil {
+		return e
+	}
+
+	_, e = t.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Clear(key)
+		return nil, nil
+	})
+	return e
+}
+
+// Count returns the number of key-value pairs in the Map. Count reads the
+// entire Map, and so is only suitable for small collections.
+func (m Map[K, V]) Count(rt fdb.ReadTransactor) (int, error) {
+	n, e := rt.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		var n int
+		ri := rtr.GetRange(m.keyRange(), fdb.RangeOptions{Mode: fdb.StreamingModeWantAll}).Iterator()
+		for ri.Advance() {
+			if _, e := ri.Get(); e != nil {
+				return nil, e
+			}
+			n++
+		}
+		return n, nil
+	})
+	if e != nil {
+		return 0, e
+	}
+	return n.(int), nil
+}
+
+// All returns the key-value pairs of the Map, in the order of their encoded
+// keys.
+//
+// Like any RangeResult, a MapRangeResult should not be returned from a
+// transactional function.
+func (m Map[K, V]) All(rtr fdb.ReadTransaction, options fdb.RangeOptions) MapRangeResult[K, V] {
+	return MapRangeResult[K, V]{m: m, rr: rtr.GetRange(m.keyRange(), options)}
+}
+
+// keyRange returns the range of keys in the Map. Unlike the range of the
+// Subspace, it includes the prefix itself, at which a key that encodes to no
+// bytes is stored.
+func (m Map[K, V]) keyRange() fdb.KeyRange {
+	return fdb.KeyRange{Begin: fdb.Key(m.ss.Bytes()), End: fdb.Key(concat(m.ss.Bytes(), 0xFF))}
+}
+
+// Range returns the key-value pairs of the Map with keys from begin
+// (inclusive) to end (exclusive), in the order of their encoded keys.
+func (m Map[K, V]) Range(rtr fdb.ReadTransaction, begin, end K, options fdb.RangeOptions) MapRangeResult[K, V] {
+	b, e := m.Key(begin)
+	if e != nil {
+		return MapRangeResult[K, V]{err: e}
+	}
+	n, e := m.Key(end)
+	if e != nil {
+		return MapRangeResult[K, V]{err: e}
+	}
+	if bytes.Compare(b, n) > 0 {
+		return MapRangeResult[K, V]{err: errors.New("map range begins after it ends")}
+	}
+
+	return MapRangeResult[K, V]{m: m, rr: rtr.GetRange(fdb.KeyRange{Begin: b, End: n}, options)}
+}
+
+// decode decodes a key-value pair read from the Map.
+func (m Map[K, V]) decode(kv fdb.KeyValue) (k K, v V, e error) {
+	prefix := m.ss.Bytes()
+	if !bytes.HasPrefix(kv.Key, prefix) {
+		e = errors.New("key is not in subspace")
+		return
+	}
+	if k, e = m.keys.DecodeKey(kv.Key[len(prefix):]); e != nil {
+		e = fmt.Errorf("cannot decode map key %q: %w", kv.Key, e)
+		return
+	}
+	if v, e = m.values.DecodeValue(kv.Value); e != nil {
+		e = fmt.Errorf("cannot decode map value at key %q: %w", kv.Key, e)
+	}
+	return
+}
+
+// MapEntry is a key-value pair read from a Map.
+type MapEntry[K, V any] struct {
+	Key K
+	Value V
+}
+
+// MapRangeResult is a handle to the asynchronous result of a range read of a
+// Map, as an fdb.RangeResult is of a range read of the database.
+type MapRangeResult[K, V any] struct {
+	m Map[K, V]
+	rr fdb.RangeResult
+	err error
+}
+
+// GetSliceWithError returns the key-value pairs satisfying the range read
+// that returned this MapRangeResult, or an error if a read did not complete or
+// a pair could not be decoded. The current goroutine will be blocked until all
+// reads have completed.
+func (mr MapRangeResult[K, V]) GetSliceWithError() ([]MapEntry[K, V], error) {
+	if mr.err != nil {
+		return nil, mr.err
+	}
+
+	kvs, e := mr.rr.GetSliceWithError()
+	if e != nil {
+		return nil, e
+	}
+
+	entries := make([]MapEntry[K, V], len(kvs))
+	for i, kv := range kvs {
+		if entries[i].Key, entries[i].Value, e = mr.m.decode(kv); e != nil {
+			return nil, e
+		}
+	}
+	return entries, nil
+}
+
+// GetSliceOrPanic is like GetSliceWithError, but panics rather than returning
+// an error.
+func (mr MapRangeResult[K, V]) GetSliceOrPanic() []MapEntry[K, V] {
+	entries, e := mr.GetSliceWithError()
+	if e != nil {
+		panic(e)
+	}
+	return entries
+}
+
+// Iterator returns a MapIterator over the key-value pairs satisfying the range
+// read that returned this MapRangeResult.
+func (mr MapRangeResult[K, V]) Iterator() *MapIterator[K, V] {
+	mi := &MapIterator[K, V]{m: mr.m, err: mr.err}
+	if mr.err == nil {
+		mi.ri = mr.rr.Iterator()
+	}
+	return mi
+}
+
+// MapIterator returns the decoded key-value pairs satisfying a range read of a
+// Map. It is constructed with the (MapRangeResult).Iterator method, and is used
+// exactly as an fdb.RangeIterator: Advance must return true before each call
+// to Get or MustGet.
+type MapIterator[K, V any] struct {
+	m Map[K, V]
+	ri *fdb.RangeIterator
+	err error
+	failed bool
+}
+
+// Advance attempts to advance the iterator to the next key-value pair,
+// returning false once the range has been exhausted.
+func (mi *MapIterator[K, V]) Advance() bool {
+	if mi.err != nil {
+		if mi.failed {
+			return false
+		}
+		mi.failed = true
+		return true
+	}
+	return mi.ri.Advance()
+}
+
+// Get returns the next key-value pair, or an error if a read did not complete
+// or the pair could not be decoded.
+func (mi *MapIterator[K, V]) Get() (k K, v V, e error) {
+	if mi.err != nil {
+		e = mi.err
+		return
+	}
+
+	kv, e := mi.ri.Get()
+	if e != nil {
+		return
+	}
+	return mi.m.decode(kv)
+}
+
+// MustGet is like Get, but panics rather than returning an error.
+func (mi *MapIterator[K, V]) MustGet() (K, V) {
+	k, v, e := mi.Get()
+	if e != nil {
+		panic(e)
+	}
+	return k, v
+}
@@ -0,0 +1,311 @@
+// FoundationDB Go Subspace Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package subspace
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"bytes"
+	"context"
+	"errors"
+)
+
+// ErrOutsideSubspace is returned when a key selector used with a
+// ScopedTransaction resolves to a key outside of its Subspace.
+var ErrOutsideSubspace = errors.New("key selector resolved outside of subspace")
+
+// ScopedTransaction restricts a transaction to the keys of a Subspace. Every
+// key passed to a ScopedTransaction is interpreted relative to the prefix of
+// the Subspace, and every key it returns has that prefix removed, so that code
+// given a ScopedTransaction sees the Subspace as if it were an entire database
+// and cannot read or write any other keys.
+//
+// A key selector is resolved against the keys of the underlying database. If
+// it resolves to a key outside the Subspace (for example, because its offset
+// moves it past the first or last key in the Subspace), the read returns
+// ErrOutsideSubspace. Selectors of the form fdb.FirstGreaterOrEqual(k) used as
+// the endpoints of a range read, including those of any fdb.ExactRange, need
+// no resolution and are always accepted.
+//
+// ScopedTransaction is a lightweight value that may be efficiently copied, and
+// is safe for concurrent use by multiple goroutines if the transaction it wraps
+// is.
+type ScopedTransaction struct {
+	tr fdb.WriteTransaction
+	ss Subspace
+}
+
+// Scoped returns a ScopedTransaction that performs its operations in tr,
+// restricted to the keys of ss.
+func Scoped(tr fdb.WriteTransaction, ss Subspace) ScopedTransaction {
+	return ScopedTransaction{tr, ss}
+}
+
+// Subspace returns the Subspace to which the ScopedTransaction is restricted.
+func (st ScopedTransaction) Subspace() Subspace {
+	return st.ss
+}
+
+func (st ScopedTransaction) key(k fdb.KeyConvertible) fdb.Key {
+	return fdb.Key(concat(st.ss.Bytes(), k.FDBKey()...))
+}
+
+// Get returns the value of the key relative to the Subspace, as
+// (fdb.Transaction).Get.
+func (st ScopedTransaction) Get(key fdb.KeyConvertible) fdb.FutureByteSlice {
+	return st.tr.Get(st.key(key))
+}
+
+// GetKey resolves a key selector relative to the Subspace, as
+// (fdb.Transaction).GetKey. The returned key has the prefix of the Subspace
+// removed, and getting it returns ErrOutsideSubspace if the selector resolves
+// to a key outside the Subspace.
+func (st ScopedTransaction) GetKey(sel fdb.Selectable) fdb.FutureKey {
+	ks := sel.FDBKeySelector()
+	ks.Key = st.key(ks.Key)
+	return scopedFutureKey{st.tr.GetKey(ks), st.ss.Bytes()}
+}
+
+// GetRange performs a range read of the keys relative to the Subspace, as
+// (fdb.Transaction).GetRange. The endpoints of the range are resolved as
+// described for ScopedTransaction, and the key-value pairs returned have the
+// prefix of the Subspace removed from their keys.
+func (st ScopedTransaction) GetRange(r fdb.Range, options fdb.RangeOptions) ScopedRangeResult {
+	begin, end := r.FDBRangeKeySelectors()
+	return ScopedRangeResult{
+		tr: st.tr,
+		prefix: st.ss.Bytes(),
+		begin: st.endpoint(begin),
+		end: st.endpoint(end),
+		options: options,
+	}
+}
+
+// endpoint returns the (not yet resolved) endpoint of a range read.
+func (st ScopedTransaction) endpoint(sel fdb.Selectable) scopedEndpoint {
+	ks := sel.FDBKeySelector()
+	if !ks.OrEqual && ks.Offset == 1 {
+		return scopedEndpoint{k: ks.Key.FDBKey()}
+	}
+	return scopedEndpoint{f: st.GetKey(ks)}
+}
+
+// Set sets the value of the key relative to the Subspace, as
+// (fdb.Transaction).Set.
+func (st ScopedTransaction) Set(key fdb.KeyConvertible, value []byte) {
+	st.tr.Set(st.key(key), value)
+}
+
+// Clear removes the key relative to the Subspace, as (fdb.Transaction).Clear.
+func (st ScopedTransaction) Clear(key fdb.KeyConvertible) {
+	st.tr.Clear(st.key(key))
+}
+
+// ClearRange removes the keys in the range relative to the Subspace, as
+// (fdb.Transaction).ClearRange.
+func (st ScopedTransaction) ClearRange(er fdb.ExactRange) {
+	begin, end := er.FDBRangeKeys()
+	st.tr.ClearRange(fdb.KeyRange{Begin: st.key(begin), End: st.key(end)})
+}
+
+// Watch watches the key relative to the Subspace for changes, as
+// (fdb.Transaction).Watch.
+func (st ScopedTransaction) Watch(key fdb.KeyConvertible) fdb.FutureNil {
+	return st.tr.Watch(st.key(key))
+}
+
+// scopedFutureKey removes the prefix of a Subspace from the key returned by a
+// FutureKey, failing if the key is outside the Subspace.
+type scopedFutureKey struct {
+	fdb.FutureKey
+	prefix []byte
+}
+
+func (f scopedFutureKey) strip(k fdb.Key, e error) (fdb.Key, error) {
+	if e != nil {
+		return nil, e
+	}
+	if !bytes.HasPrefix(k, f.prefix) {
+		return nil, ErrOutsideSubspace
+	}
+	return k[len(f.prefix):], nil
+}
+
+func (f scopedFutureKey) Get() (fdb.Key, error) {
+	return f.strip(f.FutureKey.Get())
+}
+
+func (f scopedFutureKey) MustGet() fdb.Key {
+	k, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return k
+}
+
+func (f scopedFutureKey) GetContext(ctx context.Context) (fdb.Key, error) {
+	return f.strip(f.FutureKey.GetContext(ctx))
+}
+
+// scopedEndpoint is an endpoint of a range read of a ScopedTransaction: either
+// a key relative to the Subspace, or a future resolving to one.
+type scopedEndpoint struct {
+	k fdb.Key
+	f fdb.FutureKey
+}
+
+func (ep scopedEndpoint) get() (fdb.Key, error) {
+	if ep.f == nil {
+		return ep.k, nil
+	}
+	return ep.f.Get()
+}
+
+// ScopedRangeResult is a handle to the asynchronous result of a range read of
+// a ScopedTransaction, as fdb.RangeResult is of a range read of a
+// Transaction. The range is read once its endpoints have been resolved, when
+// the result is first used.
+//
+// A ScopedRangeResult should not be returned from a transactional function
+// passed to the Transact method of a Transactor.
+type ScopedRangeResult struct {
+	tr fdb.WriteTransaction
+	prefix []byte
+	begin, end scopedEndpoint
+	options fdb.RangeOptions
+}
+
+// read resolves the endpoints of the range and begins reading it. It returns
+// false if the range is empty.
+func (sr ScopedRangeResult) read() (fdb.RangeResult, bool, error) {
+	begin, e := sr.begin.get()
+	if e != nil {
+		return fdb.RangeResult{}, false, e
+	}
+	end, e := sr.end.get()
+	if e != nil {
+		return fdb.RangeResult{}, false, e
+	}
+	if bytes.Compare(begin, end) >= 0 {
+		return fdb.RangeResult{}, false, nil
+	}
+
+	kr := fdb.KeyRange{Begin: fdb.Key(concat(sr.prefix, begin...)), End: fdb.Key(concat(sr.prefix, end...))}
+	return sr.tr.GetRange(kr, sr.options), true, nil
+}
+
+// GetSliceWithError returns the key-value pairs satisfying the range read that
+// returned this ScopedRangeResult, with the prefix of the Subspace removed from
+// their keys, or an error if any of the reads did not complete or an endpoint
+// resolved outside the Subspace. The current goroutine will be blocked until
+// all reads have completed.
+func (sr ScopedRangeResult) GetSliceWithError() ([]fdb.KeyValue, error) {
+	rr, ok, e := sr.read()
+	if !ok {
+		return nil, e
+	}
+
+	kvs, e := rr.GetSliceWithError()
+	if e != nil {
+		return nil, e
+	}
+	for i := range kvs {
+		kvs[i].Key = kvs[i].Key[len(sr.prefix):]
+	}
+	return kvs, nil
+}
+
+// GetSliceOrPanic is like GetSliceWithError, but panics rather than returning
+// an error.
+func (sr ScopedRangeResult) GetSliceOrPanic() []fdb.KeyValue {
+	kvs, e := sr.GetSliceWithError()
+	if e != nil {
+		panic(e)
+	}
+	return kvs
+}
+
+// Iterator returns a ScopedRangeIterator over the key-value pairs satisfying
+// the range read that returned this ScopedRangeResult.
+func (sr ScopedRangeResult) Iterator() *ScopedRangeIterator {
+	return &ScopedRangeIterator{sr: sr}
+}
+
+// ScopedRangeIterator returns the key-value pairs satisfying a range read of a
+// ScopedTransaction, with the prefix of the Subspace removed from their
+// keys. It is constructed with the (ScopedRangeResult).Iterator method, and is
+// used exactly as an fdb.RangeIterator: Advance must return true before each
+// call to Get or MustGet.
+type ScopedRangeIterator struct {
+	sr ScopedRangeResult
+	ri *fdb.RangeIterator
+	started bool
+	err error
+}
+
+// Advance attempts to advance the iterator to the next key-value pair,
+// returning false once the range has been exhausted. If the endpoints of the
+// range cannot be resolved, Advance returns true once, and Get returns the
+// error.
+func (si *ScopedRangeIterator) Advance() bool {
+	if !si.started {
+		si.started = true
+
+		rr, ok, e := si.sr.read()
+		if e != nil {
+			si.err = e
+			return true
+		}
+
+		if ok {
+			si.ri = rr.Iterator()
+		}
+	}
+
+	if si.ri == nil {
+		return false
+	}
+	return si.ri.Advance()
+}
+
+// Get returns the next key-value pair, or an error if a read did not complete
+// or an endpoint of the range resolved outside the Subspace.
+func (si *ScopedRangeIterator) Get() (fdb.KeyValue, error) {
+	if si.err != nil {
+		return fdb.KeyValue{}, si.err
+	}
+
+	kv, e := si.ri.Get()
+	if e != nil {
+		return kv, e
+	}
+	kv.Key = kv.Key[len(si.sr.prefix):]
+	return kv, nil
+}
+
+// MustGet is like Get, but panics rather than returning an error.
+func (si *ScopedRangeIterator) MustGet() fdb.KeyValue {
+	kv, e := si.Get()
+	if e != nil {
+		panic(e)
+	}
+	return kv
+}
@@ -53,6 +53,13 @@ type Subspace interface {
 	// Subspace prepended.
 	Pack(t tuple.Tuple) fdb.Key
 
+	// PackWithVersionstamp returns the key encoding the specified Tuple with
+	// the prefix of this Subspace prepended, suitable for use with
+	// (fdb.Transaction).SetVersionstampedKey. PackWithVersionstamp will return
+	// an error if the Tuple does not contain exactly one incomplete
+	// tuple.Versionstamp.
+	PackWithVersionstamp(t tuple.Tuple) (fdb.Key, error)
+
 	// Unpack returns the Tuple encoded by the given key with the prefix of this
 	// Subspace removed. Unpack will return an error if the key is not in this
 	// Subspace or does not encode a well-formed Tuple.
@@ -62,6 +69,21 @@ type Subspace interface {
 	// Subspace, indicating that the Subspace logically contains the key.
 	Contains(k fdb.KeyConvertible) bool
 
+	// Range returns the range of keys in this Subspace encoding tuples between
+	// begin and end, as described for tuple.Range. Range will return an error
+	// if either Tuple cannot be packed, or if begin sorts after end.
+	Range(begin, end tuple.Tuple, beginInclusive, endInclusive bool) (fdb.KeyRange, error)
+
+	// StringPrefixRange returns the range of keys in this Subspace encoding
+	// tuples that begin with the elements of the specified Tuple followed by a
+	// string beginning with prefix.
+	StringPrefixRange(t tuple.Tuple, prefix string) (fdb.KeyRange, error)
+
+	// BytesPrefixRange returns the range of keys in this Subspace encoding
+	// tuples that begin with the elements of the specified Tuple followed by a
+	// byte string beginning with prefix.
+	BytesPrefixRange(t tuple.Tuple, prefix []byte) (fdb.KeyRange, error)
+
 	// All Subspaces implement fdb.KeyConvertible and may be used as
 	// FoundationDB keys (corresponding to the prefix of this Subspace).
 	fdb.KeyConvertible
@@ -96,7 +118,7 @@ func FromBytes(b []byte) Subspace {
 }
 
 func (s subspace) Sub(el ...tuple.TupleElement) Subspace {
-	return subspace{concat(s.Bytes(), tuple.Tuple(el).Pack()...)}
+	return subspace{tuple.Tuple(el).AppendPack(concat(s.b))}
 }
 
 func (s subspace) Bytes() []byte {
@@ -104,7 +126,11 @@ func (s subspace) Bytes() []byte {
 }
 
 func (s subspace) Pack(t tuple.Tuple) fdb.Key {
-	return fdb.Key(concat(s.b, t.Pack()...))
+	return fdb.Key(t.AppendPack(concat(s.b)))
+}
+
+func (s subspace) PackWithVersionstamp(t tuple.Tuple) (fdb.Key, error) {
+	return t.PackWithVersionstamp(s.b)
 }
 
 func (s subspace) Unpack(k fdb.KeyConvertible) (tuple.Tuple, error) {
@@ -119,6 +145,26 @@ func (s subspace) Contains(k fdb.KeyConvertible) bool {
 	return bytes.HasPrefix(k.FDBKey(), s.b)
 }
 
+func (s subspace) Range(begin, end tuple.Tuple, beginInclusive, endInclusive bool) (fdb.KeyRange, error) {
+	return s.prefixRange(tuple.Range(begin, end, beginInclusive, endInclusive))
+}
+
+func (s subspace) StringPrefixRange(t tuple.Tuple, prefix string) (fdb.KeyRange, error) {
+	return s.prefixRange(tuple.StringPrefixRange(t, prefix))
+}
+
+func (s subspace) BytesPrefixRange(t tuple.Tuple, prefix []byte) (fdb.KeyRange, error) {
+	return s.prefixRange(tuple.BytesPrefixRange(t, prefix))
+}
+
+// prefixRange prepends the prefix of the subspace to both keys of kr.
+func (s subspace) prefixRange(kr fdb.KeyRange, e error) (fdb.KeyRange, error) {
+	if e != nil {
+		return fdb.KeyRange{}, e
+	}
+	return fdb.KeyRange{Begin: fdb.Key(concat(s.b, kr.Begin.FDBKey()...)), End: fdb.Key(concat(s.b, kr.End.FDBKey()...))}, nil
+}
+
 func (s subspace) FDBKey() fdb.Key {
 	return fdb.Key(s.b)
 }
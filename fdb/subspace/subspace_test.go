@@ -0,0 +1,278 @@
+// FoundationDB Go Subspace Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package subspace_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/memdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func set(t *testing.T, db fdb.Database, kvs ...string) {
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for i := 0; i < len(kvs); i += 2 {
+			tr.Set(fdb.Key(kvs[i]), []byte(kvs[i+1]))
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func keys(kvs []fdb.KeyValue) string {
+	var b bytes.Buffer
+	for _, kv := range kvs {
+		b.Write(kv.Key)
+	}
+	return b.String()
+}
+
+func TestSubspaceRanges(t *testing.T) {
+	db := memdb.New()
+	ss := subspace.Sub("scores")
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(subspace.Sub("other").Pack(tuple.Tuple{"alice", 150}), nil)
+		for i, name := range []string{"al", "alice", "alice\x00", "bob"} {
+			for _, score := range []int{99, 100, 150, 200, 201} {
+				tr.Set(ss.Pack(tuple.Tuple{name, score, i}), nil)
+			}
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	count := func(kr fdb.KeyRange, e error) int {
+		if e != nil {
+			t.Fatal(e)
+		}
+		kvs, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+			return rtr.GetRange(kr, fdb.RangeOptions{}).GetSliceWithError()
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		return len(kvs.([]fdb.KeyValue))
+	}
+
+	if n := count(ss.Range(tuple.Tuple{"alice", 100}, tuple.Tuple{"alice", 200}, true, true)); n != 3 {
+		t.Errorf("got %d keys with scores in [100, 200], expected 3", n)
+	}
+	if n := count(ss.Range(tuple.Tuple{"alice", 100}, tuple.Tuple{"alice", 200}, false, false)); n != 1 {
+		t.Errorf("got %d keys with scores in (100, 200), expected 1", n)
+	}
+	if n := count(ss.StringPrefixRange(nil, "alice")); n != 10 {
+		t.Errorf("got %d keys with names beginning \"alice\", expected 10", n)
+	}
+	if n := count(ss.StringPrefixRange(nil, "alice\x00")); n != 5 {
+		t.Errorf("got %d keys with names beginning \"alice\\x00\", expected 5", n)
+	}
+	if n := count(ss.BytesPrefixRange(nil, []byte("alice"))); n != 0 {
+		t.Errorf("got %d keys with byte string names, expected 0", n)
+	}
+}
+
+func TestMap(t *testing.T) {
+	db := memdb.New()
+
+	type score struct {
+		Points int
+		Level string
+	}
+	m := subspace.NewMap[string, score](subspace.Sub("scores"), subspace.ElementKeys[string](), subspace.JSONValues[score]())
+
+	for i, name := range []string{"carol", "alice", "dave", "bob"} {
+		if e := m.Set(db, name, score{i * 10, "easy"}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	set(t, db, "scoret", "", "scoreu", "")
+
+	v, ok, e := m.Get(db, "alice")
+	if e != nil || !ok || v != (score{10, "easy"}) {
+		t.Errorf("got %v, %v, %v for alice, expected {10 easy}, true, nil", v, ok, e)
+	}
+	if _, ok, e = m.Get(db, "eve"); e != nil || ok {
+		t.Errorf("got %v, %v for eve, expected false, nil", ok, e)
+	}
+
+	if e = m.Delete(db, "dave"); e != nil {
+		t.Fatal(e)
+	}
+	if n, e := m.Count(db); e != nil || n != 3 {
+		t.Errorf("got count %d, %v, expected 3", n, e)
+	}
+
+	_, e = db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		entries, e := m.All(rtr, fdb.RangeOptions{}).GetSliceWithError()
+		if e != nil {
+			return nil, e
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Key)
+		}
+		if s := fmt.Sprint(names); s != "[alice bob carol]" {
+			t.Errorf("got names %s, expected [alice bob carol]", s)
+		}
+
+		mi := m.Range(rtr, "b", "c", fdb.RangeOptions{}).Iterator()
+		var n int
+		for mi.Advance() {
+			k, v := mi.MustGet()
+			if k != "bob" || v.Points != 30 {
+				t.Errorf("got %s: %v in range [b, c), expected bob: {30 easy}", k, v)
+			}
+			n++
+		}
+		if n != 1 {
+			t.Errorf("got %d entries in range [b, c), expected 1", n)
+		}
+
+		if _, e := m.Range(rtr, "c", "b", fdb.RangeOptions{}).GetSliceWithError(); e == nil {
+			t.Error("expected error from inverted map range")
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	set(t, db, string(m.Subspace().Pack(tuple.Tuple{"mallory"})), "not json")
+	_, e = db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return m.All(rtr, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	var se *json.SyntaxError
+	if !errors.As(e, &se) {
+		t.Errorf("got %v decoding invalid map value, expected a json.SyntaxError", e)
+	}
+
+	// The empty tuple is stored at the prefix of the map itself.
+	tm := subspace.NewMap[tuple.Tuple, []byte](subspace.Sub("tuples"), subspace.TupleKeys(), subspace.BytesValues())
+	for _, k := range []tuple.Tuple{{}, {1}} {
+		if e := tm.Set(db, k, []byte("v")); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if n, e := tm.Count(db); e != nil || n != 2 {
+		t.Errorf("got count %d, %v for map with empty key, expected 2", n, e)
+	}
+	_, e = db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		entries, e := tm.All(rtr, fdb.RangeOptions{}).GetSliceWithError()
+		if e != nil {
+			return nil, e
+		}
+		if len(entries) != 2 || len(entries[0].Key) != 0 {
+			t.Errorf("got entries %v, expected the empty tuple first", entries)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	errKey := errors.New("unencodable key")
+	fm := subspace.NewMap[string, []byte](subspace.Sub("failing"), failingKeys{errKey}, subspace.BytesValues())
+	if e := fm.Set(db, "k", nil); !errors.Is(e, errKey) {
+		t.Errorf("got %v, expected the error of the key codec", e)
+	}
+}
+
+// failingKeys is a KeyCodec that cannot encode or decode any key.
+type failingKeys struct {
+	err error
+}
+
+func (fk failingKeys) EncodeKey(k string) ([]byte, error) {
+	return nil, fk.err
+}
+
+func (fk failingKeys) DecodeKey(b []byte) (string, error) {
+	return "", fk.err
+}
+
+func TestScopedTransaction(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "a", "outside", "app/", "prefix", "app/x", "1", "app/y", "2", "app/z", "3", "apq", "outside")
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		st := subspace.Scoped(tr, subspace.FromBytes([]byte("app/")))
+
+		if v := st.Get(fdb.Key("y")).MustGet(); string(v) != "2" {
+			t.Errorf("got %q for y, expected \"2\"", v)
+		}
+		st.Set(fdb.Key("w"), []byte("0"))
+		st.Clear(fdb.Key("z"))
+
+		kvs := st.GetRange(fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key{0xFF}}, fdb.RangeOptions{}).GetSliceOrPanic()
+		if k := keys(kvs); k != "wxy" {
+			t.Errorf("got keys %q, expected \"wxy\"", k)
+		}
+
+		sr := fdb.SelectorRange{Begin: fdb.FirstGreaterThan(fdb.Key("w")), End: fdb.LastLessOrEqual(fdb.Key("y"))}
+		var got []string
+		for si := st.GetRange(sr, fdb.RangeOptions{}).Iterator(); si.Advance(); {
+			got = append(got, string(si.MustGet().Key))
+		}
+		if s := fmt.Sprint(got); s != "[x]" {
+			t.Errorf("got keys %s from selector range, expected [x]", s)
+		}
+
+		if k := st.GetKey(fdb.FirstGreaterThan(fdb.Key("w"))).MustGet(); string(k) != "x" {
+			t.Errorf("got key %q after w, expected \"x\"", k)
+		}
+		for _, sel := range []fdb.KeySelector{fdb.FirstGreaterThan(fdb.Key("y")), fdb.LastLessThan(fdb.Key(""))} {
+			if _, e := st.GetKey(sel).Get(); e != subspace.ErrOutsideSubspace {
+				t.Errorf("got %v resolving %v, expected ErrOutsideSubspace", e, sel)
+			}
+		}
+		sr = fdb.SelectorRange{Begin: fdb.FirstGreaterOrEqual(fdb.Key("x")), End: fdb.FirstGreaterThan(fdb.Key("y"))}
+		if _, e := st.GetRange(sr, fdb.RangeOptions{}).GetSliceWithError(); e != subspace.ErrOutsideSubspace {
+			t.Errorf("got %v reading past the end of the subspace, expected ErrOutsideSubspace", e)
+		}
+
+		st.ClearRange(fdb.KeyRange{Begin: fdb.Key("x"), End: fdb.Key{0xFF}})
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	kvs, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.GetRange(fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key{0xFF}}, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if k := keys(kvs.([]fdb.KeyValue)); k != "aapp/app/wapq" {
+		t.Errorf("got keys %q, expected \"aapp/app/wapq\"", k)
+	}
+}
@@ -21,12 +21,6 @@
 
 package fdb
 
-/*
- #define FDB_API_VERSION 200
- #include <foundationdb/fdb_c.h>
-*/
-import "C"
-
 // A ReadTransaction can asynchronously read from a FoundationDB
 // database. Transaction and Snapshot both satisfy the ReadTransaction
 // interface.
@@ -44,6 +38,60 @@ type ReadTransaction interface {
 	ReadTransactor
 }
 
+// A WriteTransaction can asynchronously read from and modify a FoundationDB
+// database. Transaction satisfies the WriteTransaction interface.
+//
+// Code written against WriteTransaction rather than Transaction may also be
+// used with types that wrap or mock a Transaction, such as those that add
+// instrumentation or record the operations performed. Transactional functions
+// passed to Transact are nevertheless given a Transaction, so such a wrapper
+// does not see operations performed by code that calls Transact (such as the
+// directory layer); see DatabaseHandle.
+//
+// All WriteTransactions satisfy the Transactor interface and may be used with
+// transactional functions.
+type WriteTransaction interface {
+	ReadTransaction
+
+	Set(key KeyConvertible, value []byte)
+	Clear(key KeyConvertible)
+	ClearRange(er ExactRange)
+
+	Add(key KeyConvertible, param []byte)
+	BitAnd(key KeyConvertible, param []byte)
+	BitOr(key KeyConvertible, param []byte)
+	BitXor(key KeyConvertible, param []byte)
+	AppendIfFits(key KeyConvertible, param []byte)
+	Max(key KeyConvertible, param []byte)
+	Min(key KeyConvertible, param []byte)
+	SetVersionstampedKey(key KeyConvertible, param []byte)
+	SetVersionstampedValue(key KeyConvertible, param []byte)
+	ByteMin(key KeyConvertible, param []byte)
+	ByteMax(key KeyConvertible, param []byte)
+	CompareAndClear(key KeyConvertible, param []byte)
+
+	AddReadConflictRange(er ExactRange) error
+	AddReadConflictKey(key KeyConvertible) error
+	AddWriteConflictRange(er ExactRange) error
+	AddWriteConflictKey(key KeyConvertible) error
+
+	Options() TransactionOptions
+	SetReadVersion(version int64)
+	GetCommittedVersion() (int64, error)
+	LocalityGetAddressesForKey(key KeyConvertible) FutureStringSlice
+
+	Watch(key KeyConvertible) FutureNil
+	Commit() FutureNil
+	GetVersionstamp() FutureKey
+	OnError(e Error) FutureNil
+	Cancel()
+	Reset()
+
+	Transactor
+}
+
+var _ WriteTransaction = Transaction{}
+
 // Transaction is a handle to a FoundationDB transaction. Transaction is a
 // lightweight object that may be efficiently copied, and is safe for concurrent
 // use by multiple goroutines.
@@ -68,7 +116,7 @@ type Transaction struct {
 }
 
 type transaction struct {
-	ptr *C.FDBTransaction
+	b TransactionBackend
 	db Database
 }
 
@@ -80,13 +128,7 @@ type TransactionOptions struct {
 }
 
 func (opt TransactionOptions) setOpt(code int, param []byte) error {
-	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
-		return C.fdb_transaction_set_option(opt.transaction.ptr, C.FDBTransactionOption(code), p, pl)
-	}, param)
-}
-
-func (t *transaction) destroy() {
-	C.fdb_transaction_destroy(t.ptr)
+	return opt.transaction.b.SetOption(code, param)
 }
 
 // GetDatabase returns a handle to the database with which this transaction is
@@ -153,7 +195,7 @@ func (t Transaction) ReadTransact(f func(ReadTransaction) (interface{}, error))
 // error, the commit may have occurred or may occur in the future. This can make
 // it more difficult to reason about the order in which transactions occur.
 func (t Transaction) Cancel() {
-	C.fdb_transaction_cancel(t.ptr)
+	t.b.Cancel()
 }
 
 // (Infrequently used) SetReadVersion sets the database version that the transaction will read from
@@ -161,7 +203,7 @@ func (t Transaction) Cancel() {
 // is used (the transaction’s reads will be causally consistent only if the
 // provided read version has that property).
 func (t Transaction) SetReadVersion(version int64) {
-	C.fdb_transaction_set_read_version(t.ptr, C.int64_t(version))
+	t.b.SetReadVersion(version)
 }
 
 // Snapshot returns a Snapshot object, suitable for performing snapshot
@@ -183,7 +225,7 @@ func (t Transaction) Snapshot() Snapshot {
 // Typical code will not use OnError directly. (Database).Transact uses
 // OnError internally to implement a correct retry loop.
 func (t Transaction) OnError(e Error) FutureNil {
-	return &futureNil{newFuture(C.fdb_transaction_on_error(t.ptr, C.fdb_error_t(e.Code)))}
+	return t.b.OnError(e)
 }
 
 // Commit attempts to commit the modifications made in the transaction to the
@@ -197,7 +239,20 @@ func (t Transaction) OnError(e Error) FutureNil {
 // see
 // https://foundationdb.com/documentation/developer-guide.html#developer-guide-unknown-results.
 func (t Transaction) Commit() FutureNil {
-	return &futureNil{newFuture(C.fdb_transaction_commit(t.ptr))}
+	return t.b.Commit()
+}
+
+// GetVersionstamp returns a future which will contain the versionstamp used by
+// any versionstamp operations in this transaction. The future will be ready
+// only after the successful completion of a call to Commit on this
+// Transaction. Read-only transactions do not modify the database when
+// committed, and will result in the future completing with an error. Keep in
+// mind that a transaction which reads keys and then sets them to their current
+// values may be optimized to a read-only transaction.
+//
+// GetVersionstamp must be called before Commit.
+func (t Transaction) GetVersionstamp() FutureKey {
+	return t.b.GetVersionstamp()
 }
 
 // Watch creates a watch and returns a FutureNil that will become ready when the
@@ -228,31 +283,27 @@ func (t Transaction) Commit() FutureNil {
 // to create a watch will return a too_many_watches error. This limit can be
 // changed using SetMaxWatches on the Database. Because a watch outlives the
 // transaction that creates it, any watch that is no longer needed should be
-// cancelled by calling (FutureNil).Cancel on its returned future.
+// cancelled by calling (FutureNil).Cancel on its returned future. Waiting on
+// the returned future with (FutureNil).GetContext cancels the watch
+// automatically when the context is done.
 func (t Transaction) Watch(key KeyConvertible) FutureNil {
-	kb := key.FDBKey()
-	return &futureNil{newFuture(C.fdb_transaction_watch(t.ptr, byteSliceToPtr(kb), C.int(len(kb))))}
+	return t.b.Watch(key.FDBKey())
 }
 
-func (t *transaction) get(key []byte, snapshot int) FutureByteSlice {
-	return &futureByteSlice{future: newFuture(C.fdb_transaction_get(t.ptr, byteSliceToPtr(key), C.int(len(key)), C.fdb_bool_t(snapshot)))}
+func (t *transaction) get(key Key, snapshot bool) FutureByteSlice {
+	return t.b.Get(key, snapshot)
 }
 
 // Get returns the (future) value associated with the specified key. The read is
 // performed asynchronously and does not block the calling goroutine. The future
 // will become ready when the read is complete.
 func (t Transaction) Get(key KeyConvertible) FutureByteSlice {
-	return t.get(key.FDBKey(), 0)
+	return t.get(key.FDBKey(), false)
 }
 
-func (t *transaction) doGetRange(r Range, options RangeOptions, snapshot bool, iteration int) futureKeyValueArray {
+func (t *transaction) doGetRange(r Range, options RangeOptions, snapshot bool, iteration int) FutureKeyValueArray {
 	begin, end := r.FDBRangeKeySelectors()
-	bsel := begin.FDBKeySelector()
-	esel := end.FDBKeySelector()
-	bkey := bsel.Key.FDBKey()
-	ekey := esel.Key.FDBKey()
-
-	return futureKeyValueArray{newFuture(C.fdb_transaction_get_range(t.ptr, byteSliceToPtr(bkey), C.int(len(bkey)), C.fdb_bool_t(boolToInt(bsel.OrEqual)), C.int(bsel.Offset), byteSliceToPtr(ekey), C.int(len(ekey)), C.fdb_bool_t(boolToInt(esel.OrEqual)), C.int(esel.Offset), C.int(options.Limit), C.int(0), C.FDBStreamingMode(options.Mode-1), C.int(iteration), C.fdb_bool_t(boolToInt(snapshot)), C.fdb_bool_t(boolToInt(options.Reverse))))}
+	return t.b.GetRange(begin.FDBKeySelector(), end.FDBKeySelector(), options, snapshot, iteration)
 }
 
 func (t *transaction) getRange(r Range, options RangeOptions, snapshot bool) RangeResult {
@@ -263,7 +314,7 @@ func (t *transaction) getRange(r Range, options RangeOptions, snapshot bool) Ran
 		sr: SelectorRange{begin, end},
 		options: options,
 		snapshot: snapshot,
-		f: &f,
+		f: f,
 	}
 }
 
@@ -277,7 +328,7 @@ func (t Transaction) GetRange(r Range, options RangeOptions) RangeResult {
 }
 
 func (t *transaction) getReadVersion() FutureInt64 {
-	return &futureInt64{newFuture(C.fdb_transaction_get_read_version(t.ptr))}
+	return t.b.GetReadVersion()
 }
 
 // (Infrequently used) GetReadVersion returns the (future) transaction read version. The read is
@@ -291,16 +342,14 @@ func (t Transaction) GetReadVersion() FutureInt64 {
 // with key. Set returns immediately, having modified the snapshot of the
 // database represented by the transaction.
 func (t Transaction) Set(key KeyConvertible, value []byte) {
-	kb := key.FDBKey()
-	C.fdb_transaction_set(t.ptr, byteSliceToPtr(kb), C.int(len(kb)), byteSliceToPtr(value), C.int(len(value)))
+	t.b.Set(key.FDBKey(), value)
 }
 
 // Clear removes the specified key (and any associated value), if it
 // exists. Clear returns immediately, having modified the snapshot of the
 // database represented by the transaction.
 func (t Transaction) Clear(key KeyConvertible) {
-	kb := key.FDBKey()
-	C.fdb_transaction_clear(t.ptr, byteSliceToPtr(kb), C.int(len(kb)))
+	t.b.Clear(key.FDBKey())
 }
 
 // ClearRange removes all keys k such that begin <= k < end, and their
@@ -308,9 +357,7 @@ func (t Transaction) Clear(key KeyConvertible) {
 // snapshot of the database represented by the transaction.
 func (t Transaction) ClearRange(er ExactRange) {
 	begin, end := er.FDBRangeKeys()
-	bkb := begin.FDBKey()
-	ekb := end.FDBKey()
-	C.fdb_transaction_clear_range(t.ptr, byteSliceToPtr(bkb), C.int(len(bkb)), byteSliceToPtr(ekb), C.int(len(ekb)))
+	t.b.ClearRange(begin.FDBKey(), end.FDBKey())
 }
 
 // (Infrequently used) GetCommittedVersion returns the version number at which a
@@ -321,33 +368,18 @@ func (t Transaction) ClearRange(er ExactRange) {
 // transaction which reads keys and then sets them to their current values may
 // be optimized to a read-only transaction.
 func (t Transaction) GetCommittedVersion() (int64, error) {
-	var version C.int64_t
-
-	if err := C.fdb_transaction_get_committed_version(t.ptr, &version); err != 0 {
-		return 0, Error{int(err)}
-	}
-
-	return int64(version), nil
+	return t.b.GetCommittedVersion()
 }
 
 // Reset rolls back a transaction, completely resetting it to its initial
 // state. This is logically equivalent to destroying the transaction and
 // creating a new one.
 func (t Transaction) Reset() {
-	C.fdb_transaction_reset(t.ptr)
+	t.b.Reset()
 }
 
-func boolToInt(b bool) int {
-	if b {
-		return 1
-	} else {
-		return 0
-	}
-}
-
-func (t *transaction) getKey(sel KeySelector, snapshot int) FutureKey {
-	key := sel.Key.FDBKey()
-	return &futureKey{future: newFuture(C.fdb_transaction_get_key(t.ptr, byteSliceToPtr(key), C.int(len(key)), C.fdb_bool_t(boolToInt(sel.OrEqual)), C.int(sel.Offset), C.fdb_bool_t(snapshot)))}
+func (t *transaction) getKey(sel KeySelector, snapshot bool) FutureKey {
+	return t.b.GetKey(sel, snapshot)
 }
 
 // GetKey returns the future key referenced by the provided key selector. The
@@ -360,22 +392,19 @@ func (t *transaction) getKey(sel KeySelector, snapshot int) FutureKey {
 // (TransactionOptions).SetReadYourWritesDisable will avoid both the caching and
 // the increased network bandwidth.
 func (t Transaction) GetKey(sel Selectable) FutureKey {
-	return t.getKey(sel.FDBKeySelector(), 0)
+	return t.getKey(sel.FDBKeySelector(), false)
 }
 
-func (t Transaction) atomicOp(key []byte, param []byte, code int) {
-	C.fdb_transaction_atomic_op(t.ptr, byteSliceToPtr(key), C.int(len(key)), byteSliceToPtr(param), C.int(len(param)), C.FDBMutationType(code))
+func (t Transaction) atomicOp(key Key, param []byte, code int) {
+	t.b.AtomicOp(key, param, code)
 }
 
 func addConflictRange(t *transaction, er ExactRange, crtype conflictRangeType) error {
 	begin, end := er.FDBRangeKeys()
-	bkb := begin.FDBKey()
-	ekb := end.FDBKey()
-	if err := C.fdb_transaction_add_conflict_range(t.ptr, byteSliceToPtr(bkb), C.int(len(bkb)), byteSliceToPtr(ekb), C.int(len(ekb)), C.FDBConflictRangeType(crtype)); err != 0 {
-		return Error{int(err)}
+	if crtype == conflictRangeTypeWrite {
+		return t.b.AddWriteConflictRange(begin.FDBKey(), end.FDBKey())
 	}
-
-	return nil
+	return t.b.AddReadConflictRange(begin.FDBKey(), end.FDBKey())
 }
 
 // AddReadConflictRange adds a range of keys to the transaction’s read conflict
@@ -433,8 +462,7 @@ func (t Transaction) Options() TransactionOptions {
 }
 
 func localityGetAddressesForKey(t *transaction, key KeyConvertible) FutureStringSlice {
-	kb := key.FDBKey()
-	return &futureStringSlice{newFuture(C.fdb_transaction_get_addresses_for_key(t.ptr, byteSliceToPtr(kb), C.int(len(kb))))}
+	return t.b.GetAddressesForKey(key.FDBKey())
 }
 
 // LocalityGetAddressesForKey returns the (future) public network addresses of
@@ -0,0 +1,148 @@
+// FoundationDB Go API
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build cgo
+
+package fdb
+
+/*
+ #define FDB_API_VERSION 710
+ #include <foundationdb/fdb_c.h>
+*/
+import "C"
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// nativeTransaction is the TransactionBackend provided by the FoundationDB C
+// library.
+type nativeTransaction struct {
+	ptr *C.FDBTransaction
+}
+
+func (t *nativeTransaction) destroy() {
+	C.fdb_transaction_destroy(t.ptr)
+}
+
+func (t *nativeTransaction) Get(key Key, snapshot bool) FutureByteSlice {
+	return &futureByteSlice{future: newFuture(C.fdb_transaction_get(t.ptr, byteSliceToPtr(key), C.int(len(key)), C.fdb_bool_t(boolToInt(snapshot))))}
+}
+
+func (t *nativeTransaction) GetKey(sel KeySelector, snapshot bool) FutureKey {
+	key := sel.Key.FDBKey()
+	return &futureKey{future: newFuture(C.fdb_transaction_get_key(t.ptr, byteSliceToPtr(key), C.int(len(key)), C.fdb_bool_t(boolToInt(sel.OrEqual)), C.int(sel.Offset), C.fdb_bool_t(boolToInt(snapshot))))}
+}
+
+func (t *nativeTransaction) GetRange(bsel, esel KeySelector, options RangeOptions, snapshot bool, iteration int) FutureKeyValueArray {
+	bkey := bsel.Key.FDBKey()
+	ekey := esel.Key.FDBKey()
+
+	return futureKeyValueArray{newFuture(C.fdb_transaction_get_range(t.ptr, byteSliceToPtr(bkey), C.int(len(bkey)), C.fdb_bool_t(boolToInt(bsel.OrEqual)), C.int(bsel.Offset), byteSliceToPtr(ekey), C.int(len(ekey)), C.fdb_bool_t(boolToInt(esel.OrEqual)), C.int(esel.Offset), C.int(options.Limit), C.int(0), C.FDBStreamingMode(options.Mode-1), C.int(iteration), C.fdb_bool_t(boolToInt(snapshot)), C.fdb_bool_t(boolToInt(options.Reverse))))}
+}
+
+func (t *nativeTransaction) GetReadVersion() FutureInt64 {
+	return &futureInt64{newFuture(C.fdb_transaction_get_read_version(t.ptr))}
+}
+
+func (t *nativeTransaction) SetReadVersion(version int64) {
+	C.fdb_transaction_set_read_version(t.ptr, C.int64_t(version))
+}
+
+func (t *nativeTransaction) GetCommittedVersion() (int64, error) {
+	var version C.int64_t
+
+	if err := C.fdb_transaction_get_committed_version(t.ptr, &version); err != 0 {
+		return 0, Error{int(err)}
+	}
+
+	return int64(version), nil
+}
+
+func (t *nativeTransaction) Set(key Key, value []byte) {
+	C.fdb_transaction_set(t.ptr, byteSliceToPtr(key), C.int(len(key)), byteSliceToPtr(value), C.int(len(value)))
+}
+
+func (t *nativeTransaction) Clear(key Key) {
+	C.fdb_transaction_clear(t.ptr, byteSliceToPtr(key), C.int(len(key)))
+}
+
+func (t *nativeTransaction) ClearRange(begin, end Key) {
+	C.fdb_transaction_clear_range(t.ptr, byteSliceToPtr(begin), C.int(len(begin)), byteSliceToPtr(end), C.int(len(end)))
+}
+
+func (t *nativeTransaction) AtomicOp(key Key, param []byte, code int) {
+	C.fdb_transaction_atomic_op(t.ptr, byteSliceToPtr(key), C.int(len(key)), byteSliceToPtr(param), C.int(len(param)), C.FDBMutationType(code))
+}
+
+func (t *nativeTransaction) addConflictRange(begin, end Key, crtype conflictRangeType) error {
+	if err := C.fdb_transaction_add_conflict_range(t.ptr, byteSliceToPtr(begin), C.int(len(begin)), byteSliceToPtr(end), C.int(len(end)), C.FDBConflictRangeType(crtype)); err != 0 {
+		return Error{int(err)}
+	}
+
+	return nil
+}
+
+func (t *nativeTransaction) AddReadConflictRange(begin, end Key) error {
+	return t.addConflictRange(begin, end, conflictRangeTypeRead)
+}
+
+func (t *nativeTransaction) AddWriteConflictRange(begin, end Key) error {
+	return t.addConflictRange(begin, end, conflictRangeTypeWrite)
+}
+
+func (t *nativeTransaction) SetOption(code int, param []byte) error {
+	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
+		return C.fdb_transaction_set_option(t.ptr, C.FDBTransactionOption(code), p, pl)
+	}, param)
+}
+
+func (t *nativeTransaction) Watch(key Key) FutureNil {
+	return &futureNil{newFuture(C.fdb_transaction_watch(t.ptr, byteSliceToPtr(key), C.int(len(key))))}
+}
+
+func (t *nativeTransaction) Commit() FutureNil {
+	return &futureNil{newFuture(C.fdb_transaction_commit(t.ptr))}
+}
+
+func (t *nativeTransaction) GetVersionstamp() FutureKey {
+	return &futureKey{future: newFuture(C.fdb_transaction_get_versionstamp(t.ptr))}
+}
+
+func (t *nativeTransaction) OnError(e Error) FutureNil {
+	return &futureNil{newFuture(C.fdb_transaction_on_error(t.ptr, C.fdb_error_t(e.Code)))}
+}
+
+func (t *nativeTransaction) Cancel() {
+	C.fdb_transaction_cancel(t.ptr)
+}
+
+func (t *nativeTransaction) Reset() {
+	C.fdb_transaction_reset(t.ptr)
+}
+
+func (t *nativeTransaction) GetAddressesForKey(key Key) FutureStringSlice {
+	return &futureStringSlice{newFuture(C.fdb_transaction_get_addresses_for_key(t.ptr, byteSliceToPtr(key), C.int(len(key))))}
+}
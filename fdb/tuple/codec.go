@@ -0,0 +1,206 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "github.com/FoundationDB/fdb-go/fdb"
+import "errors"
+import "fmt"
+import "math/big"
+import "reflect"
+import "sync"
+
+// User type codes, which the tuple encoding reserves for application-defined
+// types.
+const (
+	userTypeStart = 0x40
+	userTypeEnd = 0x4f
+)
+
+// A Codec converts values of an application-defined Go type to and from tuple
+// elements, so that they may be used directly as elements of a Tuple. Codecs
+// are registered with RegisterCodec.
+type Codec struct {
+	// Code is the user type code (0x40 through 0x4f) that identifies values of
+	// the type in packed tuples. Each value is encoded as Code followed by the
+	// encoding of the element returned by Encode, and Unpack returns the value
+	// produced by Decode. Values of the type sort after elements of all
+	// built-in types, and among themselves in the order of their encoded
+	// elements.
+	//
+	// If Code is zero, values are encoded exactly as the element returned by
+	// Encode, and so sort among elements of that type. Unpack returns the
+	// encoded element itself, which Unmarshal converts with Decode when
+	// unmarshaling into a value of the registered type.
+	Code byte
+
+	// Encode returns the tuple element representing v, which is always of the
+	// registered type. The element must not itself require a codec. To
+	// preserve the order of values in keys, the encoded elements must sort in
+	// the order of the values they represent.
+	Encode func(v interface{}) (TupleElement, error)
+
+	// Decode returns the value of the registered type represented by el.
+	Decode func(el TupleElement) (interface{}, error)
+}
+
+type codec struct {
+	Codec
+	typ reflect.Type
+}
+
+var codecs struct {
+	sync.RWMutex
+	byType map[reflect.Type]*codec
+	byCode [userTypeEnd - userTypeStart + 1]*codec
+}
+
+// RegisterCodec registers c to encode and decode values of the same type as
+// value. Once registered, values of the type may be packed (by Pack, a
+// subspace.Subspace, a Packer or Marshal) and compared (by Compare) as tuple
+// elements, and values encoded with a user type code are returned by Unpack.
+//
+// RegisterCodec returns an error if the type is one of the types listed for
+// TupleElement, if the type or user type code has already been registered, or
+// if c is incomplete. Codecs cannot be unregistered, and are typically
+// registered by an init function.
+func RegisterCodec(value interface{}, c Codec) error {
+	switch value.(type) {
+	case nil:
+		return errors.New("cannot register a codec for nil")
+	case int64, int, uint64, uint, *big.Int, big.Int, float32, float64, bool, UUID, Tuple, []byte, fdb.KeyConvertible, string, Versionstamp:
+		return fmt.Errorf("cannot register a codec for %T (a built-in tuple element type)", value)
+	}
+
+	if c.Code != 0 && (c.Code < userTypeStart || c.Code > userTypeEnd) {
+		return fmt.Errorf("invalid user type code %02x (must be zero or between %02x and %02x)", c.Code, userTypeStart, userTypeEnd)
+	}
+	if c.Encode == nil || c.Decode == nil {
+		return fmt.Errorf("codec for %T must have both Encode and Decode functions", value)
+	}
+
+	typ := reflect.TypeOf(value)
+
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if _, ok := codecs.byType[typ]; ok {
+		return fmt.Errorf("a codec is already registered for %s", typ)
+	}
+	if c.Code != 0 {
+		if other := codecs.byCode[c.Code-userTypeStart]; other != nil {
+			return fmt.Errorf("user type code %02x is already registered for %s", c.Code, other.typ)
+		}
+	}
+
+	if codecs.byType == nil {
+		codecs.byType = make(map[reflect.Type]*codec)
+	}
+	cd := &codec{c, typ}
+	codecs.byType[typ] = cd
+	if c.Code != 0 {
+		codecs.byCode[c.Code-userTypeStart] = cd
+	}
+
+	return nil
+}
+
+func codecForType(typ reflect.Type) *codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.byType[typ]
+}
+
+func codecForCode(code byte) *codec {
+	if code < userTypeStart || code > userTypeEnd {
+		return nil
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.byCode[code-userTypeStart]
+}
+
+// encode returns the element representing v.
+func (c *codec) encode(v interface{}) (TupleElement, error) {
+	el, e := c.Encode(v)
+	if e != nil {
+		return nil, fmt.Errorf("cannot encode %s: %v", c.typ, e)
+	}
+	if el != nil && codecForType(reflect.TypeOf(el)) != nil {
+		return nil, fmt.Errorf("codec for %s encoded a value of type %T, which itself requires a codec", c.typ, el)
+	}
+	return el, nil
+}
+
+// appendTo appends the encoding of v, which is of the codec's type, to dst.
+func (c *codec) appendTo(dst []byte, v interface{}, stamps *[]int, nested bool) ([]byte, error) {
+	el, e := c.encode(v)
+	if e != nil {
+		return dst, e
+	}
+
+	// The element following a user type code is delimited by its own
+	// encoding, so need not be escaped even within a nested tuple.
+	if c.Code != 0 {
+		dst = append(dst, c.Code)
+		nested = false
+	}
+
+	return Tuple{el}.appendTo(dst, stamps, nested)
+}
+
+// decode returns the value of the codec's type represented by el.
+func (c *codec) decode(el TupleElement) (interface{}, error) {
+	v, e := c.Decode(el)
+	if e != nil {
+		return nil, fmt.Errorf("cannot decode %s: %v", c.typ, e)
+	}
+	if reflect.TypeOf(v) != c.typ {
+		return nil, fmt.Errorf("codec for %s decoded a value of type %T", c.typ, v)
+	}
+	return v, nil
+}
+
+// userElement is the form in which Compare orders a value encoded with a user
+// type code.
+type userElement struct {
+	code byte
+	el TupleElement
+}
+
+// lowerElement returns the element of t at index i, which must be a value of a
+// registered type, as a single-element tuple holding the form in which it is
+// ordered. It panics if the element cannot be encoded.
+func lowerElement(t Tuple, i int) Tuple {
+	c := codecForType(reflect.TypeOf(t[i]))
+	if c == nil {
+		panic(fmt.Sprintf("unencodable element at index %d (%v, type %T)", i, t[i], t[i]))
+	}
+
+	el, e := c.encode(t[i])
+	if e != nil {
+		panic(fmt.Errorf("unencodable element at index %d: %v", i, e))
+	}
+	if c.Code != 0 {
+		return Tuple{userElement{c.Code, el}}
+	}
+	return Tuple{el}
+}
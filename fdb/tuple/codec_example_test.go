@@ -0,0 +1,70 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Timestamps are stored in keys as user type 0x40, followed by the number of
+// nanoseconds since the Unix epoch.
+func init() {
+	e := tuple.RegisterCodec(time.Time{}, tuple.Codec{
+		Code: 0x40,
+		Encode: func(v interface{}) (tuple.TupleElement, error) {
+			return v.(time.Time).UnixNano(), nil
+		},
+		Decode: func(el tuple.TupleElement) (interface{}, error) {
+			ns, ok := el.(int64)
+			if !ok {
+				return nil, fmt.Errorf("invalid timestamp %v", el)
+			}
+			return time.Unix(0, ns).UTC(), nil
+		},
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func ExampleRegisterCodec() {
+	when := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	key := tuple.Tuple{"events", when}.Pack()
+	earlier := tuple.Tuple{"events", when.Add(-time.Hour)}.Pack()
+
+	t, e := tuple.Unpack(key)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+
+	fmt.Println(t[1].(time.Time).Format(time.RFC3339))
+	fmt.Println(bytes.Compare(earlier, key))
+
+	// Output:
+	// 2024-03-01T12:30:00Z
+	// -1
+}
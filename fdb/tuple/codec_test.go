@@ -0,0 +1,229 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// priority is encoded as a plain integer.
+type priority int
+
+// amount is encoded as user type 0x41 followed by an integer. Negative amounts
+// cannot be decoded.
+type amount struct {
+	cents int64
+}
+
+// broken cannot be encoded.
+type broken struct{}
+
+func init() {
+	e := tuple.RegisterCodec(priority(0), tuple.Codec{
+		Encode: func(v interface{}) (tuple.TupleElement, error) {
+			return int64(v.(priority)), nil
+		},
+		Decode: func(el tuple.TupleElement) (interface{}, error) {
+			i, ok := el.(int64)
+			if !ok {
+				return nil, fmt.Errorf("invalid priority %v", el)
+			}
+			return priority(i), nil
+		},
+	})
+	if e != nil {
+		panic(e)
+	}
+
+	e = tuple.RegisterCodec(amount{}, tuple.Codec{
+		Code: 0x41,
+		Encode: func(v interface{}) (tuple.TupleElement, error) {
+			return v.(amount).cents, nil
+		},
+		Decode: func(el tuple.TupleElement) (interface{}, error) {
+			i, ok := el.(int64)
+			if !ok || i < 0 {
+				return nil, fmt.Errorf("invalid amount %v", el)
+			}
+			return amount{i}, nil
+		},
+	})
+	if e != nil {
+		panic(e)
+	}
+
+	e = tuple.RegisterCodec(broken{}, tuple.Codec{
+		Encode: func(v interface{}) (tuple.TupleElement, error) {
+			return nil, errors.New("unencodable")
+		},
+		Decode: func(el tuple.TupleElement) (interface{}, error) {
+			return broken{}, nil
+		},
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	encode := func(v interface{}) (tuple.TupleElement, error) { return nil, nil }
+	decode := func(el tuple.TupleElement) (interface{}, error) { return nil, nil }
+
+	type unregistered struct{}
+
+	tests := []struct {
+		value interface{}
+		c tuple.Codec
+		err string
+	}{
+		{nil, tuple.Codec{Encode: encode, Decode: decode}, "cannot register a codec for nil"},
+		{int64(0), tuple.Codec{Encode: encode, Decode: decode}, "cannot register a codec for int64 (a built-in tuple element type)"},
+		{tuple.Tuple{}, tuple.Codec{Encode: encode, Decode: decode}, "cannot register a codec for tuple.Tuple (a built-in tuple element type)"},
+		{unregistered{}, tuple.Codec{Code: 0x3F, Encode: encode, Decode: decode}, "invalid user type code 3f (must be zero or between 40 and 4f)"},
+		{unregistered{}, tuple.Codec{Code: 0x42, Encode: encode}, "codec for tuple_test.unregistered must have both Encode and Decode functions"},
+		{priority(0), tuple.Codec{Encode: encode, Decode: decode}, "a codec is already registered for tuple_test.priority"},
+		{unregistered{}, tuple.Codec{Code: 0x41, Encode: encode, Decode: decode}, "user type code 41 is already registered for tuple_test.amount"},
+	}
+
+	for _, test := range tests {
+		e := tuple.RegisterCodec(test.value, test.c)
+		if e == nil {
+			t.Errorf("registered codec for %T", test.value)
+		} else if e.Error() != test.err {
+			t.Errorf("registering codec for %T: got error %q, expected %q", test.value, e, test.err)
+		}
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	tup := tuple.Tuple{amount{5}, tuple.Tuple{amount{7}, nil, priority(3)}, priority(2)}
+
+	packed := tup.Pack()
+	expected := []byte{0x41, 0x15, 0x05, 0x05, 0x41, 0x15, 0x07, 0x00, 0xFF, 0x15, 0x03, 0x00, 0x15, 0x02}
+	if !bytes.Equal(packed, expected) {
+		t.Fatalf("packed %v as %x, expected %x", tup, packed, expected)
+	}
+
+	u, e := tuple.Unpack(packed)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// Values encoded without a user type code are unpacked as their encodings
+	want := tuple.Tuple{amount{5}, tuple.Tuple{amount{7}, nil, int64(3)}, int64(2)}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("unpacked %v, expected %v", u, want)
+	}
+
+	var p tuple.Packer
+	if e := p.Append(tup); e != nil || !bytes.Equal(p.Bytes(), packed) {
+		t.Errorf("Packer produced %x (error %v)", p.Bytes(), e)
+	}
+
+	r := tuple.NewTupleReader(packed)
+	if !r.Next() || r.Type() != tuple.ElementTypeUser {
+		t.Fatalf("reader did not find a user type element (error %v)", r.Err())
+	}
+	if el, e := r.Element(); e != nil || el != (amount{5}) {
+		t.Errorf("reader returned %v (error %v)", el, e)
+	}
+
+	var s struct {
+		A amount
+		P priority
+		Q *priority
+	}
+	b, e := tuple.Marshal(struct {
+		A amount
+		P priority
+		Q priority
+	}{amount{1}, 2, 3})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !bytes.Equal(b, tuple.Tuple{amount{1}, int64(2), int64(3)}.Pack()) {
+		t.Errorf("marshaled %x", b)
+	}
+	if e := tuple.Unmarshal(b, &s); e != nil {
+		t.Fatal(e)
+	}
+	if s.A != (amount{1}) || s.P != 2 || s.Q == nil || *s.Q != 3 {
+		t.Errorf("unmarshaled %+v", s)
+	}
+
+	if e := tuple.Unmarshal(tuple.Tuple{amount{1}, "x", nil}.Pack(), &s); e == nil || !strings.Contains(e.Error(), "invalid priority x") {
+		t.Errorf("unmarshaling an invalid priority returned %v", e)
+	}
+}
+
+func TestCodecOrdering(t *testing.T) {
+	tests := []tuple.Tuple{
+		{tuple.Versionstamp{}},
+		{amount{0}},
+		{amount{1}},
+		{amount{1}, priority(-1)},
+		{amount{1}, int64(0)},
+		{amount{1}, priority(1)},
+		{amount{1}, int64(2)},
+		{amount{1000}},
+	}
+
+	for i := range tests {
+		for j := range tests {
+			c := tuple.Compare(tests[i], tests[j])
+			pc := bytes.Compare(tests[i].Pack(), tests[j].Pack())
+			if c != pc || pc != compare(i < j, i > j) {
+				t.Errorf("comparing %v and %v: Compare returned %d, packed comparison is %d", tests[i], tests[j], c, pc)
+			}
+		}
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	tests := []struct {
+		enc []byte
+		err string
+	}{
+		{[]byte{0x41}, "truncated user type element with typecode 41 at offset 0"},
+		{[]byte{0x41, 0x15}, "truncated tuple element with typecode 15 at offset 1 (2 bytes required, 1 available)"},
+		{[]byte{0x41, 0x13, 0xFE}, "invalid tuple element with typecode 41 at offset 0: cannot decode tuple_test.amount: invalid amount -1"},
+		{[]byte{0x4F, 0x14}, "unknown tuple element typecode 4f at offset 0"},
+	}
+
+	for _, test := range tests {
+		_, e := tuple.Unpack(test.enc)
+		if e == nil {
+			t.Errorf("unpacked invalid encoding %x", test.enc)
+		} else if e.Error() != test.err {
+			t.Errorf("unpacking %x: got error %q, expected %q", test.enc, e, test.err)
+		}
+	}
+
+	if _, e := (tuple.Tuple{int64(1), broken{}}).PackWithError(); e == nil || e.Error() != "unencodable element at index 1: cannot encode tuple_test.broken: unencodable" {
+		t.Errorf("packing an unencodable value returned %v", e)
+	}
+}
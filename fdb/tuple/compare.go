@@ -0,0 +1,192 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "github.com/FoundationDB/fdb-go/fdb"
+import "bytes"
+import "math"
+import "math/big"
+import "strings"
+
+// Compare returns an integer comparing two tuples in the order of their packed
+// representations: 0 if a and b pack to the same bytes, -1 if a sorts before b,
+// and +1 if a sorts after b. Compare does not pack either tuple, but will panic
+// in the same circumstances as Pack.
+//
+// As in the packed representation, elements of different types are ordered by
+// type (nil, byte strings, strings, nested tuples, integers, float32s,
+// float64s, booleans, UUIDs, Versionstamps and then values of registered types
+// by user type code), integers of all sizes are ordered by value, and a tuple
+// sorts before any longer tuple of which it is a prefix.
+func Compare(a, b Tuple) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareElements(a, i, b, i); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// customOrder is returned by typeOrder for values of registered types, which
+// must be lowered (by lowerElement) before they can be ordered.
+const customOrder = 0xFF
+
+// typeOrder returns the type code that determines the order of an element
+// relative to elements of other types. Integers of all sizes share a code.
+func typeOrder(t Tuple, i int) byte {
+	switch el := t[i].(type) {
+	case nil:
+		return nilCode
+	case []byte:
+		return bytesCode
+	case string:
+		return stringCode
+	case Tuple:
+		return nestedCode
+	case int64, int, uint64, uint, *big.Int, big.Int:
+		return intZeroCode
+	case float32:
+		return floatCode
+	case float64:
+		return doubleCode
+	case bool:
+		return falseCode
+	case UUID:
+		return uuidCode
+	case Versionstamp:
+		return versionstampCode
+	case fdb.KeyConvertible:
+		return bytesCode
+	case userElement:
+		return el.code
+	}
+	return customOrder
+}
+
+func compareElements(a Tuple, i int, b Tuple, j int) int {
+	ta, tb := typeOrder(a, i), typeOrder(b, j)
+	if ta == customOrder || tb == customOrder {
+		if ta == customOrder {
+			a, i = lowerElement(a, i), 0
+		}
+		if tb == customOrder {
+			b, j = lowerElement(b, j), 0
+		}
+		return compareElements(a, i, b, j)
+	}
+	if ta != tb {
+		return compareInts(int(ta), int(tb))
+	}
+
+	switch ta {
+	case nilCode:
+		return 0
+	case bytesCode:
+		return bytes.Compare(elementBytes(a[i]), elementBytes(b[j]))
+	case stringCode:
+		return strings.Compare(a[i].(string), b[j].(string))
+	case nestedCode:
+		return Compare(a[i].(Tuple), b[j].(Tuple))
+	case intZeroCode:
+		x, xok := toInt64(a[i])
+		y, yok := toInt64(b[j])
+		if xok && yok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+		return bigIntValue(a[i]).Cmp(bigIntValue(b[j]))
+	case floatCode:
+		return compareFloatBits(uint64(math.Float32bits(a[i].(float32))), uint64(math.Float32bits(b[j].(float32))), 32)
+	case doubleCode:
+		return compareFloatBits(math.Float64bits(a[i].(float64)), math.Float64bits(b[j].(float64)), 64)
+	case falseCode:
+		x, y := a[i].(bool), b[j].(bool)
+		switch {
+		case x == y:
+			return 0
+		case y:
+			return -1
+		}
+		return 1
+	case uuidCode:
+		x, y := a[i].(UUID), b[j].(UUID)
+		return bytes.Compare(x[:], y[:])
+	case versionstampCode:
+		return bytes.Compare(a[i].(Versionstamp).Bytes(), b[j].(Versionstamp).Bytes())
+	}
+
+	// Values of registered types with the same user type code
+	return compareElements(Tuple{a[i].(userElement).el}, 0, Tuple{b[j].(userElement).el}, 0)
+}
+
+func elementBytes(el TupleElement) []byte {
+	if b, ok := el.([]byte); ok {
+		return b
+	}
+	return el.(fdb.KeyConvertible).FDBKey()
+}
+
+func bigIntValue(el TupleElement) *big.Int {
+	if i, ok := el.(big.Int); ok {
+		return &i
+	}
+	i, _ := toBigInt(el)
+	return i
+}
+
+// compareFloatBits compares the IEEE 754 representations of two floating
+// point numbers of the given size in the order of their tuple encodings, which
+// is the order of the bits after flipping the sign bit of non-negative numbers
+// and all bits of negative ones.
+func compareFloatBits(x, y uint64, size uint) int {
+	sign := uint64(1) << (size - 1)
+	adjust := func(u uint64) uint64 {
+		if u&sign != 0 {
+			return ^u & (sign<<1 - 1)
+		}
+		return u | sign
+	}
+
+	x, y = adjust(x), adjust(y)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,536 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "github.com/FoundationDB/fdb-go/fdb"
+import "bytes"
+import "encoding/hex"
+import "fmt"
+import "math"
+import "math/big"
+import "strconv"
+import "strings"
+import "unicode/utf8"
+
+// String returns a human-readable representation of the tuple in the style of
+// the Python binding, such as ("user", 42, b"\x00", None). Strings are quoted as
+// by strconv.Quote (except that any bytes that are not valid UTF-8 are written
+// as \udc80 to \udcff, as by the surrogateescape error handler of Python), byte
+// strings are written as b"..." with non-printable bytes escaped, float32s as
+// SingleFloat(...), and UUIDs and Versionstamps as
+// UUID("...") and Versionstamp(..., ...). The result may be converted back to
+// an equivalent Tuple with Parse, unless the tuple contains values of types
+// registered with RegisterCodec (which are formatted as by fmt.Sprint).
+func (t Tuple) String() string {
+	var buf bytes.Buffer
+	t.format(&buf)
+	return buf.String()
+}
+
+func (t Tuple) format(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, el := range t {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		formatElement(buf, el)
+	}
+	if len(t) == 1 {
+		buf.WriteByte(',')
+	}
+	buf.WriteByte(')')
+}
+
+func formatElement(buf *bytes.Buffer, el TupleElement) {
+	switch el := el.(type) {
+	case nil:
+		buf.WriteString("None")
+	case bool:
+		if el {
+			buf.WriteString("True")
+		} else {
+			buf.WriteString("False")
+		}
+	case int64, int, uint64, uint, *big.Int:
+		fmt.Fprint(buf, el)
+	case big.Int:
+		buf.WriteString(el.String())
+	case float32:
+		buf.WriteString("SingleFloat(")
+		buf.WriteString(formatFloat(float64(el), 32))
+		buf.WriteByte(')')
+	case float64:
+		buf.WriteString(formatFloat(el, 64))
+	case string:
+		formatString(buf, el)
+	case []byte:
+		formatBytes(buf, el)
+	case Tuple:
+		el.format(buf)
+	case UUID:
+		fmt.Fprintf(buf, "UUID(%q)", el.String())
+	case Versionstamp:
+		buf.WriteString(el.String())
+	case fdb.KeyConvertible:
+		formatBytes(buf, el.FDBKey())
+	default:
+		fmt.Fprintf(buf, "%v", el)
+	}
+}
+
+// formatFloat formats f so that it is always read back as a floating point
+// number.
+func formatFloat(f float64, size int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, size)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatString writes s quoted as by strconv.Quote, except that bytes that are
+// not valid UTF-8 are written as \udc80 to \udcff rather than as \x escapes,
+// which Parse reads as code points.
+func formatString(buf *bytes.Buffer, s string) {
+	if utf8.ValidString(s) {
+		buf.WriteString(strconv.Quote(s))
+		return
+	}
+
+	buf.WriteByte('"')
+	for len(s) > 0 {
+		r, n := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && n == 1 {
+			fmt.Fprintf(buf, `\udc%02x`, s[0])
+		} else {
+			q := strconv.Quote(s[:n])
+			buf.WriteString(q[1:len(q)-1])
+		}
+		s = s[n:]
+	}
+	buf.WriteByte('"')
+}
+
+func formatBytes(buf *bytes.Buffer, b []byte) {
+	buf.WriteString(`b"`)
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case 0x20 <= c && c < 0x7F:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(buf, `\x%02x`, c)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Parse returns the Tuple represented by s, which must be in the format
+// produced by (Tuple).String. Parse also accepts single-quoted strings and byte
+// strings, as written by the Python binding, and insignificant whitespace. As
+// in Python, a \x escape in a string denotes a code point (so that "\xe9" is
+// "é"), while in a byte string it denotes a byte; the escapes \udc80 to \udcff
+// in a string denote the bytes 0x80 to 0xff that are not valid UTF-8.
+//
+// Integers are parsed as int64, or as uint64 or *big.Int if they do not fit
+// in an int64 (as by Unpack), and numbers containing a decimal point or
+// exponent (as well as inf, -inf and nan) as float64.
+func Parse(s string) (Tuple, error) {
+	p := parser{s: s}
+
+	p.skipSpace()
+	t, e := p.parseTuple()
+	if e != nil {
+		return nil, e
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q after tuple", p.s[p.pos:])
+	}
+	return t, nil
+}
+
+// MustParse is like Parse but panics if s does not represent a tuple.
+func MustParse(s string) Tuple {
+	t, e := Parse(s)
+	if e != nil {
+		panic(e)
+	}
+	return t
+}
+
+type parser struct {
+	s string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid tuple at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(prefix string) error {
+	p.skipSpace()
+	if !p.consume(prefix) {
+		if p.pos == len(p.s) {
+			return p.errorf("expected %q, found end of input", prefix)
+		}
+		return p.errorf("expected %q", prefix)
+	}
+	return nil
+}
+
+func (p *parser) parseTuple() (Tuple, error) {
+	if e := p.expect("("); e != nil {
+		return nil, e
+	}
+
+	t := Tuple{}
+	for {
+		p.skipSpace()
+		if p.consume(")") {
+			return t, nil
+		}
+
+		el, e := p.parseElement()
+		if e != nil {
+			return nil, e
+		}
+		t = append(t, el)
+
+		p.skipSpace()
+		if p.consume(")") {
+			return t, nil
+		}
+		if e := p.expect(","); e != nil {
+			return nil, e
+		}
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func (p *parser) parseElement() (TupleElement, error) {
+	if p.pos == len(p.s) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '(':
+		return p.parseTuple()
+	case c == '"' || c == '\'':
+		b, e := p.parseQuoted(true)
+		return string(b), e
+	case c == 'b' && p.pos+1 < len(p.s) && (p.s[p.pos+1] == '"' || p.s[p.pos+1] == '\''):
+		p.pos++
+		return p.parseQuoted(false)
+	case c == '-' || c == '+' || c == '.' || '0' <= c && c <= '9':
+		return p.parseNumber()
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos]) {
+		p.pos++
+	}
+
+	switch ident := p.s[start:p.pos]; ident {
+	case "None":
+		return nil, nil
+	case "True":
+		return true, nil
+	case "False":
+		return false, nil
+	case "inf":
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	case "SingleFloat":
+		if e := p.expect("("); e != nil {
+			return nil, e
+		}
+		p.skipSpace()
+		f, e := p.parseNumber()
+		if e != nil {
+			return nil, e
+		}
+		if e := p.expect(")"); e != nil {
+			return nil, e
+		}
+		return toFloat32(f), nil
+	case "UUID":
+		if e := p.expect("("); e != nil {
+			return nil, e
+		}
+		p.skipSpace()
+		pos := p.pos
+		b, e := p.parseQuoted(true)
+		if e != nil {
+			return nil, e
+		}
+		h, e := hex.DecodeString(strings.Replace(string(b), "-", "", -1))
+		if e != nil || len(h) != 16 {
+			p.pos = pos
+			return nil, p.errorf("invalid UUID %q", b)
+		}
+		if e := p.expect(")"); e != nil {
+			return nil, e
+		}
+		var u UUID
+		copy(u[:], h)
+		return u, nil
+	case "Versionstamp":
+		return p.parseVersionstamp()
+	case "":
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	default:
+		p.pos = start
+		return nil, p.errorf("unknown identifier %q", ident)
+	}
+}
+
+func toFloat32(el TupleElement) float32 {
+	switch el := el.(type) {
+	case float64:
+		return float32(el)
+	case int64:
+		return float32(el)
+	case uint64:
+		return float32(el)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(el).Float32()
+		return f
+	}
+	return 0
+}
+
+func (p *parser) parseVersionstamp() (TupleElement, error) {
+	if e := p.expect("("); e != nil {
+		return nil, e
+	}
+	p.skipSpace()
+
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos]) {
+		p.pos++
+	}
+	h, e := hex.DecodeString(p.s[start:p.pos])
+	if e != nil || len(h) != 10 {
+		p.pos = start
+		return nil, p.errorf("invalid transaction version %q", p.s[start:p.pos])
+	}
+
+	if e := p.expect(","); e != nil {
+		return nil, e
+	}
+	p.skipSpace()
+
+	uvpos := p.pos
+	uv, e := p.parseNumber()
+	if e != nil {
+		return nil, e
+	}
+	n, ok := uv.(int64)
+	if !ok || n < 0 || n > math.MaxUint16 {
+		p.pos = uvpos
+		return nil, p.errorf("invalid user version %v", uv)
+	}
+
+	if e := p.expect(")"); e != nil {
+		return nil, e
+	}
+
+	var v Versionstamp
+	copy(v.TransactionVersion[:], h)
+	v.UserVersion = uint16(n)
+	return v, nil
+}
+
+func (p *parser) parseNumber() (TupleElement, error) {
+	start := p.pos
+	neg := false
+	if p.pos < len(p.s) && (p.s[p.pos] == '-' || p.s[p.pos] == '+') {
+		neg = p.s[p.pos] == '-'
+		p.pos++
+	}
+
+	if p.consume("inf") {
+		if neg {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	}
+	if p.consume("nan") {
+		return math.NaN(), nil
+	}
+
+	float := false
+scan:
+	for ; p.pos < len(p.s); p.pos++ {
+		switch c := p.s[p.pos]; {
+		case '0' <= c && c <= '9':
+		case c == '.' || c == 'e' || c == 'E':
+			float = true
+		case (c == '-' || c == '+') && (p.s[p.pos-1] == 'e' || p.s[p.pos-1] == 'E'):
+		default:
+			break scan
+		}
+	}
+
+	text := p.s[start:p.pos]
+	if float {
+		f, e := strconv.ParseFloat(text, 64)
+		if e != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", text)
+		}
+		return f, nil
+	}
+
+	i, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", text)
+	}
+	switch {
+	case i.IsInt64():
+		return i.Int64(), nil
+	case i.IsUint64():
+		return i.Uint64(), nil
+	}
+	return i, nil
+}
+
+// parseQuoted parses a quoted string, in which the escape sequences of Go and
+// Python string literals (other than octal escapes) may be used. Unicode
+// escapes are permitted only if unicode is true, in which case \x escapes also
+// denote code points, and \udc80 to \udcff single bytes.
+func (p *parser) parseQuoted(unicode bool) ([]byte, error) {
+	quote := p.s[p.pos]
+	start := p.pos
+	p.pos++
+
+	var b []byte
+	for {
+		if p.pos == len(p.s) {
+			p.pos = start
+			return nil, p.errorf("unterminated string")
+		}
+
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b, nil
+		case c != '\\':
+			b = append(b, c)
+			p.pos++
+			continue
+		}
+
+		esc := p.pos
+		p.pos++
+		if p.pos == len(p.s) {
+			continue
+		}
+
+		c = p.s[p.pos]
+		p.pos++
+		switch c {
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '\\', '\'', '"':
+			b = append(b, c)
+		case 'x', 'u', 'U':
+			n := 2
+			switch c {
+			case 'u':
+				n = 4
+			case 'U':
+				n = 8
+			}
+			if c != 'x' && !unicode || p.pos+n > len(p.s) {
+				p.pos = esc
+				return nil, p.errorf("invalid escape sequence")
+			}
+			v, e := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+			escaped := 0xDC80 <= v && v <= 0xDCFF
+			if e != nil || c != 'x' && !utf8.ValidRune(rune(v)) && !escaped {
+				p.pos = esc
+				return nil, p.errorf("invalid escape sequence %q", p.s[esc:p.pos+n])
+			}
+			p.pos += n
+			if !unicode || escaped {
+				b = append(b, byte(v))
+			} else {
+				b = utf8.AppendRune(b, rune(v))
+			}
+		default:
+			p.pos = esc
+			return nil, p.errorf("invalid escape sequence %q", p.s[esc:esc+2])
+		}
+	}
+}
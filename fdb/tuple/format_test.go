@@ -0,0 +1,116 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func ExampleParse() {
+	t, e := tuple.Parse(`("user", 42, b"\x00", (None, True), SingleFloat(1.5), -2.0)`)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Printf("%d elements: %v\n", len(t), t)
+
+	// Output:
+	// 6 elements: ("user", 42, b"\x00", (None, True), SingleFloat(1.5), -2.0)
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		t tuple.Tuple
+		s string
+	}{
+		{tuple.Tuple{}, "()"},
+		{tuple.Tuple{nil}, "(None,)"},
+		{tuple.Tuple{"a\"b\n", []byte("\x00\xff'\"\\z")}, `("a\"b\n", b"\x00\xff'\"\\z")`},
+		{tuple.Tuple{"\xff\x00é\u0085"}, `("\udcff\x00é\u0085",)`},
+		{tuple.Tuple{int64(-1), uint64(math.MaxUint64), new(big.Int).Lsh(big.NewInt(1), 70)}, "(-1, 18446744073709551615, 1180591620717411303424)"},
+		{tuple.Tuple{1.0, 1e100, math.Inf(-1), math.NaN(), float32(0.1)}, "(1.0, 1e+100, -inf, nan, SingleFloat(0.1))"},
+		{tuple.Tuple{tuple.Tuple{tuple.Tuple{}}, false}, "(((),), False)"},
+		{tuple.Tuple{tuple.UUID{0: 0xab, 15: 0xcd}}, `(UUID("ab000000-0000-0000-0000-0000000000cd"),)`},
+		{tuple.Tuple{tuple.Versionstamp{[10]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 11}}, "(Versionstamp(0102030405060708090a, 11),)"},
+	}
+
+	for _, test := range tests {
+		if s := test.t.String(); s != test.s {
+			t.Errorf("formatted %#v as %s, expected %s", test.t, s, test.s)
+		}
+
+		p, e := tuple.Parse(test.s)
+		if e != nil {
+			t.Errorf("unable to parse %s: %v", test.s, e)
+			continue
+		}
+		if s := p.String(); s != test.s {
+			t.Errorf("parsed %s as %s", test.s, s)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s string
+		t string
+	}{
+		{" ( 'py' , b'\\x01' , ) ", `("py", b"\x01")`},
+		{`("é", "\U0001F600")`, `("é", "😀")`},
+		{`('caf\xe9', "\x85", b"\xe9")`, `("café", "\u0085", b"\xe9")`},
+		{"(1e3, -0.5, +7, SingleFloat(2), SingleFloat(-inf))", "(1000.0, -0.5, 7, SingleFloat(2.0), SingleFloat(-inf))"},
+		{"(-9223372036854775809,)", "(-9223372036854775809,)"},
+	}
+
+	for _, test := range tests {
+		p, e := tuple.Parse(test.s)
+		if e != nil {
+			t.Errorf("unable to parse %s: %v", test.s, e)
+		} else if s := p.String(); s != test.t {
+			t.Errorf("parsed %s as %s, expected %s", test.s, s, test.t)
+		}
+	}
+
+	errors := []struct {
+		s string
+		err string
+	}{
+		{"", `invalid tuple at offset 0: expected "(", found end of input`},
+		{"(1", `invalid tuple at offset 2: expected ",", found end of input`},
+		{"(1) x", `invalid tuple at offset 4: unexpected "x" after tuple`},
+		{"(foo)", `invalid tuple at offset 1: unknown identifier "foo"`},
+		{`("abc)`, `invalid tuple at offset 1: unterminated string`},
+		{`(b"\u00e9")`, `invalid tuple at offset 3: invalid escape sequence`},
+		{`(UUID("xyz"),)`, `invalid tuple at offset 6: invalid UUID "xyz"`},
+		{"(1.2.3,)", `invalid tuple at offset 1: invalid number "1.2.3"`},
+	}
+
+	for _, test := range errors {
+		if _, e := tuple.Parse(test.s); e == nil || e.Error() != test.err {
+			t.Errorf("parsing %q returned error %v, expected %s", test.s, e, test.err)
+		}
+	}
+}
@@ -0,0 +1,647 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "github.com/FoundationDB/fdb-go/fdb"
+import "fmt"
+import "math"
+import "math/big"
+import "reflect"
+import "sort"
+import "strconv"
+import "sync"
+
+// Marshal returns the packed tuple encoding of v, which must be a struct or a
+// pointer to a struct. Each exported field of the struct becomes one element
+// of the tuple, and the field tag "fdb" controls how:
+//
+//     // Field is the first element of the tuple
+//     Field int `fdb:"1"`
+//
+//     // Field is omitted
+//     Field int `fdb:"-"`
+//
+// Fields with numeric tags are encoded in ascending order of their tags, which
+// must be unique. Untagged fields follow them, in the order in which they are
+// declared; a struct with no tags is therefore encoded in declaration order.
+//
+// Fields of the types listed for TupleElement are encoded as such. Other
+// integer types are encoded as integers, and strings, byte slices, booleans
+// and floating point numbers of named types as their underlying types. A
+// struct (other than Versionstamp or big.Int) or a slice or array (other than
+// a byte slice or UUID) is encoded as a nested tuple of its fields or
+// elements. A nil pointer or interface is encoded as nil, and any other
+// pointer or interface as the value to which it refers. Fields of types with a
+// registered Codec are encoded by the codec.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot marshal %T as a tuple (not a struct)", v)
+	}
+
+	t, e := structToTuple(rv)
+	if e != nil {
+		return nil, e
+	}
+	return t.PackWithError()
+}
+
+// Unmarshal decodes the packed tuple b into the struct to which v points,
+// following the field order described for Marshal. The tuple must have exactly
+// as many elements as the struct has fields to decode.
+//
+// Integer elements may be stored in fields of any integer type that can
+// represent their values, and float32 elements in float64 fields. A nil
+// element sets a pointer, interface, slice or map field to nil; it may not be
+// stored in a field of any other type. Fields of types with a registered Codec
+// are decoded by the codec. Unmarshal returns an error, and leaves the
+// remaining fields unmodified, at the first element that cannot be stored in
+// its field.
+func Unmarshal(b []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal a tuple into %T (not a non-nil pointer to a struct)", v)
+	}
+
+	t, e := Unpack(b)
+	if e != nil {
+		return e
+	}
+	return tupleToStruct(t, rv.Elem())
+}
+
+type fieldInfo struct {
+	name string
+	index int
+}
+
+var fieldCache sync.Map // map[reflect.Type][]fieldInfo
+
+// structFields returns the fields of a struct type in tuple order.
+func structFields(st reflect.Type) ([]fieldInfo, error) {
+	if fi, ok := fieldCache.Load(st); ok {
+		return fi.([]fieldInfo), nil
+	}
+
+	type taggedField struct {
+		fieldInfo
+		pos int
+	}
+
+	var tagged []taggedField
+	var untagged []fieldInfo
+	seen := make(map[int]string)
+
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("fdb")
+		switch {
+		case tag == "-":
+			continue
+		case !ok || tag == "":
+			untagged = append(untagged, fieldInfo{f.Name, i})
+			continue
+		}
+
+		pos, e := strconv.Atoi(tag)
+		if e != nil {
+			return nil, fmt.Errorf("invalid fdb tag %q on field %s of %s", tag, f.Name, st)
+		}
+		if other, dup := seen[pos]; dup {
+			return nil, fmt.Errorf("fields %s and %s of %s have the same fdb tag %d", other, f.Name, st, pos)
+		}
+		seen[pos] = f.Name
+		tagged = append(tagged, taggedField{fieldInfo{f.Name, i}, pos})
+	}
+
+	sort.Slice(tagged, func(i, j int) bool { return tagged[i].pos < tagged[j].pos })
+
+	fields := make([]fieldInfo, 0, len(tagged)+len(untagged))
+	for _, f := range tagged {
+		fields = append(fields, f.fieldInfo)
+	}
+	fields = append(fields, untagged...)
+
+	fieldCache.Store(st, fields)
+	return fields, nil
+}
+
+var (
+	versionstampType = reflect.TypeOf(Versionstamp{})
+	uuidType = reflect.TypeOf(UUID{})
+	tupleType = reflect.TypeOf(Tuple{})
+	bigIntType = reflect.TypeOf(big.Int{})
+	bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
+)
+
+func structToTuple(rv reflect.Value) (Tuple, error) {
+	fields, e := structFields(rv.Type())
+	if e != nil {
+		return nil, e
+	}
+
+	t := make(Tuple, len(fields))
+	for i, f := range fields {
+		t[i], e = marshalValue(rv.Field(f.index))
+		if e != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", f.name, rv.Type(), e)
+		}
+	}
+	return t, nil
+}
+
+func marshalValue(v reflect.Value) (TupleElement, error) {
+	if codecForType(v.Type()) != nil {
+		return v.Interface(), nil
+	}
+
+	switch v.Type() {
+	case versionstampType, uuidType, tupleType:
+		return v.Interface(), nil
+	case bigIntType:
+		i := v.Interface().(big.Int)
+		return &i, nil
+	case bigIntPtrType:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32:
+		return float32(v.Float()), nil
+	case reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+		fallthrough
+	case reflect.Array:
+		if v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		t := make(Tuple, v.Len())
+		for i := range t {
+			var e error
+			if t[i], e = marshalValue(v.Index(i)); e != nil {
+				return nil, fmt.Errorf("element %d: %v", i, e)
+			}
+		}
+		return t, nil
+	case reflect.Struct:
+		return structToTuple(v)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return marshalValue(v.Elem())
+	}
+
+	return nil, fmt.Errorf("cannot marshal a value of type %s", v.Type())
+}
+
+func tupleToStruct(t Tuple, rv reflect.Value) error {
+	fields, e := structFields(rv.Type())
+	if e != nil {
+		return e
+	}
+	if len(t) != len(fields) {
+		return fmt.Errorf("cannot unmarshal a tuple of %d elements into %s (%d fields)", len(t), rv.Type(), len(fields))
+	}
+
+	for i, f := range fields {
+		if e := unmarshalValue(t[i], rv.Field(f.index)); e != nil {
+			return fmt.Errorf("field %s of %s: %v", f.name, rv.Type(), e)
+		}
+	}
+	return nil
+}
+
+func mismatch(el TupleElement, v reflect.Value) error {
+	return fmt.Errorf("cannot unmarshal %T into a value of type %s", el, v.Type())
+}
+
+func unmarshalValue(el TupleElement, v reflect.Value) error {
+	if c := codecForType(v.Type()); c != nil {
+		if reflect.TypeOf(el) != v.Type() {
+			var e error
+			if el, e = c.decode(el); e != nil {
+				return e
+			}
+		}
+		v.Set(reflect.ValueOf(el))
+		return nil
+	}
+
+	if el == nil {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return mismatch(el, v)
+	}
+
+	switch v.Type() {
+	case versionstampType, uuidType:
+		if reflect.TypeOf(el) != v.Type() {
+			return mismatch(el, v)
+		}
+		v.Set(reflect.ValueOf(el))
+		return nil
+	case bigIntType, bigIntPtrType:
+		i, ok := toBigInt(el)
+		if !ok {
+			return mismatch(el, v)
+		}
+		if v.Kind() == reflect.Ptr {
+			v.Set(reflect.ValueOf(i))
+		} else {
+			v.Set(reflect.ValueOf(i).Elem())
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := el.(bool)
+		if !ok {
+			return mismatch(el, v)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(el)
+		if !ok {
+			return mismatch(el, v)
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("cannot unmarshal %v (%T) into a value of type %s", el, el, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, ok := toUint64(el)
+		if !ok {
+			return mismatch(el, v)
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("cannot unmarshal %v (%T) into a value of type %s", el, el, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Float32:
+		f, ok := el.(float32)
+		if !ok {
+			return mismatch(el, v)
+		}
+		v.SetFloat(float64(f))
+	case reflect.Float64:
+		f, ok := toFloat64(el)
+		if !ok {
+			return mismatch(el, v)
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, ok := el.(string)
+		if !ok {
+			return mismatch(el, v)
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, ok := el.([]byte)
+			if !ok {
+				return mismatch(el, v)
+			}
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		t, ok := el.(Tuple)
+		if !ok {
+			return mismatch(el, v)
+		}
+		s := reflect.MakeSlice(v.Type(), len(t), len(t))
+		for i := range t {
+			if e := unmarshalValue(t[i], s.Index(i)); e != nil {
+				return fmt.Errorf("element %d: %v", i, e)
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, ok := el.([]byte)
+			if !ok || len(b) != v.Len() {
+				return mismatch(el, v)
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		t, ok := el.(Tuple)
+		if !ok || len(t) != v.Len() {
+			return mismatch(el, v)
+		}
+		for i := range t {
+			if e := unmarshalValue(t[i], v.Index(i)); e != nil {
+				return fmt.Errorf("element %d: %v", i, e)
+			}
+		}
+	case reflect.Struct:
+		t, ok := el.(Tuple)
+		if !ok {
+			return mismatch(el, v)
+		}
+		return tupleToStruct(t, v)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if e := unmarshalValue(el, p.Elem()); e != nil {
+			return e
+		}
+		v.Set(p)
+	case reflect.Interface:
+		ev := reflect.ValueOf(el)
+		if !ev.Type().AssignableTo(v.Type()) {
+			return mismatch(el, v)
+		}
+		v.Set(ev)
+	default:
+		return mismatch(el, v)
+	}
+
+	return nil
+}
+
+func toInt64(el TupleElement) (int64, bool) {
+	switch el := el.(type) {
+	case int64:
+		return el, true
+	case int:
+		return int64(el), true
+	case uint64:
+		return int64(el), el <= math.MaxInt64
+	case uint:
+		return int64(el), uint64(el) <= math.MaxInt64
+	case *big.Int:
+		return el.Int64(), el.IsInt64()
+	}
+	return 0, false
+}
+
+func toUint64(el TupleElement) (uint64, bool) {
+	switch el := el.(type) {
+	case int64:
+		return uint64(el), el >= 0
+	case int:
+		return uint64(el), el >= 0
+	case uint64:
+		return el, true
+	case uint:
+		return uint64(el), true
+	case *big.Int:
+		return el.Uint64(), el.IsUint64()
+	}
+	return 0, false
+}
+
+func toBigInt(el TupleElement) (*big.Int, bool) {
+	switch el := el.(type) {
+	case int64:
+		return big.NewInt(el), true
+	case int:
+		return big.NewInt(int64(el)), true
+	case uint64:
+		return new(big.Int).SetUint64(el), true
+	case uint:
+		return new(big.Int).SetUint64(uint64(el)), true
+	case *big.Int:
+		return new(big.Int).Set(el), true
+	}
+	return nil, false
+}
+
+func toFloat64(el TupleElement) (float64, bool) {
+	switch el := el.(type) {
+	case float64:
+		return el, true
+	case float32:
+		return float64(el), true
+	}
+	return 0, false
+}
+
+// element returns the element of the tuple at index i, or an error if i is out
+// of range.
+func (t Tuple) element(i int) (TupleElement, error) {
+	if i < 0 || i >= len(t) {
+		return nil, fmt.Errorf("tuple index %d out of range (length %d)", i, len(t))
+	}
+	return t[i], nil
+}
+
+func (t Tuple) typeError(i int, want string) error {
+	return fmt.Errorf("tuple element %d is %T, not %s", i, t[i], want)
+}
+
+// GetInt returns the element of the tuple at index i as an int64. It returns an
+// error if i is out of range, or if the element is not an integer that fits in
+// an int64.
+func (t Tuple) GetInt(i int) (int64, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return 0, e
+	}
+	v, ok := toInt64(el)
+	if !ok {
+		return 0, t.typeError(i, "an int64")
+	}
+	return v, nil
+}
+
+// GetUint returns the element of the tuple at index i as a uint64. It returns
+// an error if i is out of range, or if the element is not an integer that fits
+// in a uint64.
+func (t Tuple) GetUint(i int) (uint64, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return 0, e
+	}
+	v, ok := toUint64(el)
+	if !ok {
+		return 0, t.typeError(i, "a uint64")
+	}
+	return v, nil
+}
+
+// GetBigInt returns the element of the tuple at index i, which may be an
+// integer of any size, as a *big.Int. It returns an error if i is out of range
+// or the element is not an integer.
+func (t Tuple) GetBigInt(i int) (*big.Int, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return nil, e
+	}
+	v, ok := toBigInt(el)
+	if !ok {
+		return nil, t.typeError(i, "an integer")
+	}
+	return v, nil
+}
+
+// GetFloat32 returns the element of the tuple at index i, which must be a
+// float32.
+func (t Tuple) GetFloat32(i int) (float32, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return 0, e
+	}
+	v, ok := el.(float32)
+	if !ok {
+		return 0, t.typeError(i, "a float32")
+	}
+	return v, nil
+}
+
+// GetFloat64 returns the element of the tuple at index i, which must be a
+// float64 or float32, as a float64.
+func (t Tuple) GetFloat64(i int) (float64, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return 0, e
+	}
+	v, ok := toFloat64(el)
+	if !ok {
+		return 0, t.typeError(i, "a float64")
+	}
+	return v, nil
+}
+
+// GetString returns the element of the tuple at index i, which must be a
+// string.
+func (t Tuple) GetString(i int) (string, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return "", e
+	}
+	v, ok := el.(string)
+	if !ok {
+		return "", t.typeError(i, "a string")
+	}
+	return v, nil
+}
+
+// GetBytes returns the element of the tuple at index i, which must be a []byte
+// or fdb.KeyConvertible (other than a Tuple).
+func (t Tuple) GetBytes(i int) ([]byte, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return nil, e
+	}
+	switch v := el.(type) {
+	case []byte:
+		return v, nil
+	case Tuple:
+		// A Tuple is an fdb.KeyConvertible, but not a byte string
+	case fdb.KeyConvertible:
+		return []byte(v.FDBKey()), nil
+	}
+	return nil, t.typeError(i, "a []byte")
+}
+
+// GetBool returns the element of the tuple at index i, which must be a bool.
+func (t Tuple) GetBool(i int) (bool, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return false, e
+	}
+	v, ok := el.(bool)
+	if !ok {
+		return false, t.typeError(i, "a bool")
+	}
+	return v, nil
+}
+
+// GetUUID returns the element of the tuple at index i, which must be a UUID.
+func (t Tuple) GetUUID(i int) (UUID, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return UUID{}, e
+	}
+	v, ok := el.(UUID)
+	if !ok {
+		return UUID{}, t.typeError(i, "a UUID")
+	}
+	return v, nil
+}
+
+// GetVersionstamp returns the element of the tuple at index i, which must be a
+// Versionstamp.
+func (t Tuple) GetVersionstamp(i int) (Versionstamp, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return Versionstamp{}, e
+	}
+	v, ok := el.(Versionstamp)
+	if !ok {
+		return Versionstamp{}, t.typeError(i, "a Versionstamp")
+	}
+	return v, nil
+}
+
+// GetTuple returns the element of the tuple at index i, which must be a nested
+// Tuple.
+func (t Tuple) GetTuple(i int) (Tuple, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return nil, e
+	}
+	v, ok := el.(Tuple)
+	if !ok {
+		return nil, t.typeError(i, "a Tuple")
+	}
+	return v, nil
+}
+
+// IsNil returns true if the element of the tuple at index i is nil. It returns
+// an error if i is out of range.
+func (t Tuple) IsNil(i int) (bool, error) {
+	el, e := t.element(i)
+	if e != nil {
+		return false, e
+	}
+	return el == nil, nil
+}
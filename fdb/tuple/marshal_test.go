@@ -0,0 +1,197 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X, Y int32
+}
+
+type record struct {
+	Name string `fdb:"2"`
+	ID int64 `fdb:"1"`
+	Score float64 `fdb:"3"`
+	Tags []string
+	Location point
+	Parent *record
+	Key []byte
+	Ignored string `fdb:"-"`
+	private int
+}
+
+func ExampleMarshal() {
+	type user struct {
+		Group string `fdb:"1"`
+		ID int `fdb:"2"`
+		Email string `fdb:"-"`
+	}
+
+	b, e := tuple.Marshal(user{"admin", 42, "root@example.com"})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	t, _ := tuple.Unpack(b)
+	fmt.Println(t)
+
+	var u user
+	if e := tuple.Unmarshal(b, &u); e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Printf("%s %d %q\n", u.Group, u.ID, u.Email)
+
+	// Output:
+	// ("admin", 42)
+	// admin 42 ""
+}
+
+func TestMarshal(t *testing.T) {
+	r := record{
+		Name: "child",
+		ID: 7,
+		Score: 1.5,
+		Tags: []string{"a", "b"},
+		Location: point{-1, 2},
+		Parent: &record{Name: "parent", Tags: []string{}},
+		Key: []byte{0x00},
+		Ignored: "ignored",
+		private: 1,
+	}
+
+	b, e := tuple.Marshal(&r)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	u, e := tuple.Unpack(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := tuple.Tuple{
+		int64(7), "child", 1.5,
+		tuple.Tuple{"a", "b"},
+		tuple.Tuple{int64(-1), int64(2)},
+		tuple.Tuple{int64(0), "parent", 0.0, tuple.Tuple{}, tuple.Tuple{int64(0), int64(0)}, nil, []byte(nil)},
+		[]byte{0x00},
+	}
+	if !reflect.DeepEqual(u, expected) {
+		t.Fatalf("marshalled %v, expected %v", u, expected)
+	}
+
+	var r2 record
+	if e := tuple.Unmarshal(b, &r2); e != nil {
+		t.Fatal(e)
+	}
+	r.Ignored, r.private = "", 0
+	if !reflect.DeepEqual(r, r2) {
+		t.Errorf("unmarshalled %+v, expected %+v", r2, r)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var p point
+	tests := []struct {
+		t tuple.Tuple
+		v interface{}
+		err string
+	}{
+		{tuple.Tuple{int64(1)}, &p, "cannot unmarshal a tuple of 1 elements into tuple_test.point (2 fields)"},
+		{tuple.Tuple{int64(1), "2"}, &p, "field Y of tuple_test.point: cannot unmarshal string into a value of type int32"},
+		{tuple.Tuple{int64(1) << 40, int64(2)}, &p, "field X of tuple_test.point: cannot unmarshal 1099511627776 (int64) into a value of type int32"},
+		{tuple.Tuple{nil, int64(2)}, &p, "field X of tuple_test.point: cannot unmarshal <nil> into a value of type int32"},
+		{tuple.Tuple{}, p, "cannot unmarshal a tuple into tuple_test.point (not a non-nil pointer to a struct)"},
+	}
+
+	for _, test := range tests {
+		e := tuple.Unmarshal(test.t.Pack(), test.v)
+		if e == nil || e.Error() != test.err {
+			t.Errorf("unmarshalling %v: got error %v, expected %q", test.t, e, test.err)
+		}
+	}
+
+	type dup struct {
+		A int `fdb:"1"`
+		B int `fdb:"1"`
+	}
+	if _, e := tuple.Marshal(dup{}); e == nil || !strings.Contains(e.Error(), "same fdb tag") {
+		t.Errorf("marshalled struct with duplicate tags (error %v)", e)
+	}
+	if _, e := tuple.Marshal(struct{ C chan int }{}); e == nil {
+		t.Error("marshalled struct with channel field")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	tup := tuple.Tuple{int64(-1), uint64(1) << 63, "s", []byte("b"), float32(1), true, nil, tuple.Tuple{int64(1)}}
+
+	if i, e := tup.GetInt(0); e != nil || i != -1 {
+		t.Errorf("GetInt(0) returned %v, %v", i, e)
+	}
+	if _, e := tup.GetInt(1); e == nil {
+		t.Error("GetInt(1) did not return an error for an out of range value")
+	}
+	if u, e := tup.GetUint(1); e != nil || u != 1<<63 {
+		t.Errorf("GetUint(1) returned %v, %v", u, e)
+	}
+	if _, e := tup.GetUint(0); e == nil {
+		t.Error("GetUint(0) did not return an error for a negative value")
+	}
+	if b, e := tup.GetBigInt(1); e != nil || b.Cmp(new(big.Int).Lsh(big.NewInt(1), 63)) != 0 {
+		t.Errorf("GetBigInt(1) returned %v, %v", b, e)
+	}
+	if s, e := tup.GetString(2); e != nil || s != "s" {
+		t.Errorf("GetString(2) returned %v, %v", s, e)
+	}
+	if _, e := tup.GetString(3); e == nil || e.Error() != "tuple element 3 is []uint8, not a string" {
+		t.Errorf("GetString(3) returned error %v", e)
+	}
+	if b, e := tup.GetBytes(3); e != nil || string(b) != "b" {
+		t.Errorf("GetBytes(3) returned %v, %v", b, e)
+	}
+	if _, e := tup.GetBytes(7); e == nil {
+		t.Error("GetBytes(7) did not return an error for a nested tuple")
+	}
+	if f, e := tup.GetFloat64(4); e != nil || f != 1 {
+		t.Errorf("GetFloat64(4) returned %v, %v", f, e)
+	}
+	if b, e := tup.GetBool(5); e != nil || !b {
+		t.Errorf("GetBool(5) returned %v, %v", b, e)
+	}
+	if n, e := tup.IsNil(6); e != nil || !n {
+		t.Errorf("IsNil(6) returned %v, %v", n, e)
+	}
+	if n, e := tup.GetTuple(7); e != nil || len(n) != 1 {
+		t.Errorf("GetTuple(7) returned %v, %v", n, e)
+	}
+	if _, e := tup.GetInt(8); e == nil || e.Error() != "tuple index 8 out of range (length 8)" {
+		t.Errorf("GetInt(8) returned error %v", e)
+	}
+}
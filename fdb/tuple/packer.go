@@ -0,0 +1,172 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "errors"
+import "fmt"
+import "math/big"
+
+// A Packer encodes tuples into a buffer that is reused from one key to the
+// next. Once the buffer has grown to the size of the largest key, packing does
+// not allocate. Elements may be appended one at a time with the typed methods
+// (which also avoid converting each element to a TupleElement), or a Tuple at a
+// time with Append.
+//
+// The zero value for Packer is an empty buffer ready to use. For example, to
+// write a key for each of a number of users:
+//
+//	var p tuple.Packer
+//	for _, u := range users {
+//		p.Reset()
+//		p.AppendRaw(prefix)
+//		p.AppendString(u.Name)
+//		p.AppendInt(u.ID)
+//		tr.Set(fdb.Key(p.Bytes()), nil)
+//	}
+//
+// FoundationDB copies keys and values passed to it, so the buffer may be reused
+// as soon as Set returns.
+type Packer struct {
+	buf []byte
+}
+
+// Reset empties the Packer, retaining its buffer for reuse.
+func (p *Packer) Reset() {
+	p.buf = p.buf[:0]
+}
+
+// Bytes returns the bytes written to the Packer since it was last reset. The
+// slice aliases the Packer's buffer, and is valid only until the next call to a
+// method that modifies the Packer.
+func (p *Packer) Bytes() []byte {
+	return p.buf
+}
+
+// Len returns the number of bytes written to the Packer since it was last
+// reset.
+func (p *Packer) Len() int {
+	return len(p.buf)
+}
+
+// AppendRaw appends b without encoding it. It is typically used to write the
+// prefix of a subspace before the elements of a key.
+func (p *Packer) AppendRaw(b []byte) {
+	p.buf = append(p.buf, b...)
+}
+
+// AppendNil appends a nil tuple element.
+func (p *Packer) AppendNil() {
+	p.buf = append(p.buf, nilCode)
+}
+
+// AppendBytes appends a byte string tuple element.
+func (p *Packer) AppendBytes(b []byte) {
+	p.buf = appendBytes(p.buf, bytesCode, b)
+}
+
+// AppendString appends a unicode string tuple element.
+func (p *Packer) AppendString(s string) {
+	p.buf = appendString(p.buf, s)
+}
+
+// AppendInt appends an integer tuple element.
+func (p *Packer) AppendInt(i int64) {
+	p.buf = appendInt(p.buf, i)
+}
+
+// AppendUint appends an integer tuple element.
+func (p *Packer) AppendUint(u uint64) {
+	p.buf = appendUint(p.buf, u)
+}
+
+// AppendBigInt appends an integer tuple element, returning an error if i is
+// nil or its magnitude exceeds 255 bytes.
+func (p *Packer) AppendBigInt(i *big.Int) error {
+	if i == nil {
+		return errors.New("unencodable integer (nil *big.Int)")
+	}
+	b, e := appendBigInt(p.buf, i)
+	if e != nil {
+		return e
+	}
+	p.buf = b
+	return nil
+}
+
+// AppendFloat32 appends a single-precision floating point tuple element.
+func (p *Packer) AppendFloat32(f float32) {
+	p.buf = appendFloat(p.buf, f)
+}
+
+// AppendFloat64 appends a double-precision floating point tuple element.
+func (p *Packer) AppendFloat64(f float64) {
+	p.buf = appendDouble(p.buf, f)
+}
+
+// AppendBool appends a boolean tuple element.
+func (p *Packer) AppendBool(b bool) {
+	if b {
+		p.buf = append(p.buf, trueCode)
+	} else {
+		p.buf = append(p.buf, falseCode)
+	}
+}
+
+// AppendUUID appends a UUID tuple element.
+func (p *Packer) AppendUUID(u UUID) {
+	p.buf = append(p.buf, uuidCode)
+	p.buf = append(p.buf, u[:]...)
+}
+
+// AppendVersionstamp appends a Versionstamp tuple element, returning an error
+// if it is incomplete. (Keys containing an incomplete Versionstamp must be
+// built with (Tuple).PackWithVersionstamp.)
+func (p *Packer) AppendVersionstamp(v Versionstamp) error {
+	if !v.IsComplete() {
+		return errors.New("incomplete versionstamp (use PackWithVersionstamp)")
+	}
+	p.buf = appendVersionstamp(p.buf, v)
+	return nil
+}
+
+// AppendTuple appends t as a single, nested tuple element. If t cannot be
+// encoded, AppendTuple returns an error and leaves the Packer unchanged.
+func (p *Packer) AppendTuple(t Tuple) error {
+	b, e := t.appendTo(append(p.buf, nestedCode), nil, true)
+	if e != nil {
+		return fmt.Errorf("in nested tuple: %v", e)
+	}
+	p.buf = append(b, 0x00)
+	return nil
+}
+
+// Append appends each of the elements of t, exactly as (Tuple).Pack would
+// encode them. If t cannot be encoded, Append returns an error and leaves the
+// Packer unchanged.
+func (p *Packer) Append(t Tuple) error {
+	b, e := t.appendTo(p.buf, nil, false)
+	if e != nil {
+		return e
+	}
+	p.buf = b
+	return nil
+}
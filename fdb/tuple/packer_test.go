@@ -0,0 +1,159 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"math"
+	"testing"
+)
+
+var benchTuple = tuple.Tuple{"users", int64(1234567), "alice@example.com", []byte("a\x00b"), float64(1.5), true}
+
+func TestPacker(t *testing.T) {
+	var p tuple.Packer
+
+	for i := 0; i < 2; i++ {
+		p.Reset()
+		p.AppendRaw([]byte("prefix"))
+		p.AppendNil()
+		p.AppendBytes([]byte("a\x00b"))
+		p.AppendString("c\x00")
+		p.AppendInt(math.MinInt64)
+		p.AppendUint(math.MaxUint64)
+		if e := p.AppendBigInt(bigInt("0x10000000000000000")); e != nil {
+			t.Fatal(e)
+		}
+		p.AppendFloat32(-1)
+		p.AppendFloat64(2)
+		p.AppendBool(true)
+		p.AppendUUID(tuple.UUID{1})
+		if e := p.AppendVersionstamp(tuple.Versionstamp{UserVersion: 7}); e != nil {
+			t.Fatal(e)
+		}
+		if e := p.AppendTuple(tuple.Tuple{nil, "d"}); e != nil {
+			t.Fatal(e)
+		}
+		if e := p.Append(tuple.Tuple{int64(5), "e"}); e != nil {
+			t.Fatal(e)
+		}
+
+		expected := append([]byte("prefix"), tuple.Tuple{
+			nil, []byte("a\x00b"), "c\x00", int64(math.MinInt64), uint64(math.MaxUint64),
+			bigInt("0x10000000000000000"), float32(-1), float64(2), true, tuple.UUID{1},
+			tuple.Versionstamp{UserVersion: 7}, tuple.Tuple{nil, "d"}, int64(5), "e",
+		}.Pack()...)
+		if !bytes.Equal(p.Bytes(), expected) {
+			t.Fatalf("packed %x, expected %x", p.Bytes(), expected)
+		}
+		if p.Len() != len(expected) {
+			t.Fatalf("Len returned %d, expected %d", p.Len(), len(expected))
+		}
+	}
+
+	p.Reset()
+	p.AppendInt(1)
+	if e := p.Append(tuple.Tuple{int64(2), struct{}{}}); e == nil {
+		t.Error("appended unencodable tuple")
+	}
+	if e := p.AppendTuple(tuple.Tuple{tuple.IncompleteVersionstamp(0)}); e == nil {
+		t.Error("appended nested incomplete versionstamp")
+	}
+	if e := p.AppendVersionstamp(tuple.IncompleteVersionstamp(0)); e == nil {
+		t.Error("appended incomplete versionstamp")
+	}
+	if e := p.AppendBigInt(nil); e == nil {
+		t.Error("appended nil *big.Int")
+	}
+	if !bytes.Equal(p.Bytes(), tuple.Tuple{int64(1)}.Pack()) {
+		t.Errorf("failed appends modified packer: %x", p.Bytes())
+	}
+}
+
+func TestAppendPack(t *testing.T) {
+	prefix := []byte{0xFE, 0x00}
+	b := benchTuple.AppendPack(prefix)
+	if !bytes.Equal(b, append([]byte{0xFE, 0x00}, benchTuple.Pack()...)) {
+		t.Errorf("AppendPack produced %x", b)
+	}
+}
+
+func TestPackAllocations(t *testing.T) {
+	buf := make([]byte, 0, 256)
+	if n := testing.AllocsPerRun(100, func() {
+		buf = benchTuple.AppendPack(buf[:0])
+	}); n != 0 {
+		t.Errorf("AppendPack made %v allocations", n)
+	}
+
+	var p tuple.Packer
+	if n := testing.AllocsPerRun(100, func() {
+		p.Reset()
+		p.AppendString("users")
+		p.AppendInt(1234567)
+		p.AppendBytes([]byte("a\x00b"))
+	}); n != 0 {
+		t.Errorf("Packer made %v allocations", n)
+	}
+
+	packed := benchTuple.Pack()
+	if n := testing.AllocsPerRun(100, func() {
+		r := tuple.NewTupleReader(packed)
+		for r.Next() {
+			if r.Type() == tuple.ElementTypeInt {
+				r.GetInt()
+			}
+		}
+	}); n != 0 {
+		t.Errorf("TupleReader made %v allocations", n)
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchTuple.Pack()
+	}
+}
+
+func BenchmarkAppendPack(b *testing.B) {
+	b.ReportAllocs()
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf = benchTuple.AppendPack(buf[:0])
+	}
+}
+
+func BenchmarkPacker(b *testing.B) {
+	b.ReportAllocs()
+	var p tuple.Packer
+	for i := 0; i < b.N; i++ {
+		p.Reset()
+		p.AppendString("users")
+		p.AppendInt(1234567)
+		p.AppendString("alice@example.com")
+		p.AppendBytes([]byte("a\x00b"))
+		p.AppendFloat64(1.5)
+		p.AppendBool(true)
+	}
+}
@@ -0,0 +1,108 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "github.com/FoundationDB/fdb-go/fdb"
+import "bytes"
+import "fmt"
+
+// Range returns the range of keys encoding tuples that sort between begin and
+// end. Each bound is treated as a prefix: an inclusive bound includes both the
+// tuple itself and every longer tuple that begins with it, and an exclusive
+// bound excludes them all. For example, if begin is (100,) and end is (200,)
+// and both bounds are inclusive, the range contains (100,), (150, "a") and
+// (200, "b"), but not (99, "c") or (201,).
+//
+// Range returns an error if either tuple cannot be packed, or if begin sorts
+// after end.
+func Range(begin, end Tuple, beginInclusive, endInclusive bool) (fdb.KeyRange, error) {
+	b, e := begin.PackWithError()
+	if e != nil {
+		return fdb.KeyRange{}, fmt.Errorf("invalid range begin: %v", e)
+	}
+	n, e := end.PackWithError()
+	if e != nil {
+		return fdb.KeyRange{}, fmt.Errorf("invalid range end: %v", e)
+	}
+
+	if bytes.Compare(b, n) > 0 {
+		return fdb.KeyRange{}, fmt.Errorf("range begin %v is after range end %v", begin, end)
+	}
+
+	// The encoding of any tuple extending t is the encoding of t followed by a
+	// type code, which is never 0xFF, so appending 0xFF to the encoding of t
+	// yields a key after all of them.
+	if !beginInclusive {
+		b = append(b, 0xFF)
+	}
+	if endInclusive {
+		n = append(n, 0xFF)
+	}
+
+	// An exclusive begin may pass an end that extends it, leaving nothing
+	// between them.
+	if bytes.Compare(b, n) > 0 {
+		n = b
+	}
+
+	return fdb.KeyRange{Begin: fdb.Key(b), End: fdb.Key(n)}, nil
+}
+
+// StringPrefixRange returns the range of keys encoding tuples that begin with
+// the elements of t followed by a string that begins with prefix. (The range
+// does not include t itself.)
+func StringPrefixRange(t Tuple, prefix string) (fdb.KeyRange, error) {
+	b, e := t.PackWithError()
+	if e != nil {
+		return fdb.KeyRange{}, e
+	}
+	return stringRange(appendString(b, prefix)), nil
+}
+
+// BytesPrefixRange returns the range of keys encoding tuples that begin with
+// the elements of t followed by a byte string that begins with prefix. (The
+// range does not include t itself.)
+func BytesPrefixRange(t Tuple, prefix []byte) (fdb.KeyRange, error) {
+	b, e := t.PackWithError()
+	if e != nil {
+		return fdb.KeyRange{}, e
+	}
+	return stringRange(appendBytes(b, bytesCode, prefix)), nil
+}
+
+// stringRange returns the range of keys that begin with b, the encoding of a
+// string, less its terminating 0x00. The encoding of any string extending it
+// is b followed by more bytes, the first of which may be 0xFF (or 0x00 0xFF),
+// so the range ends at the first key that does not begin with b. Since b
+// contains a type code (which is not 0xFF), there always is one.
+func stringRange(b []byte) fdb.KeyRange {
+	b = b[:len(b)-1]
+
+	i := len(b) - 1
+	for b[i] == 0xFF {
+		i--
+	}
+	end := concat(b[:i+1])
+	end[i]++
+
+	return fdb.KeyRange{Begin: fdb.Key(b), End: fdb.Key(end)}
+}
@@ -0,0 +1,134 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// rangeElements are chosen to exercise the escaping of 0x00 and 0xFF within
+// strings, and the ordering of elements of different types.
+var rangeElements = []tuple.TupleElement{
+	nil, []byte(""), []byte("a"), []byte("a\x00"), []byte("a\x00b"), []byte("a\xff"), []byte("b"),
+	"", "a", "a\x00", "a\x00\xff", "a\xff", "ab", "b", int64(-1), int64(0), int64(100), int64(150),
+	int64(200), int64(201), tuple.Tuple{}, tuple.Tuple{nil}, float64(1.5), true,
+}
+
+// rangeTuples returns all tuples of up to two elements drawn from
+// rangeElements.
+func rangeTuples() []tuple.Tuple {
+	ts := []tuple.Tuple{{}}
+	for _, x := range rangeElements {
+		ts = append(ts, tuple.Tuple{x})
+		for _, y := range rangeElements {
+			ts = append(ts, tuple.Tuple{x, y})
+		}
+	}
+	return ts
+}
+
+func inRange(kr fdb.KeyRange, k []byte) bool {
+	b, e := kr.FDBRangeKeys()
+	return bytes.Compare(k, b.FDBKey()) >= 0 && bytes.Compare(k, e.FDBKey()) < 0
+}
+
+func extends(t, prefix tuple.Tuple) bool {
+	return len(t) >= len(prefix) && tuple.Compare(t[:len(prefix)], prefix) == 0
+}
+
+func TestRange(t *testing.T) {
+	bounds := []tuple.Tuple{{}, {int64(100)}, {int64(200)}, {"a"}, {"a\x00"}, {[]byte("a\xff")}, {int64(150), nil}}
+	tuples := rangeTuples()
+
+	for _, begin := range bounds {
+		for _, end := range bounds {
+			for _, bi := range []bool{false, true} {
+				for _, ei := range []bool{false, true} {
+					kr, e := tuple.Range(begin, end, bi, ei)
+
+					if inverted := tuple.Compare(begin, end) > 0; inverted != (e != nil) {
+						t.Errorf("Range(%v, %v, %v, %v) returned error %v", begin, end, bi, ei, e)
+					}
+					if e != nil {
+						continue
+					}
+
+					for _, tup := range tuples {
+						c, d := tuple.Compare(tup, begin), tuple.Compare(tup, end)
+						want := (bi && c >= 0 || !bi && c > 0 && !extends(tup, begin)) && (ei && (d <= 0 || extends(tup, end)) || !ei && d < 0)
+						if got := inRange(kr, tup.Pack()); got != want {
+							t.Errorf("Range(%v, %v, %v, %v) contains %v: %v, expected %v", begin, end, bi, ei, tup, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if _, e := tuple.Range(tuple.Tuple{int64(2)}, tuple.Tuple{int64(1)}, true, true); e == nil || e.Error() != "range begin (2,) is after range end (1,)" {
+		t.Errorf("inverted range returned error %v", e)
+	}
+	if _, e := tuple.Range(tuple.Tuple{struct{}{}}, tuple.Tuple{}, true, true); e == nil || !strings.HasPrefix(e.Error(), "invalid range begin: ") {
+		t.Errorf("unpackable range begin returned error %v", e)
+	}
+}
+
+func TestPrefixRanges(t *testing.T) {
+	tuples := rangeTuples()
+
+	for _, p := range []tuple.Tuple{{}, {int64(100)}} {
+		for _, prefix := range []string{"", "a", "a\x00", "a\xff", "b"} {
+			skr, e := tuple.StringPrefixRange(p, prefix)
+			if e != nil {
+				t.Fatal(e)
+			}
+			bkr, e := tuple.BytesPrefixRange(p, []byte(prefix))
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			for _, tup := range tuples {
+				var isString, isBytes bool
+				if len(tup) > len(p) && extends(tup, p) {
+					switch el := tup[len(p)].(type) {
+					case string:
+						isString = strings.HasPrefix(el, prefix)
+					case []byte:
+						isBytes = bytes.HasPrefix(el, []byte(prefix))
+					}
+				}
+
+				k := tup.Pack()
+				if got := inRange(skr, k); got != isString {
+					t.Errorf("StringPrefixRange(%v, %q) contains %v: %v, expected %v", p, prefix, tup, got, isString)
+				}
+				if got := inRange(bkr, k); got != isBytes {
+					t.Errorf("BytesPrefixRange(%v, %q) contains %v: %v, expected %v", p, prefix, tup, got, isBytes)
+				}
+			}
+		}
+	}
+}
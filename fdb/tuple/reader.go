@@ -0,0 +1,374 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple
+
+import "bytes"
+import "errors"
+import "fmt"
+import "math"
+import "math/big"
+
+// ElementType identifies the type of an encoded tuple element.
+type ElementType int
+
+const (
+	ElementTypeNil ElementType = iota
+	ElementTypeBytes
+	ElementTypeString
+	ElementTypeTuple
+	ElementTypeInt
+	ElementTypeFloat32
+	ElementTypeFloat64
+	ElementTypeBool
+	ElementTypeUUID
+	ElementTypeVersionstamp
+	ElementTypeUser
+)
+
+var elementTypeNames = [...]string{
+	ElementTypeNil: "nil",
+	ElementTypeBytes: "bytes",
+	ElementTypeString: "string",
+	ElementTypeTuple: "tuple",
+	ElementTypeInt: "integer",
+	ElementTypeFloat32: "float32",
+	ElementTypeFloat64: "float64",
+	ElementTypeBool: "bool",
+	ElementTypeUUID: "UUID",
+	ElementTypeVersionstamp: "Versionstamp",
+	ElementTypeUser: "user type",
+}
+
+func (et ElementType) String() string {
+	if et < 0 || int(et) >= len(elementTypeNames) {
+		return fmt.Sprintf("ElementType(%d)", int(et))
+	}
+	return elementTypeNames[et]
+}
+
+func elementType(code byte) ElementType {
+	switch {
+	case code == nilCode:
+		return ElementTypeNil
+	case code == bytesCode:
+		return ElementTypeBytes
+	case code == stringCode:
+		return ElementTypeString
+	case code == nestedCode:
+		return ElementTypeTuple
+	case negIntStart <= code && code <= posIntEnd:
+		return ElementTypeInt
+	case code == floatCode:
+		return ElementTypeFloat32
+	case code == doubleCode:
+		return ElementTypeFloat64
+	case code == falseCode || code == trueCode:
+		return ElementTypeBool
+	case code == uuidCode:
+		return ElementTypeUUID
+	case code == versionstampCode:
+		return ElementTypeVersionstamp
+	}
+	return ElementTypeUser
+}
+
+// A TupleReader decodes the elements of a packed tuple one at a time, without
+// unpacking the entire tuple. Elements are checked as they are reached, but are
+// only decoded when requested, so a TupleReader is well suited to reading a
+// few elements of a long key, or to reading keys without the allocations made
+// by Unpack.
+//
+//	r := tuple.NewTupleReader(key)
+//	for r.Next() {
+//		switch r.Type() {
+//		case tuple.ElementTypeString:
+//			s, _ := r.GetString()
+//			...
+//		}
+//	}
+//	if e := r.Err(); e != nil {
+//		...
+//	}
+type TupleReader struct {
+	b []byte
+	index int
+	start int
+	end int
+	err error
+}
+
+// NewTupleReader returns a TupleReader over the packed tuple b. The reader
+// refers to b, which must not be modified while the reader is in use.
+func NewTupleReader(b []byte) TupleReader {
+	return TupleReader{b: b, index: -1}
+}
+
+// Next advances the reader to the next element of the tuple, which then
+// becomes available through the other methods of the reader. It returns false
+// when there are no more elements, or if the next element is malformed (in
+// which case Err returns the error).
+func (r *TupleReader) Next() bool {
+	r.start = r.end
+	if r.err != nil || r.end >= len(r.b) {
+		return false
+	}
+
+	end, e := scanElement(r.b, r.start)
+	if e != nil {
+		r.err = e
+		return false
+	}
+
+	r.end = end
+	r.index++
+	return true
+}
+
+// Err returns the error, if any, that ended iteration. It returns nil if the
+// reader reached the end of a well-formed tuple.
+func (r *TupleReader) Err() error {
+	return r.err
+}
+
+// Index returns the position within the tuple of the current element.
+func (r *TupleReader) Index() int {
+	return r.index
+}
+
+// Offset returns the offset within the packed tuple of the current element.
+func (r *TupleReader) Offset() int {
+	return r.start
+}
+
+// Raw returns the encoding of the current element, or nil if there is no
+// current element. The slice aliases the packed tuple.
+func (r *TupleReader) Raw() []byte {
+	if r.start == r.end {
+		return nil
+	}
+	return r.b[r.start:r.end:r.end]
+}
+
+// Type returns the type of the current element. It panics if there is no
+// current element (that is, if Next has not returned true).
+func (r *TupleReader) Type() ElementType {
+	if r.start == r.end {
+		panic("tuple: Type called without a current element")
+	}
+	return elementType(r.b[r.start])
+}
+
+func (r *TupleReader) current() ([]byte, error) {
+	if r.start == r.end {
+		return nil, errors.New("no current tuple element")
+	}
+	return r.b[r.start:r.end], nil
+}
+
+func (r *TupleReader) typeError(raw []byte, want string) error {
+	return fmt.Errorf("tuple element %d (%s) is not %s", r.index, elementType(raw[0]), want)
+}
+
+// Element decodes and returns the current element, exactly as Unpack would.
+func (r *TupleReader) Element() (TupleElement, error) {
+	raw, e := r.current()
+	if e != nil {
+		return nil, e
+	}
+	el, e := decodeElement(raw)
+	if e != nil {
+		return nil, fmt.Errorf("invalid tuple element with typecode %02x at offset %d: %v", raw[0], r.start, e)
+	}
+	return el, nil
+}
+
+// IsNil returns true if the current element is nil.
+func (r *TupleReader) IsNil() (bool, error) {
+	raw, e := r.current()
+	if e != nil {
+		return false, e
+	}
+	return raw[0] == nilCode, nil
+}
+
+// GetInt returns the current element, which must be an integer that fits in
+// an int64.
+func (r *TupleReader) GetInt() (int64, error) {
+	raw, e := r.current()
+	if e != nil {
+		return 0, e
+	}
+	if code := raw[0]; 0x0c <= code && code <= 0x1c {
+		mag, neg := decodeSmallInt(raw)
+		switch {
+		case !neg && mag <= math.MaxInt64:
+			return int64(mag), nil
+		case neg && mag <= 1<<63:
+			return int64(-mag), nil
+		}
+	}
+	return 0, r.typeError(raw, "an int64")
+}
+
+// GetUint returns the current element, which must be an integer that fits in
+// a uint64.
+func (r *TupleReader) GetUint() (uint64, error) {
+	raw, e := r.current()
+	if e != nil {
+		return 0, e
+	}
+	if code := raw[0]; intZeroCode <= code && code <= 0x1c {
+		mag, _ := decodeSmallInt(raw)
+		return mag, nil
+	}
+	return 0, r.typeError(raw, "a uint64")
+}
+
+// GetBigInt returns the current element, which may be an integer of any size,
+// as a *big.Int.
+func (r *TupleReader) GetBigInt() (*big.Int, error) {
+	raw, e := r.current()
+	if e != nil {
+		return nil, e
+	}
+	if elementType(raw[0]) != ElementTypeInt {
+		return nil, r.typeError(raw, "an integer")
+	}
+	el, _ := decodeElement(raw)
+	v, _ := toBigInt(el)
+	return v, nil
+}
+
+// GetFloat32 returns the current element, which must be a float32.
+func (r *TupleReader) GetFloat32() (float32, error) {
+	raw, e := r.current()
+	if e != nil {
+		return 0, e
+	}
+	if raw[0] != floatCode {
+		return 0, r.typeError(raw, "a float32")
+	}
+	return decodeFloat(raw), nil
+}
+
+// GetFloat64 returns the current element, which must be a float64 or float32,
+// as a float64.
+func (r *TupleReader) GetFloat64() (float64, error) {
+	raw, e := r.current()
+	if e != nil {
+		return 0, e
+	}
+	switch raw[0] {
+	case doubleCode:
+		return decodeDouble(raw), nil
+	case floatCode:
+		return float64(decodeFloat(raw)), nil
+	}
+	return 0, r.typeError(raw, "a float64")
+}
+
+// GetString returns the current element, which must be a string.
+func (r *TupleReader) GetString() (string, error) {
+	raw, e := r.current()
+	if e != nil {
+		return "", e
+	}
+	if raw[0] != stringCode {
+		return "", r.typeError(raw, "a string")
+	}
+	if s := raw[1:len(raw)-1]; bytes.IndexByte(s, 0x00) < 0 {
+		return string(s), nil
+	}
+	return string(decodeBytes(raw)), nil
+}
+
+// GetBytes returns the current element, which must be a byte string. Unless
+// the byte string contains 0x00 (which is escaped in the packed tuple), the
+// result aliases the packed tuple rather than being copied from it.
+func (r *TupleReader) GetBytes() ([]byte, error) {
+	raw, e := r.current()
+	if e != nil {
+		return nil, e
+	}
+	if raw[0] != bytesCode {
+		return nil, r.typeError(raw, "a []byte")
+	}
+	if s := raw[1:len(raw)-1]; bytes.IndexByte(s, 0x00) < 0 {
+		return s[:len(s):len(s)], nil
+	}
+	return decodeBytes(raw), nil
+}
+
+// GetBool returns the current element, which must be a bool.
+func (r *TupleReader) GetBool() (bool, error) {
+	raw, e := r.current()
+	if e != nil {
+		return false, e
+	}
+	switch raw[0] {
+	case trueCode:
+		return true, nil
+	case falseCode:
+		return false, nil
+	}
+	return false, r.typeError(raw, "a bool")
+}
+
+// GetUUID returns the current element, which must be a UUID.
+func (r *TupleReader) GetUUID() (UUID, error) {
+	raw, e := r.current()
+	if e != nil {
+		return UUID{}, e
+	}
+	if raw[0] != uuidCode {
+		return UUID{}, r.typeError(raw, "a UUID")
+	}
+	return decodeUUID(raw), nil
+}
+
+// GetVersionstamp returns the current element, which must be a Versionstamp.
+func (r *TupleReader) GetVersionstamp() (Versionstamp, error) {
+	raw, e := r.current()
+	if e != nil {
+		return Versionstamp{}, e
+	}
+	if raw[0] != versionstampCode {
+		return Versionstamp{}, r.typeError(raw, "a Versionstamp")
+	}
+	return decodeVersionstamp(raw), nil
+}
+
+// GetTuple returns the current element, which must be a nested tuple.
+func (r *TupleReader) GetTuple() (Tuple, error) {
+	raw, e := r.current()
+	if e != nil {
+		return nil, e
+	}
+	if raw[0] != nestedCode {
+		return nil, r.typeError(raw, "a Tuple")
+	}
+	el, e := r.Element()
+	if e != nil {
+		return nil, e
+	}
+	return el.(Tuple), nil
+}
@@ -0,0 +1,193 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestTupleReader(t *testing.T) {
+	packed := tuple.Tuple{
+		nil, []byte("a\x00b"), []byte("c"), "d\x00", int64(math.MinInt64), uint64(math.MaxUint64),
+		bigInt("-0x10000000000000000"), float32(1), float64(2), false, tuple.UUID{1},
+		tuple.Versionstamp{UserVersion: 7}, tuple.Tuple{nil, "e"},
+	}.Pack()
+
+	r := tuple.NewTupleReader(packed)
+	next := func(et tuple.ElementType) {
+		if !r.Next() {
+			t.Fatalf("reader ended early: %v", r.Err())
+		}
+		if r.Type() != et {
+			t.Fatalf("element %d has type %v, expected %v", r.Index(), r.Type(), et)
+		}
+	}
+	check := func(e error) {
+		if e != nil {
+			t.Fatalf("element %d: %v", r.Index(), e)
+		}
+	}
+
+	next(tuple.ElementTypeNil)
+	isNil, e := r.IsNil()
+	check(e)
+	if !isNil {
+		t.Error("IsNil returned false for nil")
+	}
+	if _, e := r.GetInt(); e == nil || e.Error() != "tuple element 0 (nil) is not an int64" {
+		t.Errorf("GetInt of nil returned %v", e)
+	}
+
+	next(tuple.ElementTypeBytes)
+	b, e := r.GetBytes()
+	check(e)
+	if string(b) != "a\x00b" {
+		t.Errorf("GetBytes returned %q", b)
+	}
+
+	next(tuple.ElementTypeBytes)
+	b, e = r.GetBytes()
+	check(e)
+	if string(b) != "c" || cap(b) != 1 {
+		t.Errorf("GetBytes returned %q (capacity %d)", b, cap(b))
+	}
+	if r.Offset() != 7 || !bytes.Equal(r.Raw(), []byte{0x01, 'c', 0x00}) {
+		t.Errorf("element 2 has offset %d and encoding %x", r.Offset(), r.Raw())
+	}
+
+	next(tuple.ElementTypeString)
+	s, e := r.GetString()
+	check(e)
+	if s != "d\x00" {
+		t.Errorf("GetString returned %q", s)
+	}
+
+	next(tuple.ElementTypeInt)
+	i, e := r.GetInt()
+	check(e)
+	if i != math.MinInt64 {
+		t.Errorf("GetInt returned %d", i)
+	}
+	if _, e := r.GetUint(); e == nil {
+		t.Error("GetUint of a negative integer succeeded")
+	}
+
+	next(tuple.ElementTypeInt)
+	u, e := r.GetUint()
+	check(e)
+	if u != math.MaxUint64 {
+		t.Errorf("GetUint returned %d", u)
+	}
+	if _, e := r.GetInt(); e == nil {
+		t.Error("GetInt of a uint64 beyond int64 succeeded")
+	}
+
+	next(tuple.ElementTypeInt)
+	bi, e := r.GetBigInt()
+	check(e)
+	if bi.Cmp(bigInt("-0x10000000000000000")) != 0 {
+		t.Errorf("GetBigInt returned %v", bi)
+	}
+
+	next(tuple.ElementTypeFloat32)
+	f, e := r.GetFloat32()
+	check(e)
+	if f != 1 {
+		t.Errorf("GetFloat32 returned %v", f)
+	}
+
+	next(tuple.ElementTypeFloat64)
+	d, e := r.GetFloat64()
+	check(e)
+	if d != 2 {
+		t.Errorf("GetFloat64 returned %v", d)
+	}
+
+	next(tuple.ElementTypeBool)
+	bl, e := r.GetBool()
+	check(e)
+	if bl {
+		t.Error("GetBool returned true")
+	}
+
+	next(tuple.ElementTypeUUID)
+	id, e := r.GetUUID()
+	check(e)
+	if id != (tuple.UUID{1}) {
+		t.Errorf("GetUUID returned %v", id)
+	}
+
+	next(tuple.ElementTypeVersionstamp)
+	v, e := r.GetVersionstamp()
+	check(e)
+	if v != (tuple.Versionstamp{UserVersion: 7}) {
+		t.Errorf("GetVersionstamp returned %v", v)
+	}
+
+	next(tuple.ElementTypeTuple)
+	nt, e := r.GetTuple()
+	check(e)
+	if tuple.Compare(nt, tuple.Tuple{nil, "e"}) != 0 {
+		t.Errorf("GetTuple returned %v", nt)
+	}
+
+	if r.Next() {
+		t.Errorf("reader continued past the end, to element %d", r.Index())
+	}
+	if e := r.Err(); e != nil {
+		t.Errorf("reader ended with error %v", e)
+	}
+	if _, e := r.Element(); e == nil {
+		t.Error("Element returned without a current element")
+	}
+}
+
+func BenchmarkUnpack(b *testing.B) {
+	b.ReportAllocs()
+	packed := benchTuple.Pack()
+	for i := 0; i < b.N; i++ {
+		tuple.Unpack(packed)
+	}
+}
+
+func BenchmarkTupleReader(b *testing.B) {
+	b.ReportAllocs()
+	packed := benchTuple.Pack()
+	for i := 0; i < b.N; i++ {
+		r := tuple.NewTupleReader(packed)
+		for r.Next() {
+			switch r.Type() {
+			case tuple.ElementTypeInt:
+				r.GetInt()
+			case tuple.ElementTypeBytes:
+				r.GetBytes()
+			case tuple.ElementTypeFloat64:
+				r.GetFloat64()
+			case tuple.ElementTypeBool:
+				r.GetBool()
+			}
+		}
+	}
+}
@@ -28,15 +28,27 @@
 // For general guidance on tuple usage, see the Tuple section of Data Modeling
 // (https://foundationdb.com/documentation/data-modeling.html#data-modeling-tuples).
 //
-// FoundationDB tuples can currently encode byte and unicode strings, integers
-// and NULL values. In Go these are represented as []byte, string, int64 and
-// nil.
+// FoundationDB tuples can encode byte and unicode strings, integers of
+// arbitrary size, single- and double-precision floating point numbers,
+// booleans, UUIDs, versionstamps, nested tuples and NULL values. In Go these
+// are represented as []byte, string, int64, uint64 and *big.Int, float32,
+// float64, bool, UUID, Versionstamp, Tuple and nil. The encoding is shared
+// with the other FoundationDB bindings, so tuples packed by (for example) the
+// Python or Java bindings may be unpacked by this package, and vice versa.
+// Other Go types may be used as tuple elements by registering a Codec for
+// them.
 package tuple
 
-import "github.com/segmentio/fdb-go/fdb"
+import "github.com/FoundationDB/fdb-go/fdb"
 import "encoding/binary"
 import "bytes"
+import "errors"
 import "fmt"
+import "math"
+import "math/big"
+import "math/bits"
+import "reflect"
+import "strings"
 
 // A TupleElement is one of the types that may be encoded in FoundationDB
 // tuples. Although the Go compiler cannot enforce this, it is a programming
@@ -44,18 +56,89 @@ import "fmt"
 // result in a runtime panic).
 //
 // The valid types for TupleElement are []byte (or fdb.KeyConvertible), string,
-// int64 (or int), and nil.
+// int64 (or int), uint64 (or uint), *big.Int (or big.Int), float32, float64,
+// bool, UUID, Versionstamp, Tuple and nil.
 type TupleElement interface{}
 
 // Tuple is a slice of objects that can be encoded as FoundationDB tuples. If
 // any of the TupleElements are of unsupported types, a runtime panic will occur
-// when the Tuple is packed.
+// when the Tuple is packed (or, with PackWithError, an error will be returned).
 //
 // Given a Tuple T containing objects only of these types, then T will be
 // identical to the Tuple returned by unpacking the byte slice obtained by
-// packing T (modulo type normalization to []byte and int64).
+// packing T (modulo type normalization to []byte, and of integers to int64,
+// or to uint64 or *big.Int for values that do not fit in an int64).
 type Tuple []TupleElement
 
+// UUID is a 16-byte universally unique identifier, encoded in tuples in the
+// same byte order in which it is written (RFC 4122).
+type UUID [16]byte
+
+func (u UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// Type codes of the tuple encoding
+const (
+	nilCode = 0x00
+	bytesCode = 0x01
+	stringCode = 0x02
+	nestedCode = 0x05
+	negIntStart = 0x0b
+	intZeroCode = 0x14
+	posIntEnd = 0x1d
+	floatCode = 0x20
+	doubleCode = 0x21
+	falseCode = 0x26
+	trueCode = 0x27
+	uuidCode = 0x30
+	versionstampCode = 0x33
+)
+
+// Versionstamp is a 12-byte tuple element made up of the 10-byte versionstamp
+// that the database assigns to a transaction when it is committed, followed
+// by a 2-byte user version that orders multiple versionstamps written by the
+// same transaction.
+//
+// A Versionstamp whose transaction version has not yet been assigned is
+// incomplete (see IncompleteVersionstamp). A tuple containing an incomplete
+// Versionstamp must be packed with PackWithVersionstamp, and the result written
+// with (fdb.Transaction).SetVersionstampedKey or SetVersionstampedValue; the
+// database replaces the transaction version when the transaction commits.
+type Versionstamp struct {
+	TransactionVersion [10]byte
+	UserVersion uint16
+}
+
+var incompleteTransactionVersion = [10]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+const versionstampLength = 12
+
+// IncompleteVersionstamp returns a Versionstamp with the given user version
+// whose transaction version will be assigned by the database at commit time.
+func IncompleteVersionstamp(userVersion uint16) Versionstamp {
+	return Versionstamp{incompleteTransactionVersion, userVersion}
+}
+
+// IsComplete returns false if the transaction version of the Versionstamp has
+// not yet been assigned.
+func (v Versionstamp) IsComplete() bool {
+	return v.TransactionVersion != incompleteTransactionVersion
+}
+
+// Bytes returns the 12-byte encoding of the Versionstamp: the transaction
+// version followed by the big-endian user version.
+func (v Versionstamp) Bytes() []byte {
+	b := make([]byte, versionstampLength)
+	copy(b, v.TransactionVersion[:])
+	binary.BigEndian.PutUint16(b[10:], v.UserVersion)
+	return b
+}
+
+func (v Versionstamp) String() string {
+	return fmt.Sprintf("Versionstamp(%x, %d)", v.TransactionVersion[:], v.UserVersion)
+}
+
 var sizeLimits = []uint64{
 	1<<(0*8) - 1,
 	1<<(1*8) - 1,
@@ -68,159 +151,613 @@ var sizeLimits = []uint64{
 	1<<(8*8) - 1,
 }
 
-func encodeBytes(buf *bytes.Buffer, code byte, b []byte) {
-	buf.WriteByte(code)
-	buf.Write(bytes.Replace(b, []byte{0x00}, []byte{0x00, 0xFF}, -1))
-	buf.WriteByte(0x00)
+// appendBytes appends the encoding of a byte or unicode string to dst,
+// escaping each 0x00 within it as 0x00 0xFF.
+func appendBytes(dst []byte, code byte, b []byte) []byte {
+	dst = append(dst, code)
+	for {
+		idx := bytes.IndexByte(b, 0x00)
+		if idx < 0 {
+			break
+		}
+		dst = append(dst, b[:idx+1]...)
+		dst = append(dst, 0xFF)
+		b = b[idx+1:]
+	}
+	dst = append(dst, b...)
+	return append(dst, 0x00)
 }
 
+// appendString is like appendBytes, but avoids converting s to a byte slice.
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, stringCode)
+	for {
+		idx := strings.IndexByte(s, 0x00)
+		if idx < 0 {
+			break
+		}
+		dst = append(dst, s[:idx+1]...)
+		dst = append(dst, 0xFF)
+		s = s[idx+1:]
+	}
+	dst = append(dst, s...)
+	return append(dst, 0x00)
+}
+
+// bisectLeft returns the number of bytes needed to represent u.
 func bisectLeft(u uint64) int {
-	var n int
-	for sizeLimits[n] < u {
-		n += 1
+	return (bits.Len64(u) + 7) / 8
+}
+
+func appendUint(dst []byte, u uint64) []byte {
+	if u == 0 {
+		return append(dst, intZeroCode)
 	}
-	return n
+
+	n := bisectLeft(u)
+	dst = append(dst, byte(intZeroCode + n))
+
+	var ibuf [8]byte
+	binary.BigEndian.PutUint64(ibuf[:], u)
+	return append(dst, ibuf[8-n:]...)
 }
 
-func encodeInt(buf *bytes.Buffer, i int64) {
-	if i == 0 {
-		buf.WriteByte(0x14)
-		return
+func appendInt(dst []byte, i int64) []byte {
+	if i >= 0 {
+		return appendUint(dst, uint64(i))
 	}
 
-	var n int
-	var ibuf bytes.Buffer
+	// A negative integer of n bytes is encoded as the one's complement of its
+	// magnitude, which is what (2^8n - 1) + i yields.
+	n := bisectLeft(-uint64(i))
+	dst = append(dst, byte(intZeroCode - n))
 
-	switch {
-	case i > 0:
-		n = bisectLeft(uint64(i))
-		buf.WriteByte(byte(0x14 + n))
-		binary.Write(&ibuf, binary.BigEndian, i)
-	case i < 0:
-		n = bisectLeft(uint64(-i))
-		buf.WriteByte(byte(0x14 - n))
-		binary.Write(&ibuf, binary.BigEndian, int64(sizeLimits[n])+i)
+	var ibuf [8]byte
+	binary.BigEndian.PutUint64(ibuf[:], sizeLimits[n]+uint64(i))
+	return append(dst, ibuf[8-n:]...)
+}
+
+// appendBigInt encodes integers of up to 255 bytes in magnitude. Integers that
+// fit in 8 bytes are encoded exactly as by appendInt and appendUint; larger
+// ones are preceded by their length in bytes.
+func appendBigInt(dst []byte, i *big.Int) ([]byte, error) {
+	if i.IsUint64() {
+		return appendUint(dst, i.Uint64()), nil
 	}
+	if i.IsInt64() {
+		return appendInt(dst, i.Int64()), nil
+	}
+
+	mag := i.Bytes()
+	n := len(mag)
+	if n > 0xFF {
+		return dst, fmt.Errorf("integer magnitude is too large to encode (%d bytes)", n)
+	}
+
+	if i.Sign() >= 0 {
+		dst = append(dst, posIntEnd, byte(n))
+		return append(dst, mag...), nil
+	}
+
+	if n <= 8 {
+		dst = append(dst, byte(intZeroCode - n))
+	} else {
+		dst = append(dst, negIntStart, byte(n)^0xFF)
+	}
+	for _, b := range mag {
+		dst = append(dst, ^b)
+	}
+	return dst, nil
+}
+
+// adjustFloatBytes converts between the IEEE 754 representation of a
+// floating point number and its order-preserving encoding: the sign bit of a
+// positive number is flipped, and all bits of a negative number are flipped.
+func adjustFloatBytes(b []byte, encode bool) {
+	if (encode && b[0]&0x80 != 0x00) || (!encode && b[0]&0x80 == 0x00) {
+		for i := range b {
+			b[i] ^= 0xFF
+		}
+	} else {
+		b[0] ^= 0x80
+	}
+}
+
+func appendFloat(dst []byte, f float32) []byte {
+	var fbuf [4]byte
+	binary.BigEndian.PutUint32(fbuf[:], math.Float32bits(f))
+	adjustFloatBytes(fbuf[:], true)
+	dst = append(dst, floatCode)
+	return append(dst, fbuf[:]...)
+}
+
+func appendDouble(dst []byte, d float64) []byte {
+	var dbuf [8]byte
+	binary.BigEndian.PutUint64(dbuf[:], math.Float64bits(d))
+	adjustFloatBytes(dbuf[:], true)
+	dst = append(dst, doubleCode)
+	return append(dst, dbuf[:]...)
+}
 
-	buf.Write(ibuf.Bytes()[8-n:])
+func appendVersionstamp(dst []byte, v Versionstamp) []byte {
+	dst = append(dst, versionstampCode)
+	dst = append(dst, v.TransactionVersion[:]...)
+	return append(dst, byte(v.UserVersion>>8), byte(v.UserVersion))
 }
 
 // Pack returns a new byte slice encoding the provided tuple. Pack will panic if
-// the tuple contains an element of any type other than []byte,
-// fdb.KeyConvertible, string, int64, int or nil.
+// the tuple contains an element of any type other than those listed for
+// TupleElement, an integer whose magnitude exceeds 255 bytes, or an incomplete
+// Versionstamp.
 //
 // Tuple satisfies the fdb.KeyConvertible interface, so it is not necessary to
 // call Pack when using a Tuple with a FoundationDB API function that requires a
 // key.
 func (t Tuple) Pack() []byte {
-	buf := new(bytes.Buffer)
+	b, e := t.PackWithError()
+	if e != nil {
+		panic(e)
+	}
+	return b
+}
+
+// PackWithError is like Pack, but returns an error rather than panicking if
+// the tuple cannot be encoded.
+func (t Tuple) PackWithError() ([]byte, error) {
+	// Most keys are short; starting with a small buffer saves the first few
+	// reallocations without holding on to much unused capacity.
+	b, e := t.appendTo(make([]byte, 0, 32), nil, false)
+	if e != nil {
+		return nil, e
+	}
+	return b, nil
+}
+
+// AppendPack appends the encoding of the provided tuple to dst and returns the
+// extended slice, in the manner of the append built-in. AppendPack panics in
+// the same circumstances as Pack.
+//
+// AppendPack does not allocate if dst has sufficient capacity (and the tuple
+// contains no *big.Int), so a single buffer may be reused to build many keys.
+// See also Packer.
+func (t Tuple) AppendPack(dst []byte) []byte {
+	b, e := t.appendTo(dst, nil, false)
+	if e != nil {
+		panic(e)
+	}
+	return b
+}
+
+// PackWithVersionstamp returns a new byte slice encoding the provided tuple
+// after prefix, followed by the position of the tuple's incomplete
+// Versionstamp, so that the result may be passed to
+// (fdb.Transaction).SetVersionstampedKey. The position is encoded as a 4-byte
+// little-endian integer, or as a 2-byte one if an API version prior to 520 has
+// been selected.
+//
+// PackWithVersionstamp returns an error if the tuple does not contain exactly
+// one incomplete Versionstamp, or if it cannot be encoded.
+func (t Tuple) PackWithVersionstamp(prefix []byte) ([]byte, error) {
+	var stamps []int
+	b, e := t.appendTo(concat(prefix), &stamps, false)
+	if e != nil {
+		return nil, e
+	}
+
+	switch len(stamps) {
+	case 0:
+		return nil, errors.New("no incomplete versionstamp in tuple packed with versionstamp")
+	case 1:
+	default:
+		return nil, errors.New("multiple incomplete versionstamps in tuple packed with versionstamp")
+	}
+
+	pos := stamps[0]
+
+	if v, e := fdb.GetAPIVersion(); e == nil && v < 520 {
+		if pos > 0xFFFF {
+			return nil, fmt.Errorf("versionstamp position %d does not fit in 2 bytes", pos)
+		}
+		b = append(b, 0, 0)
+		binary.LittleEndian.PutUint16(b[len(b)-2:], uint16(pos))
+	} else {
+		b = append(b, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[len(b)-4:], uint32(pos))
+	}
+
+	return b, nil
+}
+
+// HasIncompleteVersionstamp returns true if the tuple (or any tuple nested
+// within it) contains an incomplete Versionstamp.
+func (t Tuple) HasIncompleteVersionstamp() bool {
+	for _, e := range t {
+		switch e := e.(type) {
+		case Versionstamp:
+			if !e.IsComplete() {
+				return true
+			}
+		case Tuple:
+			if e.HasIncompleteVersionstamp() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendTo appends the encoding of the tuple to dst. If stamps is nil, an
+// incomplete Versionstamp is an error; otherwise, the position in dst of each
+// incomplete Versionstamp is appended to stamps. Within a nested tuple, nil is
+// escaped so that it is distinguishable from the terminating 0x00.
+func (t Tuple) appendTo(dst []byte, stamps *[]int, nested bool) ([]byte, error) {
+	var err error
 
 	for i, e := range t {
 		switch e := e.(type) {
 		case nil:
-			buf.WriteByte(0x00)
+			dst = append(dst, nilCode)
+			if nested {
+				dst = append(dst, 0xFF)
+			}
 		case int64:
-			encodeInt(buf, e)
+			dst = appendInt(dst, e)
 		case int:
-			encodeInt(buf, int64(e))
+			dst = appendInt(dst, int64(e))
+		case uint64:
+			dst = appendUint(dst, e)
+		case uint:
+			dst = appendUint(dst, uint64(e))
+		case *big.Int:
+			if e == nil {
+				return dst, fmt.Errorf("unencodable element at index %d (nil *big.Int)", i)
+			}
+			if dst, err = appendBigInt(dst, e); err != nil {
+				return dst, fmt.Errorf("unencodable element at index %d: %v", i, err)
+			}
+		case big.Int:
+			if dst, err = appendBigInt(dst, &e); err != nil {
+				return dst, fmt.Errorf("unencodable element at index %d: %v", i, err)
+			}
+		case float32:
+			dst = appendFloat(dst, e)
+		case float64:
+			dst = appendDouble(dst, e)
+		case bool:
+			if e {
+				dst = append(dst, trueCode)
+			} else {
+				dst = append(dst, falseCode)
+			}
+		case UUID:
+			dst = append(dst, uuidCode)
+			dst = append(dst, e[:]...)
+		case Tuple:
+			dst = append(dst, nestedCode)
+			if dst, err = e.appendTo(dst, stamps, true); err != nil {
+				return dst, fmt.Errorf("in nested tuple at index %d: %v", i, err)
+			}
+			dst = append(dst, 0x00)
 		case []byte:
-			encodeBytes(buf, 0x01, e)
+			dst = appendBytes(dst, bytesCode, e)
 		case fdb.KeyConvertible:
-			encodeBytes(buf, 0x01, []byte(e.FDBKey()))
+			dst = appendBytes(dst, bytesCode, e.FDBKey())
 		case string:
-			encodeBytes(buf, 0x02, []byte(e))
+			dst = appendString(dst, e)
+		case Versionstamp:
+			if !e.IsComplete() {
+				if stamps == nil {
+					return dst, fmt.Errorf("incomplete versionstamp at index %d (use PackWithVersionstamp)", i)
+				}
+				*stamps = append(*stamps, len(dst)+1)
+			}
+			dst = appendVersionstamp(dst, e)
 		default:
-			panic(fmt.Sprintf("unencodable element at index %d (%v, type %T)", i, t[i], t[i]))
+			c := codecForType(reflect.TypeOf(e))
+			if c == nil {
+				return dst, fmt.Errorf("unencodable element at index %d (%v, type %T)", i, t[i], t[i])
+			}
+			if dst, err = c.appendTo(dst, e, stamps, nested); err != nil {
+				return dst, fmt.Errorf("unencodable element at index %d: %v", i, err)
+			}
 		}
 	}
-
-	return buf.Bytes()
+	return dst, nil
 }
 
+// findTerminator returns the index of the first 0x00 in b that is not followed
+// by 0xFF (and so does not escape a 0x00 within a byte string), or -1 if there
+// is none.
 func findTerminator(b []byte) int {
-	bp := b
-	var length int
+	var n int
 
 	for {
-		idx := bytes.IndexByte(bp, 0x00)
-		length += idx
-		if idx+1 == len(bp) || bp[idx+1] != 0xFF {
-			break
+		idx := bytes.IndexByte(b[n:], 0x00)
+		if idx < 0 {
+			return -1
 		}
-		length += 2
-		bp = bp[idx+2:]
+		n += idx
+		if n+1 == len(b) || b[n+1] != 0xFF {
+			return n
+		}
+		n += 2
 	}
+}
 
-	return length
+// decodeBytes decodes a complete byte or unicode string element, including its
+// type code and terminator.
+func decodeBytes(b []byte) []byte {
+	return bytes.Replace(b[1:len(b)-1], []byte{0x00, 0xFF}, []byte{0x00}, -1)
 }
 
-func decodeBytes(b []byte) ([]byte, int) {
-	idx := findTerminator(b[1:])
-	return bytes.Replace(b[1:idx+1], []byte{0x00, 0xFF}, []byte{0x00}, -1), idx + 2
+// decodeSmallInt decodes an integer of up to 8 bytes into its magnitude and
+// sign.
+func decodeSmallInt(b []byte) (uint64, bool) {
+	n := int(b[0]) - intZeroCode
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+
+	var u uint64
+	for _, c := range b[1:n+1] {
+		u = u<<8 | uint64(c)
+	}
+
+	if neg {
+		return sizeLimits[n] - u, true
+	}
+	return u, false
 }
 
-func decodeString(b []byte) (string, int) {
-	bp, idx := decodeBytes(b)
-	return string(bp), idx
+// decodeInt decodes an integer of up to 8 bytes. Values that do not fit in an
+// int64 are returned as a uint64 (if positive) or a *big.Int (if negative).
+func decodeInt(b []byte) interface{} {
+	mag, neg := decodeSmallInt(b)
+
+	switch {
+	case !neg && mag > math.MaxInt64:
+		return mag
+	case !neg:
+		return int64(mag)
+	case mag > 1<<63:
+		return new(big.Int).Neg(new(big.Int).SetUint64(mag))
+	}
+	return int64(-mag)
 }
 
-func decodeInt(b []byte) (int64, int) {
-	if b[0] == 0x14 {
-		return 0, 1
+// decodeBigInt decodes an integer of more than 8 bytes. Its result is always a
+// *big.Int, since any value that fits in 8 bytes is encoded by appendInt.
+func decodeBigInt(b []byte) *big.Int {
+	n := int(b[1])
+	if b[0] == negIntStart {
+		n ^= 0xFF
 	}
 
-	var neg bool
+	mag := make([]byte, n)
+	copy(mag, b[2:n+2])
 
-	n := int(b[0]) - 20
-	if n < 0 {
-		n = -n
-		neg = true
+	if b[0] == negIntStart {
+		for i := range mag {
+			mag[i] = ^mag[i]
+		}
+		return new(big.Int).Neg(new(big.Int).SetBytes(mag))
 	}
+	return new(big.Int).SetBytes(mag)
+}
+
+func decodeFloat(b []byte) float32 {
+	var bp [4]byte
+	copy(bp[:], b[1:5])
+	adjustFloatBytes(bp[:], false)
+	return math.Float32frombits(binary.BigEndian.Uint32(bp[:]))
+}
 
-	bp := make([]byte, 8)
-	copy(bp[8-n:], b[1:n+1])
+func decodeDouble(b []byte) float64 {
+	var bp [8]byte
+	copy(bp[:], b[1:9])
+	adjustFloatBytes(bp[:], false)
+	return math.Float64frombits(binary.BigEndian.Uint64(bp[:]))
+}
 
-	var ret int64
+func decodeUUID(b []byte) UUID {
+	var u UUID
+	copy(u[:], b[1:17])
+	return u
+}
 
-	binary.Read(bytes.NewBuffer(bp), binary.BigEndian, &ret)
+func decodeVersionstamp(b []byte) Versionstamp {
+	var v Versionstamp
+	copy(v.TransactionVersion[:], b[1:11])
+	v.UserVersion = binary.BigEndian.Uint16(b[11:13])
+	return v
+}
 
-	if neg {
-		ret -= int64(sizeLimits[n])
+// fixedLength returns the encoded length (including the type code) of the
+// fixed-size element beginning with code, or zero if its length is variable.
+func fixedLength(code byte) int {
+	switch {
+	case 0x0c <= code && code <= 0x1c:
+		n := int(code) - intZeroCode
+		if n < 0 {
+			n = -n
+		}
+		return n + 1
+	case code == floatCode:
+		return 5
+	case code == doubleCode:
+		return 9
+	case code == uuidCode:
+		return 17
+	case code == versionstampCode:
+		return versionstampLength + 1
 	}
+	return 0
+}
+
+// scanElement checks that b holds a complete, well-formed element beginning at
+// offset i, without decoding it, and returns the offset following it. Within a
+// nested tuple, an escaped nil or the terminating 0x00 must be handled by the
+// caller. Errors report the offset in b of the malformed element.
+func scanElement(b []byte, i int) (int, error) {
+	code := b[i]
 
-	return ret, n + 1
+	if n := fixedLength(code); n > 0 {
+		if i+n > len(b) {
+			return i, fmt.Errorf("truncated tuple element with typecode %02x at offset %d (%d bytes required, %d available)", code, i, n, len(b)-i)
+		}
+		return i + n, nil
+	}
+
+	switch code {
+	case nilCode, falseCode, trueCode:
+		return i + 1, nil
+	case bytesCode, stringCode:
+		idx := findTerminator(b[i+1:])
+		if idx < 0 {
+			return i, fmt.Errorf("unterminated string with typecode %02x at offset %d", code, i)
+		}
+		return i + idx + 2, nil
+	case nestedCode:
+		j := i + 1
+		for j < len(b) {
+			if b[j] == nilCode {
+				if j+1 < len(b) && b[j+1] == 0xFF {
+					j += 2
+					continue
+				}
+				return j + 1, nil
+			}
+			var e error
+			if j, e = scanElement(b, j); e != nil {
+				return j, e
+			}
+		}
+		return i, fmt.Errorf("unterminated nested tuple at offset %d", i)
+	case negIntStart, posIntEnd:
+		if i+1 >= len(b) {
+			return i, fmt.Errorf("truncated integer at offset %d (missing length)", i)
+		}
+		n := int(b[i+1])
+		if code == negIntStart {
+			n ^= 0xFF
+		}
+		if i+n+2 > len(b) {
+			return i, fmt.Errorf("truncated integer at offset %d (%d bytes required, %d available)", i, n+2, len(b)-i)
+		}
+		return i + n + 2, nil
+	}
+
+	if codecForCode(code) != nil {
+		if i+1 >= len(b) {
+			return i, fmt.Errorf("truncated user type element with typecode %02x at offset %d", code, i)
+		}
+		return scanElement(b, i+1)
+	}
+
+	return i, fmt.Errorf("unknown tuple element typecode %02x at offset %d", code, i)
 }
 
-// Unpack returns the tuple encoded by the provided byte slice, or an error if
-// the key does not correctly encode a FoundationDB tuple.
-func Unpack(b []byte) (Tuple, error) {
+// decodeElement decodes the single, well-formed (as checked by scanElement)
+// element encoded by b. Only the decoding of a value of a registered type can
+// fail.
+func decodeElement(b []byte) (TupleElement, error) {
+	switch code := b[0]; {
+	case code == nilCode:
+		return nil, nil
+	case code == bytesCode:
+		return decodeBytes(b), nil
+	case code == stringCode:
+		return string(decodeBytes(b)), nil
+	case code == nestedCode:
+		t, _, e := decodeTuple(b, 1, true)
+		return t, e
+	case code == negIntStart || code == posIntEnd:
+		return decodeBigInt(b), nil
+	case 0x0c <= code && code <= 0x1c:
+		return decodeInt(b), nil
+	case code == floatCode:
+		return decodeFloat(b), nil
+	case code == doubleCode:
+		return decodeDouble(b), nil
+	case code == falseCode:
+		return false, nil
+	case code == trueCode:
+		return true, nil
+	case code == uuidCode:
+		return decodeUUID(b), nil
+	case code == versionstampCode:
+		return decodeVersionstamp(b), nil
+	}
+
+	el, e := decodeElement(b[1:])
+	if e != nil {
+		return nil, e
+	}
+	return codecForCode(b[0]).decode(el)
+}
+
+// decodeTuple decodes the elements of a tuple from b, beginning at offset i,
+// and returns the offset following them. A nested tuple ends at an unescaped
+// 0x00, which is consumed. Errors report the offset in b of the malformed
+// element.
+func decodeTuple(b []byte, i int, nested bool) (Tuple, int, error) {
 	var t Tuple
 
-	var i int
+	start := i
+
+	if nested {
+		t = Tuple{}
+	}
 
 	for i < len(b) {
-		var el interface{}
-		var off int
-
-		switch {
-		case b[i] == 0x00:
-			el = nil
-			off = 1
-		case b[i] == 0x01:
-			el, off = decodeBytes(b[i:])
-		case b[i] == 0x02:
-			el, off = decodeString(b[i:])
-		case 0x0c <= b[i] && b[i] <= 0x1c:
-			el, off = decodeInt(b[i:])
-		default:
-			return nil, fmt.Errorf("unable to decode tuple element with unknown typecode %02x", b[i])
+		switch b[i] {
+		case nilCode:
+			if !nested {
+				break
+			}
+			if i+1 < len(b) && b[i+1] == 0xFF {
+				t = append(t, nil)
+				i += 2
+				continue
+			}
+			return t, i + 1, nil
+		case nestedCode:
+			el, end, e := decodeTuple(b, i+1, true)
+			if e != nil {
+				return nil, end, e
+			}
+			t = append(t, el)
+			i = end
+			continue
 		}
 
+		end, e := scanElement(b, i)
+		if e != nil {
+			return nil, end, e
+		}
+		el, e := decodeElement(b[i:end])
+		if e != nil {
+			return nil, i, fmt.Errorf("invalid tuple element with typecode %02x at offset %d: %v", b[i], i, e)
+		}
 		t = append(t, el)
-		i += off
+		i = end
+	}
+
+	if nested {
+		return nil, i, fmt.Errorf("unterminated nested tuple at offset %d", start-1)
 	}
 
-	return t, nil
+	return t, i, nil
+}
+
+// Unpack returns the tuple encoded by the provided byte slice, or an error if
+// the key does not correctly encode a FoundationDB tuple. The error describes
+// the first malformed element and its offset in b. Unpack never panics, and so
+// is safe to use on keys of unknown provenance.
+func Unpack(b []byte) (Tuple, error) {
+	t, _, e := decodeTuple(b, 0, false)
+	return t, e
 }
 
 // FDBKey returns the packed representation of a Tuple, and allows Tuple to
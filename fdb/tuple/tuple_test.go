@@ -0,0 +1,359 @@
+// FoundationDB Go Tuple Layer
+// Copyright (c) 2013 FoundationDB, LLC
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tuple_test
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bigInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		panic(s)
+	}
+	return i
+}
+
+func TestEncodings(t *testing.T) {
+	tests := []struct {
+		el tuple.TupleElement
+		enc []byte
+	}{
+		{nil, []byte{0x00}},
+		{[]byte("foo\x00bar"), []byte{0x01, 'f', 'o', 'o', 0x00, 0xFF, 'b', 'a', 'r', 0x00}},
+		{"FÔO", []byte{0x02, 'F', 0xC3, 0x94, 'O', 0x00}},
+		{0, []byte{0x14}},
+		{1, []byte{0x15, 0x01}},
+		{-1, []byte{0x13, 0xFE}},
+		{255, []byte{0x15, 0xFF}},
+		{-255, []byte{0x13, 0x00}},
+		{256, []byte{0x16, 0x01, 0x00}},
+		{int64(math.MinInt64), []byte{0x0C, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{uint64(math.MaxUint64), []byte{0x1C, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{bigInt("-0xFFFFFFFFFFFFFFFF"), []byte{0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{bigInt("0x10000000000000000"), []byte{0x1D, 0x09, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{bigInt("-0x10000000000000000"), []byte{0x0B, 0xF6, 0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{float32(1), []byte{0x20, 0xBF, 0x80, 0x00, 0x00}},
+		{float32(-1), []byte{0x20, 0x40, 0x7F, 0xFF, 0xFF}},
+		{float64(-42), []byte{0x21, 0x3F, 0xBA, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{false, []byte{0x26}},
+		{true, []byte{0x27}},
+		{tuple.UUID{0: 0x01, 15: 0x0F}, []byte{0x30, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x0F}},
+		{tuple.Tuple{[]byte("foo\x00bar"), nil, tuple.Tuple{}}, []byte{0x05, 0x01, 'f', 'o', 'o', 0x00, 0xFF, 'b', 'a', 'r', 0x00, 0x00, 0xFF, 0x05, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		if enc := (tuple.Tuple{test.el}).Pack(); !bytes.Equal(enc, test.enc) {
+			t.Errorf("packed %v (%T) as %x, expected %x", test.el, test.el, enc, test.enc)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []tuple.Tuple{
+		{},
+		{nil, []byte("\x00\xFF"), "\x00", int64(0)},
+		{int64(math.MaxInt64), int64(math.MinInt64), uint64(math.MaxInt64 + 1), uint64(math.MaxUint64)},
+		{bigInt("-0xFFFFFFFFFFFFFFFF"), bigInt("-0x8000000000000001"), bigInt("0x123456789ABCDEF0123456789")},
+		{bigInt("-0x123456789ABCDEF0123456789")},
+		{float32(3.25), float32(math.Inf(-1)), float64(-0.0), math.Inf(1), math.MaxFloat64, math.SmallestNonzeroFloat64},
+		{true, false, tuple.UUID{0: 0xFF, 8: 0x80}},
+		{tuple.Tuple{nil, tuple.Tuple{nil, int64(1)}, "a"}, nil, tuple.Tuple{}},
+		{tuple.Versionstamp{[10]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 11}},
+	}
+
+	for _, test := range tests {
+		u, e := tuple.Unpack(test.Pack())
+		if e != nil {
+			t.Errorf("unable to unpack %v: %v", test, e)
+			continue
+		}
+		if !reflect.DeepEqual(u, test) && !(len(u) == 0 && len(test) == 0) {
+			t.Errorf("unpacked %#v, expected %#v", u, test)
+		}
+	}
+
+	u, _ := tuple.Unpack(tuple.Tuple{int(-5), uint(5)}.Pack())
+	if !reflect.DeepEqual(u, tuple.Tuple{int64(-5), int64(5)}) {
+		t.Errorf("integers unpacked as %#v", u)
+	}
+
+	u, _ = tuple.Unpack(tuple.Tuple{float64(math.NaN())}.Pack())
+	if f, ok := u[0].(float64); !ok || !math.IsNaN(f) {
+		t.Errorf("NaN unpacked as %#v", u[0])
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	// Elements in ascending order
+	ordered := []tuple.TupleElement{
+		nil,
+		[]byte(""),
+		[]byte("\x00"),
+		[]byte("a"),
+		"",
+		"a",
+		tuple.Tuple{},
+		tuple.Tuple{nil},
+		tuple.Tuple{nil, nil},
+		tuple.Tuple{int64(1)},
+		bigInt("-0x10000000000000001"),
+		bigInt("-0x10000000000000000"),
+		bigInt("-0xFFFFFFFFFFFFFFFF"),
+		int64(math.MinInt64),
+		int64(-256),
+		int64(-255),
+		int64(-1),
+		int64(0),
+		int64(1),
+		int64(255),
+		int64(256),
+		int64(math.MaxInt64),
+		uint64(math.MaxUint64),
+		bigInt("0x10000000000000000"),
+		bigInt("0x10000000000000001"),
+		float32(math.Inf(-1)),
+		float32(-1),
+		float32(0),
+		float32(1),
+		float32(math.Inf(1)),
+		math.Inf(-1),
+		float64(-1e100),
+		float64(-1),
+		float64(0),
+		float64(1e-100),
+		math.Inf(1),
+		false,
+		true,
+		tuple.UUID{},
+		tuple.UUID{15: 1},
+		tuple.UUID{0: 1},
+		tuple.Versionstamp{},
+	}
+
+	for i := 1; i < len(ordered); i++ {
+		a := tuple.Tuple{ordered[i-1]}.Pack()
+		b := tuple.Tuple{ordered[i]}.Pack()
+		if bytes.Compare(a, b) >= 0 {
+			t.Errorf("%v (%x) does not sort before %v (%x)", ordered[i-1], a, ordered[i], b)
+		}
+		if c := tuple.Compare(tuple.Tuple{ordered[i-1]}, tuple.Tuple{ordered[i]}); c != -1 {
+			t.Errorf("Compare(%v, %v) returned %d", ordered[i-1], ordered[i], c)
+		}
+		if c := tuple.Compare(tuple.Tuple{ordered[i]}, tuple.Tuple{ordered[i-1]}); c != 1 {
+			t.Errorf("Compare(%v, %v) returned %d", ordered[i], ordered[i-1], c)
+		}
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	tests := []struct {
+		enc []byte
+		err string
+	}{
+		{[]byte{0x01, 'a'}, "unterminated string with typecode 01 at offset 0"},
+		{[]byte{0x14, 0x02, 'a', 0x00, 0xFF}, "unterminated string with typecode 02 at offset 1"},
+		{[]byte{0x15}, "truncated tuple element with typecode 15 at offset 0 (2 bytes required, 1 available)"},
+		{[]byte{0x14, 0x0C, 0x00}, "truncated tuple element with typecode 0c at offset 1 (9 bytes required, 2 available)"},
+		{[]byte{0x1D}, "truncated integer at offset 0 (missing length)"},
+		{[]byte{0x1D, 0x09, 0x01}, "truncated integer at offset 0 (11 bytes required, 3 available)"},
+		{[]byte{0x20, 0x00}, "truncated tuple element with typecode 20 at offset 0 (5 bytes required, 2 available)"},
+		{[]byte{0x30, 0x00}, "truncated tuple element with typecode 30 at offset 0 (17 bytes required, 2 available)"},
+		{[]byte{0x05, 0x15, 0x01}, "unterminated nested tuple at offset 0"},
+		{[]byte{0x05, 0x05, 0x00, 0x03}, "unknown tuple element typecode 03 at offset 3"},
+		{[]byte{0xFF}, "unknown tuple element typecode ff at offset 0"},
+	}
+
+	for _, test := range tests {
+		_, e := tuple.Unpack(test.enc)
+		if e == nil {
+			t.Errorf("unpacked invalid encoding %x", test.enc)
+		} else if e.Error() != test.err {
+			t.Errorf("unpacking %x: got error %q, expected %q", test.enc, e, test.err)
+		}
+
+		r := tuple.NewTupleReader(test.enc)
+		for r.Next() {
+		}
+		if e := r.Err(); e == nil {
+			t.Errorf("read invalid encoding %x", test.enc)
+		} else if e.Error() != test.err {
+			t.Errorf("reading %x: got error %q, expected %q", test.enc, e, test.err)
+		}
+	}
+}
+
+func TestPackWithError(t *testing.T) {
+	tests := []tuple.Tuple{
+		{struct{}{}},
+		{int64(1), tuple.Tuple{int8(1)}},
+		{tuple.IncompleteVersionstamp(0)},
+		{(*big.Int)(nil)},
+		{new(big.Int).Lsh(big.NewInt(1), 256*8)},
+	}
+
+	for _, test := range tests {
+		if _, e := test.PackWithError(); e == nil {
+			t.Errorf("packed unencodable tuple %v", test)
+		}
+	}
+}
+
+func FuzzUnpack(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(tuple.Tuple{nil, []byte("a\x00b"), "c", int64(-1), uint64(math.MaxUint64)}.Pack())
+	f.Add(tuple.Tuple{bigInt("-0x10000000000000000"), float32(1), float64(-1), true}.Pack())
+	f.Add(tuple.Tuple{tuple.Tuple{nil, tuple.Tuple{}}, tuple.UUID{}, tuple.Versionstamp{}}.Pack())
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		u, e := tuple.Unpack(b)
+
+		// A TupleReader reaches the same elements, and fails with the same
+		// error. (Codec errors are reported differently, and may be found
+		// before or after a malformed element.)
+		var ru tuple.Tuple
+		var de error
+		r := tuple.NewTupleReader(b)
+		for de == nil && r.Next() {
+			var el tuple.TupleElement
+			el, de = r.Element()
+			ru = append(ru, el)
+		}
+		re := r.Err()
+		if re == nil {
+			re = de
+		}
+		if (e == nil) != (re == nil) || e != nil && e.Error() != re.Error() && !strings.Contains(e.Error()+re.Error(), "cannot decode") {
+			t.Fatalf("reading %x: got error %v, but Unpack returned %v", b, re, e)
+		}
+		if e == nil && (len(u) != len(ru) || tuple.Compare(u, ru) != 0) {
+			t.Fatalf("read %v from %x, but Unpack returned %v", ru, b, u)
+		}
+
+		if e != nil || u.HasIncompleteVersionstamp() {
+			return
+		}
+
+		// Encodings are not unique (integers and nested nils may be encoded
+		// in more than one way), but packing is idempotent.
+		p, e := u.PackWithError()
+		if e != nil {
+			t.Fatalf("unable to pack %v unpacked from %x: %v", u, b, e)
+		}
+		u2, e := tuple.Unpack(p)
+		if e != nil {
+			t.Fatalf("unable to unpack %x packed from %v: %v", p, u, e)
+		}
+		if p2 := u2.Pack(); !bytes.Equal(p, p2) {
+			t.Fatalf("repacking %x produced %x", p, p2)
+		}
+
+		if c := tuple.Compare(u, u2); c != 0 {
+			t.Fatalf("Compare(%v, %v) returned %d", u, u2, c)
+		}
+		if c, pc := tuple.Compare(u, tuple.Tuple{int64(0)}), bytes.Compare(p, tuple.Tuple{int64(0)}.Pack()); c != pc {
+			t.Fatalf("Compare(%v, (0,)) returned %d, but packed comparison is %d", u, c, pc)
+		}
+
+		// Values of registered types cannot be parsed.
+		if hasCodecValue(u) {
+			return
+		}
+
+		s := u.String()
+		u3, e := tuple.Parse(s)
+		if e != nil {
+			t.Fatalf("unable to parse %s: %v", s, e)
+		}
+		// NaNs are formatted without their payloads, so compare the
+		// formatted tuples rather than their encodings.
+		if s3 := u3.String(); s3 != s {
+			t.Fatalf("%s parsed as %s", s, s3)
+		}
+	})
+}
+
+func FuzzPackOrdering(f *testing.F) {
+	f.Add(int64(0), int64(1), "", "a", 0.0, 1.0)
+	f.Add(int64(-1), int64(math.MinInt64), "\x00", "\x00\xFF", math.Inf(-1), math.Copysign(0, -1))
+
+	f.Fuzz(func(t *testing.T, i1, i2 int64, s1, s2 string, d1, d2 float64) {
+		check := func(a, b tuple.TupleElement, cmp int) {
+			pa := tuple.Tuple{a}.Pack()
+			pb := tuple.Tuple{b}.Pack()
+			if c := bytes.Compare(pa, pb); c != cmp {
+				t.Errorf("%v and %v packed as %x and %x (comparison %d, expected %d)", a, b, pa, pb, c, cmp)
+			}
+		}
+
+		check(i1, i2, compare(i1 < i2, i1 > i2))
+		check(s1, s2, strings.Compare(s1, s2))
+		check([]byte(s1), []byte(s2), strings.Compare(s1, s2))
+		check(tuple.Tuple{s1, i1}, tuple.Tuple{s2, i2}, compare(s1 < s2 || s1 == s2 && i1 < i2, s1 > s2 || s1 == s2 && i1 > i2))
+		if !math.IsNaN(d1) && !math.IsNaN(d2) && (d1 != 0 || d2 != 0) {
+			check(d1, d2, compare(d1 < d2, d1 > d2))
+		}
+
+		for _, tup := range []tuple.Tuple{{i1, s1, d1}, {tuple.Tuple{[]byte(s2), nil}, i2}} {
+			u, e := tuple.Unpack(tup.Pack())
+			if e != nil {
+				t.Fatal(e)
+			}
+			if !bytes.Equal(u.Pack(), tup.Pack()) {
+				t.Errorf("%v unpacked as %v", tup, u)
+			}
+		}
+	})
+}
+
+// hasCodecValue returns true if t contains a value of a type registered by the
+// tests.
+func hasCodecValue(t tuple.Tuple) bool {
+	for _, el := range t {
+		switch el := el.(type) {
+		case time.Time, amount, priority, broken:
+			return true
+		case tuple.Tuple:
+			if hasCodecValue(el) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}